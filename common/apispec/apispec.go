@@ -0,0 +1,267 @@
+package apispec
+
+import (
+	"bytes"
+	"common/validation"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"github.com/gin-gonic/gin"
+)
+
+// how responses are checked against the spec, strict answers 500 instead of a response breaking the contract
+const (
+	ResponsesOff    = "off"
+	ResponsesLog    = "log"
+	ResponsesStrict = "strict"
+)
+
+func init() {
+	openapi3.DefineStringFormatValidator("uuid", openapi3.NewRegexpFormatValidator(openapi3.FormatOfStringForUUIDOfRFC4122))
+}
+
+// Spec is the OpenAPI document of a service, it serves the document and checks the traffic of its routes against it.
+type Spec struct {
+	document *openapi3.T
+	router   routers.Router
+	json     []byte
+	// checks responses, one of the Responses constants
+	responses string
+}
+
+// Load parses and validates an OpenAPI 3 document in yaml or json.
+func Load(data []byte, responses string) (*Spec, error) {
+	switch responses {
+	case ResponsesOff, ResponsesLog, ResponsesStrict:
+	case "":
+		responses = ResponsesOff
+	default:
+		return nil, fmt.Errorf("unknown response validation %q", responses)
+	}
+
+	document, err := openapi3.NewLoader().LoadFromData(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := document.Validate(context.Background()); err != nil {
+		return nil, fmt.Errorf("invalid spec: %w", err)
+	}
+	router, err := gorillamux.NewRouter(document)
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := json.Marshal(document)
+	if err != nil {
+		return nil, err
+	}
+	return &Spec{document: document, router: router, json: encoded, responses: responses}, nil
+}
+
+// Serve adds GET /openapi.json with the document and GET /docs rendering it.
+func (s *Spec) Serve(routes gin.IRoutes) {
+	routes.GET("/openapi.json", func(ctx *gin.Context) {
+		ctx.Data(http.StatusOK, "application/json", s.json)
+	})
+	page := fmt.Sprintf(docsPage, s.document.Info.Title)
+	routes.GET("/docs", func(ctx *gin.Context) {
+		ctx.Data(http.StatusOK, "text/html; charset=utf-8", []byte(page))
+	})
+}
+
+const docsPage = `<!DOCTYPE html>
+<html>
+<head>
+  <title>%s</title>
+  <meta charset="utf-8">
+</head>
+<body>
+  <redoc spec-url="/openapi.json"></redoc>
+  <script src="https://cdn.redoc.ly/redoc/latest/bundles/redoc.standalone.js"></script>
+</body>
+</html>
+`
+
+// Validate rejects requests breaking the spec with 400 and all their field errors, and checks the responses as
+// configured. Requests of routes missing from the spec pass unchecked. Authentication is left to the middlewares
+// in front of it, the security schemes of the spec only document it.
+func (s *Spec) Validate() gin.HandlerFunc {
+	options := &openapi3filter.Options{
+		MultiError:            true,
+		IncludeResponseStatus: true,
+		AuthenticationFunc:    openapi3filter.NoopAuthenticationFunc,
+	}
+	return func(ctx *gin.Context) {
+		route, pathParams, err := s.router.FindRoute(ctx.Request)
+		if err != nil {
+			ctx.Next()
+			return
+		}
+		input := &openapi3filter.RequestValidationInput{
+			Request:    ctx.Request,
+			PathParams: pathParams,
+			Route:      route,
+			Options:    options,
+		}
+		if err := openapi3filter.ValidateRequest(ctx.Request.Context(), input); err != nil {
+			validation.Render(ctx, fieldErrors(err))
+			return
+		}
+
+		if s.responses == ResponsesOff || streams(route) {
+			ctx.Next()
+			return
+		}
+		writer := ctx.Writer
+		recorder := &responseRecorder{ResponseWriter: writer, status: http.StatusOK}
+		ctx.Writer = recorder
+		ctx.Next()
+		ctx.Writer = writer
+
+		output := &openapi3filter.ResponseValidationInput{
+			RequestValidationInput: input,
+			Status:                 recorder.status,
+			Header:                 writer.Header(),
+			Options:                options,
+		}
+		output.SetBodyBytes(recorder.body.Bytes())
+		if err := openapi3filter.ValidateResponse(ctx.Request.Context(), output); err != nil {
+			log.Printf("Response %d of %s %s breaks the spec: %v", recorder.status, ctx.Request.Method, ctx.Request.URL.Path, err)
+			if s.responses == ResponsesStrict {
+				body, _ := json.Marshal(gin.H{"status": "error", "message": "response breaks the spec: " + err.Error()})
+				writer.Header().Set("Content-Type", "application/json")
+				writer.Header().Del("Content-Length")
+				writer.WriteHeader(http.StatusInternalServerError)
+				writer.Write(body)
+				return
+			}
+		}
+		writer.WriteHeader(recorder.status)
+		writer.Write(recorder.body.Bytes())
+	}
+}
+
+// streams tells whether route answers with server-sent events, which can not be held back for validation.
+func streams(route *routers.Route) bool {
+	for _, response := range route.Operation.Responses.Map() {
+		if response.Value != nil && response.Value.Content.Get("text/event-stream") != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// responseRecorder holds the response back until it was checked.
+type responseRecorder struct {
+	gin.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+}
+
+func (r *responseRecorder) WriteHeaderNow() {}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	return r.body.Write(data)
+}
+
+func (r *responseRecorder) WriteString(data string) (int, error) {
+	return r.body.WriteString(data)
+}
+
+func (r *responseRecorder) Status() int {
+	return r.status
+}
+
+func (r *responseRecorder) Size() int {
+	return r.body.Len()
+}
+
+func (r *responseRecorder) Written() bool {
+	return r.body.Len() > 0
+}
+
+// rules of the spec named like the validate tags, so clients see one vocabulary whichever check failed
+var rules = map[string]string{
+	"exclusiveMinimum": "gt",
+	"minimum":          "gte",
+	"exclusiveMaximum": "lt",
+	"maximum":          "lte",
+	"minLength":        "min",
+	"minItems":         "min",
+	"maxLength":        "max",
+	"maxItems":         "max",
+	"enum":             "oneof",
+}
+
+func fieldErrors(err error) validation.Errors {
+	var collected validation.Errors
+	collect(err, "", &collected)
+	return collected
+}
+
+// collect flattens the errors of the request validation, field is the parameter or body path they belong to.
+// MultiError matches any of its errors with errors.As, so the error types are switched on directly.
+func collect(err error, field string, out *validation.Errors) {
+	switch e := err.(type) {
+	case openapi3.MultiError:
+		for _, inner := range e {
+			collect(inner, field, out)
+		}
+	case *openapi3filter.RequestError:
+		if e.Parameter != nil {
+			field = e.Parameter.Name
+		}
+		if e.Err != nil {
+			collect(e.Err, field, out)
+			return
+		}
+		*out = append(*out, validation.FieldError{Field: fieldOr(field), Rule: "format", Message: e.Reason})
+	case *openapi3.SchemaError:
+		rule := e.SchemaField
+		if rule == "format" && e.Schema != nil {
+			rule = e.Schema.Format
+		}
+		if renamed, ok := rules[rule]; ok {
+			rule = renamed
+		}
+		*out = append(*out, validation.FieldError{Field: fieldOr(join(field, e.JSONPointer())), Rule: rule, Message: e.Reason})
+	default:
+		*out = append(*out, validation.FieldError{Field: fieldOr(field), Rule: "format", Message: "is malformed"})
+	}
+}
+
+// join appends the json pointer of a schema error to field, e.g. items[0].quantity.
+func join(field string, pointer []string) string {
+	var path strings.Builder
+	path.WriteString(field)
+	for _, segment := range pointer {
+		if _, err := strconv.Atoi(segment); err == nil {
+			path.WriteString("[" + segment + "]")
+			continue
+		}
+		if path.Len() > 0 {
+			path.WriteString(".")
+		}
+		path.WriteString(segment)
+	}
+	return path.String()
+}
+
+func fieldOr(field string) string {
+	if field == "" {
+		return "body"
+	}
+	return field
+}
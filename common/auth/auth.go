@@ -0,0 +1,155 @@
+// Package auth verifies the JWTs customers call the services with and carries their claims to downstream services.
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+)
+
+// roles of staff tokens, customers have none
+const (
+	RoleAdmin   = "admin"
+	RoleSupport = "support"
+)
+
+var (
+	ErrMissingToken = errors.New("missing bearer token")
+	ErrInvalidToken = errors.New("invalid token")
+)
+
+// Config selects the signing algorithm and where the verification keys come from, a JWKS file wins over a key file.
+// The key file holds the shared secret for HS256 and a PEM public key for RS256.
+type Config struct {
+	Algorithm string
+	KeyFile   string
+	JWKSFile  string
+	// checked when set
+	Issuer   string
+	Audience string
+}
+
+// Claims are the verified claims of a caller, the subject is the customer id.
+type Claims struct {
+	jwt.RegisteredClaims
+	Roles []string `json:"roles,omitempty"`
+	// Token is the raw token, forwarded to downstream services on behalf of the caller
+	Token string `json:"-"`
+}
+
+// HasRole tells whether the caller has any of roles.
+func (c *Claims) HasRole(roles ...string) bool {
+	for _, granted := range c.Roles {
+		for _, role := range roles {
+			if granted == role {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type Verifier struct {
+	parser *jwt.Parser
+	// keys by key id, a single key is stored under the empty id
+	keys map[string]interface{}
+}
+
+func NewVerifier(config Config) (*Verifier, error) {
+	if config.Algorithm != AlgorithmHS256 && config.Algorithm != AlgorithmRS256 {
+		return nil, fmt.Errorf("unsupported jwt algorithm %q", config.Algorithm)
+	}
+
+	var keys map[string]interface{}
+	var err error
+	switch {
+	case config.JWKSFile != "":
+		keys, err = loadJWKS(config.JWKSFile, config.Algorithm)
+	case config.KeyFile != "":
+		keys, err = loadKeyFile(config.KeyFile, config.Algorithm)
+	default:
+		err = errors.New("either a jwt key file or a jwks file is required")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	options := []jwt.ParserOption{jwt.WithValidMethods([]string{config.Algorithm}), jwt.WithExpirationRequired()}
+	if config.Issuer != "" {
+		options = append(options, jwt.WithIssuer(config.Issuer))
+	}
+	if config.Audience != "" {
+		options = append(options, jwt.WithAudience(config.Audience))
+	}
+	return &Verifier{parser: jwt.NewParser(options...), keys: keys}, nil
+}
+
+// Verify checks the signature and the registered claims of token, tokens without a subject are rejected.
+func (v *Verifier) Verify(token string) (*Claims, error) {
+	claims := &Claims{Token: token}
+	if _, err := v.parser.ParseWithClaims(token, claims, v.key); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: missing subject", ErrInvalidToken)
+	}
+	return claims, nil
+}
+
+// VerifyHeader verifies the bearer token of an Authorization header.
+func (v *Verifier) VerifyHeader(header string) (*Claims, error) {
+	scheme, token, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+		return nil, ErrMissingToken
+	}
+	return v.Verify(strings.TrimSpace(token))
+}
+
+func (v *Verifier) key(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if key, ok := v.keys[kid]; ok {
+		return key, nil
+	}
+	// tokens without a key id are accepted as long as there is no choice
+	if kid == "" && len(v.keys) == 1 {
+		for _, key := range v.keys {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown key id %q", kid)
+}
+
+// Forward passes the token of claims on to a downstream request, requests of anonymous callers are left alone.
+func Forward(req *http.Request, claims *Claims) {
+	if claims != nil {
+		req.Header.Set("Authorization", "Bearer "+claims.Token)
+	}
+}
+
+func loadKeyFile(path string, algorithm string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if algorithm == AlgorithmHS256 {
+		secret := []byte(strings.TrimSpace(string(data)))
+		if len(secret) == 0 {
+			return nil, fmt.Errorf("jwt key file %s is empty", path)
+		}
+		return map[string]interface{}{"": secret}, nil
+	}
+	key, err := jwt.ParseRSAPublicKeyFromPEM(data)
+	if err != nil {
+		return nil, fmt.Errorf("jwt key file %s: %w", path, err)
+	}
+	return map[string]interface{}{"": key}, nil
+}
@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+)
+
+type jwks struct {
+	Keys []jwk `json:"keys"`
+}
+
+// jwk holds the fields of RSA and symmetric keys, others are skipped.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	K   string `json:"k"`
+}
+
+// loadJWKS reads the keys usable with algorithm from a JWKS file, keys meant for encryption or another algorithm are skipped.
+func loadJWKS(path string, algorithm string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var set jwks
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("jwks file %s: %w", path, err)
+	}
+
+	keys := map[string]interface{}{}
+	for _, key := range set.Keys {
+		if (key.Use != "" && key.Use != "sig") || (key.Alg != "" && key.Alg != algorithm) {
+			continue
+		}
+		var parsed interface{}
+		switch {
+		case key.Kty == "RSA" && algorithm == AlgorithmRS256:
+			parsed, err = key.rsaPublicKey()
+		case key.Kty == "oct" && algorithm == AlgorithmHS256:
+			parsed, err = base64.RawURLEncoding.DecodeString(key.K)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("jwks file %s, key %q: %w", path, key.Kid, err)
+		}
+		keys[key.Kid] = parsed
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("jwks file %s has no %s keys", path, algorithm)
+	}
+	return keys, nil
+}
+
+func (key jwk) rsaPublicKey() (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(key.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := base64.RawURLEncoding.DecodeString(key.E)
+	if err != nil {
+		return nil, err
+	}
+	exponent := new(big.Int).SetBytes(e)
+	if len(n) == 0 || !exponent.IsInt64() || exponent.Int64() < 3 {
+		return nil, fmt.Errorf("malformed rsa key")
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+}
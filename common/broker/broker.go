@@ -0,0 +1,67 @@
+package broker
+
+import (
+	"context"
+	"fmt"
+)
+
+const (
+	BackendRabbitMQ = "rabbitmq"
+	BackendNats     = "nats"
+	BackendMemory   = "memory"
+)
+
+// Message is what services exchange over queues, headers carry metadata such as the queue a message failed on.
+type Message struct {
+	Body        []byte
+	Headers     map[string]string
+	Redelivered bool
+}
+
+// Delivery is a received message that has to be acknowledged, Nack with requeue hands it out again.
+type Delivery struct {
+	Message
+	ack  func() error
+	nack func(requeue bool) error
+}
+
+func (d Delivery) Ack() error {
+	return d.ack()
+}
+
+func (d Delivery) Nack(requeue bool) error {
+	return d.nack(requeue)
+}
+
+// Handler is called for every delivery of a subscription, one delivery at a time.
+type Handler func(ctx context.Context, delivery Delivery)
+
+// Broker is the point to point messaging used between the services. Every queue is consumed
+// by competing subscribers, so replicas of a service share the work.
+type Broker interface {
+	// Declare makes sure the queues exist, it is safe to call for existing queues.
+	Declare(queues ...string) error
+	Publish(ctx context.Context, queue string, msg Message) error
+	// Subscribe delivers messages of the queue to handler until ctx is done.
+	Subscribe(ctx context.Context, queue string, handler Handler) error
+	Close() error
+}
+
+type Config struct {
+	Backend     string
+	RabbitMQUrl string
+	NatsUrl     string
+}
+
+// New connects to the backend selected in cfg, a memory broker only connects the components of one process.
+func New(cfg Config) (Broker, error) {
+	switch cfg.Backend {
+	case BackendRabbitMQ:
+		return NewRabbitMQBroker(cfg.RabbitMQUrl)
+	case BackendNats:
+		return NewNatsBroker(cfg.NatsUrl)
+	case BackendMemory:
+		return NewMemoryBroker(), nil
+	}
+	return nil, fmt.Errorf("unknown broker %s", cfg.Backend)
+}
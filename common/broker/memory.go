@@ -0,0 +1,144 @@
+package broker
+
+import (
+	"context"
+	"sync"
+)
+
+type memoryQueue struct {
+	messages []Message
+	// signals waiting subscribers that messages were added
+	ready chan struct{}
+}
+
+// MemoryBroker passes messages between subscribers of the same process, meant for tests and single binary demos.
+type MemoryBroker struct {
+	mu     sync.Mutex
+	queues map[string]*memoryQueue
+}
+
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{
+		queues: map[string]*memoryQueue{},
+	}
+}
+
+func (mb *MemoryBroker) Declare(queues ...string) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	for _, queue := range queues {
+		mb.queue(queue)
+	}
+	return nil
+}
+
+func (mb *MemoryBroker) Publish(ctx context.Context, queue string, msg Message) error {
+	mb.push(queue, msg)
+	return nil
+}
+
+func (mb *MemoryBroker) Subscribe(ctx context.Context, queue string, handler Handler) error {
+	mb.mu.Lock()
+	q := mb.queue(queue)
+	mb.mu.Unlock()
+
+	go func() {
+		for {
+			msg, ok := mb.pop(q)
+			if !ok {
+				select {
+				case <-q.ready:
+					continue
+				case <-ctx.Done():
+					return
+				}
+			}
+			handler(ctx, mb.delivery(queue, msg))
+		}
+	}()
+	return nil
+}
+
+func (mb *MemoryBroker) Close() error {
+	return nil
+}
+
+// Len returns the number of messages waiting in a queue.
+func (mb *MemoryBroker) Len(queue string) int {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	return len(mb.queue(queue).messages)
+}
+
+func (mb *MemoryBroker) delivery(queue string, msg Message) Delivery {
+	var once sync.Once
+	return Delivery{
+		Message: msg,
+		ack: func() error {
+			return nil
+		},
+		nack: func(requeue bool) error {
+			once.Do(func() {
+				if requeue {
+					msg.Redelivered = true
+					mb.push(queue, msg)
+				}
+			})
+			return nil
+		},
+	}
+}
+
+func (mb *MemoryBroker) push(queue string, msg Message) {
+	mb.mu.Lock()
+	q := mb.queue(queue)
+	q.messages = append(q.messages, copyMessage(msg))
+	mb.mu.Unlock()
+
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+func (mb *MemoryBroker) pop(q *memoryQueue) (Message, bool) {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	if len(q.messages) == 0 {
+		return Message{}, false
+	}
+	msg := q.messages[0]
+	q.messages = q.messages[1:]
+	// more messages left, wake up another subscriber of the queue
+	if len(q.messages) > 0 {
+		select {
+		case q.ready <- struct{}{}:
+		default:
+		}
+	}
+	return msg, true
+}
+
+// queue has to be called with mu held.
+func (mb *MemoryBroker) queue(name string) *memoryQueue {
+	q, exists := mb.queues[name]
+	if !exists {
+		q = &memoryQueue{ready: make(chan struct{}, 1)}
+		mb.queues[name] = q
+	}
+	return q
+}
+
+func copyMessage(msg Message) Message {
+	headers := make(map[string]string, len(msg.Headers))
+	for key, value := range msg.Headers {
+		headers[key] = value
+	}
+	body := make([]byte, len(msg.Body))
+	copy(body, msg.Body)
+	return Message{
+		Body:        body,
+		Headers:     headers,
+		Redelivered: msg.Redelivered,
+	}
+}
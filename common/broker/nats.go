@@ -0,0 +1,113 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"log"
+)
+
+// NatsBroker maps every queue to a JetStream work queue stream with a single subject,
+// subscribers share a durable consumer so a message is handled by one of them.
+type NatsBroker struct {
+	conn      *nats.Conn
+	jetStream jetstream.JetStream
+}
+
+func NewNatsBroker(url string) (*NatsBroker, error) {
+	conn, err := nats.Connect(url)
+	if err != nil {
+		return nil, err
+	}
+	js, err := jetstream.New(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &NatsBroker{
+		conn:      conn,
+		jetStream: js,
+	}, nil
+}
+
+func (nb *NatsBroker) Declare(queues ...string) error {
+	for _, queue := range queues {
+		_, err := nb.jetStream.CreateStream(context.Background(), jetstream.StreamConfig{
+			Name:      queue,
+			Subjects:  []string{queue},
+			Retention: jetstream.WorkQueuePolicy,
+			Storage:   jetstream.FileStorage,
+		})
+		if err != nil && !errors.Is(err, jetstream.ErrStreamNameAlreadyInUse) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (nb *NatsBroker) Publish(ctx context.Context, queue string, msg Message) error {
+	natsMsg := nats.NewMsg(queue)
+	natsMsg.Data = msg.Body
+	for key, value := range msg.Headers {
+		natsMsg.Header.Set(key, value)
+	}
+	_, err := nb.jetStream.PublishMsg(ctx, natsMsg)
+	return err
+}
+
+func (nb *NatsBroker) Subscribe(ctx context.Context, queue string, handler Handler) error {
+	consumer, err := nb.jetStream.CreateOrUpdateConsumer(ctx, queue, jetstream.ConsumerConfig{
+		Durable:   queue + "-consumer",
+		AckPolicy: jetstream.AckExplicitPolicy,
+	})
+	if err != nil {
+		return err
+	}
+	consumeContext, err := consumer.Consume(func(msg jetstream.Msg) {
+		handler(ctx, natsDelivery(msg))
+	})
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		<-ctx.Done()
+		consumeContext.Stop()
+	}()
+	return nil
+}
+
+func (nb *NatsBroker) Close() error {
+	return nb.conn.Drain()
+}
+
+func natsDelivery(msg jetstream.Msg) Delivery {
+	headers := make(map[string]string, len(msg.Headers()))
+	for key := range msg.Headers() {
+		headers[key] = msg.Headers().Get(key)
+	}
+	redelivered := false
+	if metadata, err := msg.Metadata(); err == nil {
+		redelivered = metadata.NumDelivered > 1
+	} else {
+		log.Println("Failed to read nats message metadata:", err)
+	}
+	return Delivery{
+		Message: Message{
+			Body:        msg.Data(),
+			Headers:     headers,
+			Redelivered: redelivered,
+		},
+		ack: func() error {
+			return msg.Ack()
+		},
+		nack: func(requeue bool) error {
+			if requeue {
+				return msg.Nak()
+			}
+			// dropped like a rejected rabbitmq message
+			return msg.Term()
+		},
+	}
+}
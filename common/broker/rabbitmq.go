@@ -0,0 +1,129 @@
+package broker
+
+import (
+	"context"
+	"fmt"
+	"github.com/streadway/amqp"
+	"log"
+)
+
+// RabbitMQBroker publishes to durable queues over the default exchange.
+type RabbitMQBroker struct {
+	conn    *amqp.Connection
+	channel *amqp.Channel
+}
+
+func NewRabbitMQBroker(url string) (*RabbitMQBroker, error) {
+	conn, err := amqp.Dial(url)
+	if err != nil {
+		return nil, err
+	}
+	ch, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &RabbitMQBroker{
+		conn:    conn,
+		channel: ch,
+	}, nil
+}
+
+func (rb *RabbitMQBroker) Declare(queues ...string) error {
+	for _, queue := range queues {
+		_, err := rb.channel.QueueDeclare(
+			queue,
+			true,
+			false,
+			false,
+			false,
+			nil,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to declare queue %s: %w", queue, err)
+		}
+	}
+	return nil
+}
+
+func (rb *RabbitMQBroker) Publish(ctx context.Context, queue string, msg Message) error {
+	headers := amqp.Table{}
+	for key, value := range msg.Headers {
+		headers[key] = value
+	}
+	return rb.channel.Publish(
+		"",
+		queue,
+		false,
+		false,
+		amqp.Publishing{
+			ContentType:  "text/plain",
+			DeliveryMode: amqp.Persistent,
+			Headers:      headers,
+			Body:         msg.Body,
+		},
+	)
+}
+
+// Subscribe consumes on a channel of its own, so a slow handler does not block publishing.
+func (rb *RabbitMQBroker) Subscribe(ctx context.Context, queue string, handler Handler) error {
+	ch, err := rb.conn.Channel()
+	if err != nil {
+		return err
+	}
+	msgs, err := ch.Consume(
+		queue,
+		"",
+		false, // autoAck disabled so that we explicitly ack messages
+		false,
+		false,
+		false,
+		nil,
+	)
+	if err != nil {
+		ch.Close()
+		return err
+	}
+
+	go func() {
+		defer ch.Close()
+		for {
+			select {
+			case msg, ok := <-msgs:
+				if !ok {
+					log.Println("RabbitMQ channel closed for queue", queue)
+					return
+				}
+				handler(ctx, rabbitMQDelivery(msg))
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+func (rb *RabbitMQBroker) Close() error {
+	rb.channel.Close()
+	return rb.conn.Close()
+}
+
+func rabbitMQDelivery(msg amqp.Delivery) Delivery {
+	headers := make(map[string]string, len(msg.Headers))
+	for key, value := range msg.Headers {
+		headers[key] = fmt.Sprint(value)
+	}
+	return Delivery{
+		Message: Message{
+			Body:        msg.Body,
+			Headers:     headers,
+			Redelivered: msg.Redelivered,
+		},
+		ack: func() error {
+			return msg.Ack(false)
+		},
+		nack: func(requeue bool) error {
+			return msg.Nack(false, requeue)
+		},
+	}
+}
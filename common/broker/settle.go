@@ -0,0 +1,40 @@
+package broker
+
+import (
+	"context"
+	"log"
+)
+
+// HeaderOriginalQueue is set on dead-lettered and quarantined messages, so they can be replayed to where they came from.
+const HeaderOriginalQueue = "x-original-queue"
+
+// Settle finishes a delivery of queue that was handled with err. Handled deliveries are acked, a failed one is
+// requeued once and moved to the dead-letter queue when it fails on redelivery as well, so it neither spins forever
+// nor gets lost. The headers are kept, so a signed message is still accepted when it is replayed.
+func Settle(ctx context.Context, b Broker, queue string, deadLetter string, delivery Delivery, err error) {
+	if err == nil {
+		if err := delivery.Ack(); err != nil {
+			log.Printf("Failed to ack message from %s: %v", queue, err)
+		}
+		return
+	}
+	if !delivery.Redelivered {
+		delivery.Nack(true)
+		return
+	}
+
+	headers := map[string]string{}
+	for key, value := range delivery.Headers {
+		headers[key] = value
+	}
+	headers[HeaderOriginalQueue] = queue
+	if err := b.Publish(ctx, deadLetter, Message{Body: delivery.Body, Headers: headers}); err != nil {
+		log.Printf("Failed to dead-letter message %q from %s: %v", string(delivery.Body), queue, err)
+		delivery.Nack(true)
+		return
+	}
+	log.Printf("Dead-lettered message %q from %s", string(delivery.Body), queue)
+	if err := delivery.Ack(); err != nil {
+		log.Printf("Failed to ack dead-lettered message from %s: %v", queue, err)
+	}
+}
@@ -0,0 +1,168 @@
+package fault
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// injection points wired into the services
+const (
+	OrderInsertBefore          = "order.insert.before"
+	OrderInsertAfter           = "order.insert.after"
+	OrderCommitBefore          = "order.commit.before"
+	PaymentClientProcess       = "order.payment-client.process"
+	PaymentCommitBefore        = "payment.commit.before"
+	OrderRollback              = "order.rollback"
+	PaymentRollback            = "payment.rollback"
+	InventoryRollback          = "inventory.rollback"
+	OrchestratorRollbackDelete = "orchestrator.rollback.before-delete"
+)
+
+const (
+	// KindError makes the point fail with ErrInjected
+	KindError = "error"
+	// KindDelay sleeps for Delay and lets the call go on
+	KindDelay = "delay"
+	// KindTimeout sleeps for Delay and then fails as if the deadline was exceeded
+	KindTimeout = "timeout"
+	// KindStatus answers with Status instead of calling the remote side
+	KindStatus = "status"
+	// KindLostResponse lets the remote call succeed and drops its response
+	KindLostResponse = "lost-response"
+	// KindPanic panics at the point, simulating a crashed process
+	KindPanic = "panic"
+)
+
+var (
+	ErrInjected = errors.New("injected fault")
+	ErrTimeout  = fmt.Errorf("%w: timeout", ErrInjected)
+	ErrLost     = fmt.Errorf("%w: response lost", ErrInjected)
+	ErrDisabled = errors.New("fault injection is not compiled in, build with -tags faults")
+)
+
+// Fault arms a single injection point. Remaining limits how often it fires, 0 keeps it armed until cleared.
+type Fault struct {
+	Point     string        `json:"point"`
+	Kind      string        `json:"kind"`
+	Delay     time.Duration `json:"delay,omitempty"`
+	Status    int           `json:"status,omitempty"`
+	Remaining int           `json:"remaining,omitempty"`
+}
+
+func (f Fault) validate() error {
+	if f.Point == "" {
+		return errors.New("fault point is required")
+	}
+	switch f.Kind {
+	case KindError, KindDelay, KindTimeout, KindStatus, KindLostResponse, KindPanic:
+	default:
+		return fmt.Errorf("unknown fault kind %q", f.Kind)
+	}
+	if f.Remaining < 0 {
+		return errors.New("remaining must not be negative")
+	}
+	return nil
+}
+
+// Err turns a fired fault into the outcome of a plain call site.
+// Delays are served here, kinds that need cooperation from the caller (status, lost-response) are returned as ErrInjected.
+func (f *Fault) Err() error {
+	if f == nil {
+		return nil
+	}
+	switch f.Kind {
+	case KindDelay:
+		time.Sleep(f.Delay)
+		return nil
+	case KindTimeout:
+		time.Sleep(f.Delay)
+		return ErrTimeout
+	case KindLostResponse:
+		return ErrLost
+	case KindPanic:
+		panic(fmt.Sprintf("injected panic at %s", f.Point))
+	default:
+		return fmt.Errorf("%w at %s", ErrInjected, f.Point)
+	}
+}
+
+// Inject fires the fault armed for point, if any.
+func Inject(point string) error {
+	return Hit(point).Err()
+}
+
+// StatusCode returns the status a status fault answers with, 500 unless configured.
+func (f *Fault) StatusCode() int {
+	if f.Status == 0 {
+		return http.StatusInternalServerError
+	}
+	return f.Status
+}
+
+// Parse reads a comma separated list of point=kind[:arg][*count] entries,
+// e.g. "order.commit.before=error*1,order.payment-client.process=status:503".
+// The argument is a duration for delay and timeout and a status code for status.
+func Parse(spec string) ([]Fault, error) {
+	var faults []Fault
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		point, rule, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("fault %q: expected point=kind", entry)
+		}
+		f := Fault{Point: strings.TrimSpace(point)}
+		rule, count, limited := strings.Cut(rule, "*")
+		if limited {
+			n, err := strconv.Atoi(count)
+			if err != nil {
+				return nil, fmt.Errorf("fault %q: invalid count: %w", entry, err)
+			}
+			f.Remaining = n
+		}
+		f.Kind = rule
+		if kind, arg, ok := strings.Cut(rule, ":"); ok {
+			f.Kind = kind
+			var err error
+			switch kind {
+			case KindDelay, KindTimeout:
+				f.Delay, err = time.ParseDuration(arg)
+			case KindStatus:
+				f.Status, err = strconv.Atoi(arg)
+			default:
+				err = errors.New("kind takes no argument")
+			}
+			if err != nil {
+				return nil, fmt.Errorf("fault %q: %w", entry, err)
+			}
+		}
+		if err := f.validate(); err != nil {
+			return nil, fmt.Errorf("fault %q: %w", entry, err)
+		}
+		faults = append(faults, f)
+	}
+	return faults, nil
+}
+
+// Configure arms every fault of spec, see Parse for the format.
+func Configure(spec string) error {
+	faults, err := Parse(spec)
+	if err != nil {
+		return err
+	}
+	if len(faults) > 0 && !Enabled {
+		return ErrDisabled
+	}
+	for _, f := range faults {
+		if err := Set(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
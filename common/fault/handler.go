@@ -0,0 +1,73 @@
+package fault
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+type faultBody struct {
+	Point     string `json:"point"`
+	Kind      string `json:"kind"`
+	Delay     string `json:"delay,omitempty"`
+	Status    int    `json:"status,omitempty"`
+	Remaining int    `json:"remaining,omitempty"`
+}
+
+// Handler serves the admin endpoint: GET lists armed faults, POST arms one,
+// DELETE clears the point given as ?point= or every fault without it.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !Enabled {
+			writeJSON(w, http.StatusNotFound, map[string]string{"status": "error", "message": ErrDisabled.Error()})
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPost, http.MethodPut:
+			var body faultBody
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"status": "error", "message": err.Error()})
+				return
+			}
+			f := Fault{Point: body.Point, Kind: body.Kind, Status: body.Status, Remaining: body.Remaining}
+			if body.Delay != "" {
+				delay, err := time.ParseDuration(body.Delay)
+				if err != nil {
+					writeJSON(w, http.StatusBadRequest, map[string]string{"status": "error", "message": err.Error()})
+					return
+				}
+				f.Delay = delay
+			}
+			if err := Set(f); err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"status": "error", "message": err.Error()})
+				return
+			}
+		case http.MethodDelete:
+			if point := r.URL.Query().Get("point"); point != "" {
+				Clear(point)
+			} else {
+				Reset()
+			}
+		default:
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"status": "error", "message": "method not allowed"})
+			return
+		}
+		faults := List()
+		bodies := make([]faultBody, 0, len(faults))
+		for _, f := range faults {
+			body := faultBody{Point: f.Point, Kind: f.Kind, Status: f.Status, Remaining: f.Remaining}
+			if f.Delay > 0 {
+				body.Delay = f.Delay.String()
+			}
+			bodies = append(bodies, body)
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"status": "success", "faults": bodies})
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,23 @@
+//go:build !faults
+
+package fault
+
+// Enabled reports whether the binary was built with fault injection.
+const Enabled = false
+
+func Set(Fault) error {
+	return ErrDisabled
+}
+
+func Clear(string) {}
+
+func Reset() {}
+
+func List() []Fault {
+	return nil
+}
+
+// Hit never fires in production builds.
+func Hit(string) *Fault {
+	return nil
+}
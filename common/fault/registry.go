@@ -0,0 +1,68 @@
+//go:build faults
+
+package fault
+
+import (
+	"sort"
+	"sync"
+)
+
+// Enabled reports whether the binary was built with fault injection.
+const Enabled = true
+
+var registry = struct {
+	sync.Mutex
+	faults map[string]*Fault
+}{faults: map[string]*Fault{}}
+
+// Set arms point, replacing a fault armed before.
+func Set(f Fault) error {
+	if err := f.validate(); err != nil {
+		return err
+	}
+	registry.Lock()
+	defer registry.Unlock()
+	registry.faults[f.Point] = &f
+	return nil
+}
+
+func Clear(point string) {
+	registry.Lock()
+	defer registry.Unlock()
+	delete(registry.faults, point)
+}
+
+func Reset() {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.faults = map[string]*Fault{}
+}
+
+func List() []Fault {
+	registry.Lock()
+	defer registry.Unlock()
+	faults := make([]Fault, 0, len(registry.faults))
+	for _, f := range registry.faults {
+		faults = append(faults, *f)
+	}
+	sort.Slice(faults, func(i, j int) bool { return faults[i].Point < faults[j].Point })
+	return faults
+}
+
+// Hit returns the fault armed for point and uses up one of its firings, nil when nothing is armed.
+func Hit(point string) *Fault {
+	registry.Lock()
+	defer registry.Unlock()
+	f, ok := registry.faults[point]
+	if !ok {
+		return nil
+	}
+	fired := *f
+	if f.Remaining > 0 {
+		f.Remaining--
+		if f.Remaining == 0 {
+			delete(registry.faults, point)
+		}
+	}
+	return &fired
+}
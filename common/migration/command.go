@@ -0,0 +1,60 @@
+package migration
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+)
+
+const Usage = "usage: migrate up|down|status"
+
+// RunCommand executes the migrate subcommand of a service binary and prints the outcome to out.
+func RunCommand(migrator *Migrator, args []string, out io.Writer) error {
+	if len(args) != 1 {
+		return errors.New(Usage)
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up()
+		for _, migration := range applied {
+			fmt.Fprintf(out, "applied %04d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Fprintln(out, "no pending migrations")
+		}
+		return nil
+	case "down":
+		reverted, err := migrator.Down()
+		if err != nil {
+			return err
+		}
+		if reverted == nil {
+			fmt.Fprintln(out, "no applied migrations")
+			return nil
+		}
+		fmt.Fprintf(out, "reverted %04d_%s\n", reverted.Version, reverted.Name)
+		return nil
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			return err
+		}
+		writer := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(writer, "VERSION\tNAME\tAPPLIED AT")
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.AppliedAt != nil {
+				appliedAt = status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(writer, "%04d\t%s\t%s\n", status.Migration.Version, status.Migration.Name, appliedAt)
+		}
+		return writer.Flush()
+	}
+	return errors.New(Usage)
+}
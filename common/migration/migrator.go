@@ -0,0 +1,149 @@
+package migration
+
+import (
+	"fmt"
+	"io/fs"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Migrator applies embedded migrations and records them in a tracking table of its own,
+// so services sharing a database do not step on each other.
+type Migrator struct {
+	db         *gorm.DB
+	table      string
+	migrations []Migration
+}
+
+type Status struct {
+	Migration Migration
+	AppliedAt *time.Time
+}
+
+func NewMigrator(db *gorm.DB, table string, fsys fs.FS) (*Migrator, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{
+		db:         db,
+		table:      table,
+		migrations: migrations,
+	}, nil
+}
+
+// Up applies every pending migration in order and returns the ones it applied.
+func (m *Migrator) Up() ([]Migration, error) {
+	if err := m.ensureTable(); err != nil {
+		return nil, err
+	}
+
+	var applied []Migration
+	for _, migration := range m.migrations {
+		ran, err := m.run(migration, true)
+		if err != nil {
+			return applied, fmt.Errorf("migration %d_%s failed: %w", migration.Version, migration.Name, err)
+		}
+		if ran {
+			applied = append(applied, migration)
+		}
+	}
+	return applied, nil
+}
+
+// Down reverts the latest applied migration, it returns nil when nothing is applied.
+func (m *Migrator) Down() (*Migration, error) {
+	if err := m.ensureTable(); err != nil {
+		return nil, err
+	}
+
+	statuses, err := m.Status()
+	if err != nil {
+		return nil, err
+	}
+	for i := len(statuses) - 1; i >= 0; i-- {
+		if statuses[i].AppliedAt == nil {
+			continue
+		}
+		migration := statuses[i].Migration
+		if _, err := m.run(migration, false); err != nil {
+			return nil, fmt.Errorf("migration %d_%s failed: %w", migration.Version, migration.Name, err)
+		}
+		return &migration, nil
+	}
+	return nil, nil
+}
+
+func (m *Migrator) Status() ([]Status, error) {
+	if err := m.ensureTable(); err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		Version   int
+		AppliedAt time.Time
+	}
+	if err := m.db.Table(m.table).Select("version, applied_at").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	appliedAt := make(map[int]time.Time, len(rows))
+	for _, row := range rows {
+		appliedAt[row.Version] = row.AppliedAt
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := Status{Migration: migration}
+		if at, ok := appliedAt[migration.Version]; ok {
+			status.AppliedAt = &at
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// run applies or reverts a single migration in a transaction. The tracking table is locked first,
+// so replicas migrating on startup at the same time apply every migration exactly once.
+func (m *Migrator) run(migration Migration, up bool) (bool, error) {
+	ran := false
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("LOCK TABLE " + m.table + " IN EXCLUSIVE MODE").Error; err != nil {
+			return err
+		}
+
+		var count int64
+		if err := tx.Table(m.table).Where("version = ?", migration.Version).Count(&count).Error; err != nil {
+			return err
+		}
+		if (count > 0) == up {
+			return nil
+		}
+
+		if up {
+			if err := tx.Exec(migration.Up).Error; err != nil {
+				return err
+			}
+			ran = true
+			return tx.Exec("INSERT INTO "+m.table+" (version, name, applied_at) VALUES (?, ?, ?)",
+				migration.Version, migration.Name, time.Now().UTC()).Error
+		}
+
+		if err := tx.Exec(migration.Down).Error; err != nil {
+			return err
+		}
+		ran = true
+		return tx.Exec("DELETE FROM "+m.table+" WHERE version = ?", migration.Version).Error
+	})
+	if err != nil {
+		return false, err
+	}
+	return ran, nil
+}
+
+func (m *Migrator) ensureTable() error {
+	return m.db.Exec("CREATE TABLE IF NOT EXISTS " + m.table + " (" +
+		"version integer not null primary key, " +
+		"name varchar(256) not null, " +
+		"applied_at timestamp not null)").Error
+}
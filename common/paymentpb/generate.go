@@ -0,0 +1,5 @@
+package paymentpb
+
+// The gRPC API of the payment-service is defined in payment.proto, the server and the client of the order-service
+// use the code generated from it. Run go generate after changing payment.proto.
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative payment.proto
@@ -0,0 +1,9 @@
+package paymentpb
+
+// metadata of the calls, the signature of a call travels in the signing headers in lower case
+const (
+	// bearer token of the customer a call is made for
+	MetadataAuthorization = "authorization"
+	// id of the order request a call is made for, it only ends up in the logs
+	MetadataRequestId = "x-request-id"
+)
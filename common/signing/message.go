@@ -0,0 +1,112 @@
+package signing
+
+import (
+	"common/broker"
+	"context"
+	"crypto/hmac"
+	"log"
+	"strconv"
+	"time"
+)
+
+// message headers, lower case as amqp and nats keep them as they are
+const (
+	HeaderMessageKeyId     = "x-signature-key-id"
+	HeaderMessageTimestamp = "x-signature-timestamp"
+	HeaderMessageSignature = "x-signature"
+	// set on quarantined messages
+	HeaderOriginalQueue    = broker.HeaderOriginalQueue
+	HeaderQuarantineReason = "x-quarantine-reason"
+)
+
+// SignMessage adds the signature headers to msg. The signature covers the key id, the timestamp and the body but
+// not the queue, so a message stays valid when it is dead-lettered and replayed. A nil signer leaves msg unsigned.
+func (s *Signer) SignMessage(msg *broker.Message) {
+	if s == nil {
+		return
+	}
+	if msg.Headers == nil {
+		msg.Headers = map[string]string{}
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	msg.Headers[HeaderMessageKeyId] = s.keyId
+	msg.Headers[HeaderMessageTimestamp] = timestamp
+	msg.Headers[HeaderMessageSignature] = signature(s.secret, "MESSAGE", s.keyId, timestamp, "", digestOf(msg.Body))
+}
+
+// MessageVerifier checks the signatures of consumed messages. Messages are redelivered and replayed from the
+// dead-letter queue legitimately, so unlike requests they have neither a nonce nor an expiry.
+type MessageVerifier struct {
+	keys map[string][]byte
+}
+
+func NewMessageVerifier(keys map[string][]byte) *MessageVerifier {
+	return &MessageVerifier{keys: keys}
+}
+
+func (v *MessageVerifier) VerifyMessage(msg broker.Message) error {
+	keyId := msg.Headers[HeaderMessageKeyId]
+	timestamp := msg.Headers[HeaderMessageTimestamp]
+	given := msg.Headers[HeaderMessageSignature]
+	if keyId == "" || timestamp == "" || given == "" {
+		return ErrMissingSignature
+	}
+	secret, ok := v.keys[keyId]
+	if !ok {
+		return ErrUnknownKey
+	}
+	expected := signature(secret, "MESSAGE", keyId, timestamp, "", digestOf(msg.Body))
+	if !hmac.Equal([]byte(given), []byte(expected)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// NewMessageSigning builds the signer of published messages and the verifier of consumed ones from the config of a
+// service, either one is nil when its part is not configured.
+func NewMessageSigning(keyId string, secret string, keys string) (*Signer, *MessageVerifier, error) {
+	var signer *Signer
+	if keyId != "" {
+		signer = NewSigner(keyId, secret)
+	}
+	accepted, err := ParseKeys(keys)
+	if err != nil {
+		return nil, nil, err
+	}
+	var verifier *MessageVerifier
+	if len(accepted) > 0 {
+		verifier = NewMessageVerifier(accepted)
+	}
+	return signer, verifier, nil
+}
+
+// Guard only hands messages with a valid signature to handler, the others are moved to the quarantine queue
+// together with the reason. Without a verifier every message is handled.
+func Guard(b broker.Broker, verifier *MessageVerifier, queue string, quarantine string, handler broker.Handler) broker.Handler {
+	if verifier == nil {
+		return handler
+	}
+	return func(ctx context.Context, delivery broker.Delivery) {
+		err := verifier.VerifyMessage(delivery.Message)
+		if err == nil {
+			handler(ctx, delivery)
+			return
+		}
+
+		log.Printf("Quarantining message %q from %s: %v", string(delivery.Body), queue, err)
+		headers := map[string]string{}
+		for key, value := range delivery.Headers {
+			headers[key] = value
+		}
+		headers[HeaderOriginalQueue] = queue
+		headers[HeaderQuarantineReason] = err.Error()
+		if err := b.Publish(ctx, quarantine, broker.Message{Body: delivery.Body, Headers: headers}); err != nil {
+			log.Printf("Failed to quarantine message from %s: %v", queue, err)
+			delivery.Nack(true)
+			return
+		}
+		if err := delivery.Ack(); err != nil {
+			log.Printf("Failed to ack quarantined message: %v", err)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package signing
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// RedisNonceStore keeps nonces in redis, so every replica of a service rejects a replay.
+type RedisNonceStore struct {
+	client *redis.Client
+	prefix string
+}
+
+func NewRedisNonceStore(client *redis.Client, prefix string) *RedisNonceStore {
+	return &RedisNonceStore{client: client, prefix: prefix}
+}
+
+func (s *RedisNonceStore) Remember(ctx context.Context, nonce string, ttl time.Duration) (bool, error) {
+	return s.client.SetNX(ctx, s.prefix+nonce, 1, ttl).Result()
+}
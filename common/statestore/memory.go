@@ -0,0 +1,84 @@
+package statestore
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps records in process, meant for tests and single process setups.
+type MemoryStore struct {
+	mu      sync.Mutex
+	records map[string]Record
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		records: map[string]Record{},
+	}
+}
+
+func (ms *MemoryStore) Create(ctx context.Context, record Record) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	if _, exists := ms.records[record.UUID]; exists {
+		return ErrExists
+	}
+	ms.records[record.UUID] = record
+	return nil
+}
+
+func (ms *MemoryStore) Get(ctx context.Context, id string) (*Record, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	record, exists := ms.records[id]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	return &record, nil
+}
+
+func (ms *MemoryStore) CompareAndSwap(ctx context.Context, expected Record, next Record) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	current, exists := ms.records[expected.UUID]
+	if !exists {
+		return ErrNotFound
+	}
+	if current != expected {
+		return ErrConflict
+	}
+	next.UUID = expected.UUID
+	next.Version = expected.Version + 1
+	ms.records[expected.UUID] = next
+	return nil
+}
+
+func (ms *MemoryStore) Delete(ctx context.Context, id string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	delete(ms.records, id)
+	return nil
+}
+
+func (ms *MemoryStore) ListExpired(ctx context.Context, now time.Time) ([]Record, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	var expired []Record
+	for _, record := range ms.records {
+		if record.ExpirationTime < now.UnixMilli() {
+			expired = append(expired, record)
+		}
+	}
+	return expired, nil
+}
+
+func (ms *MemoryStore) List(ctx context.Context) ([]Record, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	records := make([]Record, 0, len(ms.records))
+	for _, record := range ms.records {
+		records = append(records, record)
+	}
+	return records, nil
+}
@@ -0,0 +1,117 @@
+package statestore
+
+import (
+	"context"
+	"errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+// TableName is created by the order-service migrations
+const TableName = "orchestrations"
+
+type orchestrationRow struct {
+	UUID           string `gorm:"column:uuid;type:varchar(512);primary_key"`
+	Status         string
+	ExpirationTime int64
+	Version        int64
+}
+
+// PostgresStore keeps a row per record, swaps are conditional updates on the row version.
+type PostgresStore struct {
+	db *gorm.DB
+}
+
+func NewPostgresStore(db *gorm.DB) *PostgresStore {
+	return &PostgresStore{
+		db: db,
+	}
+}
+
+func (ps *PostgresStore) Create(ctx context.Context, record Record) error {
+	row := toRow(record)
+	result := ps.db.WithContext(ctx).Table(TableName).Clauses(clause.OnConflict{DoNothing: true}).Create(&row)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrExists
+	}
+	return nil
+}
+
+func (ps *PostgresStore) Get(ctx context.Context, id string) (*Record, error) {
+	var row orchestrationRow
+	err := ps.db.WithContext(ctx).Table(TableName).Where("uuid = ?", id).First(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	record := toRecord(row)
+	return &record, nil
+}
+
+func (ps *PostgresStore) CompareAndSwap(ctx context.Context, expected Record, next Record) error {
+	result := ps.db.WithContext(ctx).Table(TableName).
+		Where("uuid = ? AND status = ? AND expiration_time = ? AND version = ?",
+			expected.UUID, expected.Status, expected.ExpirationTime, expected.Version).
+		Updates(map[string]interface{}{
+			"status":          next.Status,
+			"expiration_time": next.ExpirationTime,
+			"version":         expected.Version + 1,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		return nil
+	}
+	if _, err := ps.Get(ctx, expected.UUID); err != nil {
+		return err
+	}
+	return ErrConflict
+}
+
+func (ps *PostgresStore) Delete(ctx context.Context, id string) error {
+	return ps.db.WithContext(ctx).Table(TableName).Where("uuid = ?", id).Delete(&orchestrationRow{}).Error
+}
+
+func (ps *PostgresStore) ListExpired(ctx context.Context, now time.Time) ([]Record, error) {
+	return ps.find(ps.db.WithContext(ctx).Table(TableName).Where("expiration_time < ?", now.UnixMilli()))
+}
+
+func (ps *PostgresStore) List(ctx context.Context) ([]Record, error) {
+	return ps.find(ps.db.WithContext(ctx).Table(TableName))
+}
+
+func (ps *PostgresStore) find(query *gorm.DB) ([]Record, error) {
+	var rows []orchestrationRow
+	if err := query.Order("expiration_time").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	records := make([]Record, 0, len(rows))
+	for _, row := range rows {
+		records = append(records, toRecord(row))
+	}
+	return records, nil
+}
+
+func toRow(record Record) orchestrationRow {
+	return orchestrationRow{
+		UUID:           record.UUID,
+		Status:         record.Status,
+		ExpirationTime: record.ExpirationTime,
+		Version:        record.Version,
+	}
+}
+
+func toRecord(row orchestrationRow) Record {
+	return Record{
+		UUID:           row.UUID,
+		Status:         row.Status,
+		ExpirationTime: row.ExpirationTime,
+		Version:        row.Version,
+	}
+}
@@ -0,0 +1,126 @@
+package statestore
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/go-redis/redis/v8"
+	"log"
+	"time"
+)
+
+const maxSwapAttempts = 50
+
+// RedisStore keeps records as json in a single redis hash, swaps are guarded by WATCH on the hash.
+type RedisStore struct {
+	client  *redis.Client
+	mapName string
+}
+
+func NewRedisStore(client *redis.Client, mapName string) *RedisStore {
+	return &RedisStore{
+		client:  client,
+		mapName: mapName,
+	}
+}
+
+func (rs *RedisStore) Create(ctx context.Context, record Record) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	created, err := rs.client.HSetNX(ctx, rs.mapName, record.UUID, data).Result()
+	if err != nil {
+		return err
+	}
+	if !created {
+		return ErrExists
+	}
+	return nil
+}
+
+func (rs *RedisStore) Get(ctx context.Context, id string) (*Record, error) {
+	return rs.get(ctx, rs.client, id)
+}
+
+func (rs *RedisStore) CompareAndSwap(ctx context.Context, expected Record, next Record) error {
+	next.UUID = expected.UUID
+	next.Version = expected.Version + 1
+	data, err := json.Marshal(next)
+	if err != nil {
+		return err
+	}
+
+	swap := func(tx *redis.Tx) error {
+		current, err := rs.get(ctx, tx, expected.UUID)
+		if err != nil {
+			return err
+		}
+		if *current != expected {
+			return ErrConflict
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.HSet(ctx, rs.mapName, expected.UUID, data)
+			return nil
+		})
+		return err
+	}
+	// the watch covers the whole hash, so a write to any other record aborts the transaction as well,
+	// it is retried and only a change of this record is reported as a conflict
+	for attempt := 0; attempt < maxSwapAttempts; attempt++ {
+		err = rs.client.Watch(ctx, swap, rs.mapName)
+		if err != redis.TxFailedErr {
+			return err
+		}
+	}
+	return ErrConflict
+}
+
+func (rs *RedisStore) Delete(ctx context.Context, id string) error {
+	return rs.client.HDel(ctx, rs.mapName, id).Err()
+}
+
+func (rs *RedisStore) ListExpired(ctx context.Context, now time.Time) ([]Record, error) {
+	records, err := rs.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var expired []Record
+	for _, record := range records {
+		if record.ExpirationTime < now.UnixMilli() {
+			expired = append(expired, record)
+		}
+	}
+	return expired, nil
+}
+
+func (rs *RedisStore) List(ctx context.Context) ([]Record, error) {
+	values, err := rs.client.HGetAll(ctx, rs.mapName).Result()
+	if err != nil {
+		return nil, err
+	}
+	records := make([]Record, 0, len(values))
+	for id, val := range values {
+		var record Record
+		if err := json.Unmarshal([]byte(val), &record); err != nil {
+			// one broken record should not stop the others from expiring
+			log.Println("Error unmarshaling record", id, ":", err)
+			continue
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func (rs *RedisStore) get(ctx context.Context, cmd redis.Cmdable, id string) (*Record, error) {
+	val, err := cmd.HGet(ctx, rs.mapName, id).Result()
+	if err == redis.Nil {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	var record Record
+	if err := json.Unmarshal([]byte(val), &record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
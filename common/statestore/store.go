@@ -0,0 +1,44 @@
+package statestore
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+const (
+	BackendRedis    = "redis"
+	BackendPostgres = "postgres"
+	BackendMemory   = "memory"
+)
+
+var (
+	ErrNotFound = errors.New("orchestration not found")
+	ErrExists   = errors.New("orchestration already exists")
+	// ErrConflict means the record changed since it was read, the caller has to read it again
+	ErrConflict = errors.New("orchestration changed meanwhile")
+)
+
+// Record is the saga state shared by the order-service and the orchestrator.
+// Version is increased by the store on every swap.
+type Record struct {
+	UUID           string `json:"uuid"`
+	Status         string `json:"status"`
+	ExpirationTime int64  `json:"expirationTime"`
+	Version        int64  `json:"version"`
+}
+
+func (r Record) ExpiresAt() time.Time {
+	return time.UnixMilli(r.ExpirationTime)
+}
+
+// StateStore keeps orchestration records, implementations are safe for concurrent use by several processes.
+type StateStore interface {
+	Create(ctx context.Context, record Record) error
+	Get(ctx context.Context, id string) (*Record, error)
+	// CompareAndSwap replaces expected with next unless the stored record differs from expected.
+	CompareAndSwap(ctx context.Context, expected Record, next Record) error
+	Delete(ctx context.Context, id string) error
+	ListExpired(ctx context.Context, now time.Time) ([]Record, error)
+	List(ctx context.Context) ([]Record, error)
+}
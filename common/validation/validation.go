@@ -0,0 +1,157 @@
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// FieldError is one violated rule, Field is the path of the field in the request, e.g. items[0].quantity.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Errors are all field errors of a request.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, fieldError := range e {
+		messages = append(messages, fieldError.Field+" "+fieldError.Message)
+	}
+	return strings.Join(messages, ", ")
+}
+
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+	// fields are reported by the names clients send them with
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		for _, tag := range []string{"json", "form", "uri"} {
+			name := strings.Split(field.Tag.Get(tag), ",")[0]
+			if name == "-" {
+				return ""
+			}
+			if name != "" {
+				return name
+			}
+		}
+		return field.Name
+	})
+	return v
+}
+
+// Struct checks the validate tags of v and returns all violations as Errors.
+func Struct(v interface{}) error {
+	err := validate.Struct(v)
+	var violations validator.ValidationErrors
+	if !errors.As(err, &violations) {
+		return err
+	}
+	fieldErrors := make(Errors, 0, len(violations))
+	for _, violation := range violations {
+		fieldErrors = append(fieldErrors, FieldError{
+			Field:   fieldPath(violation.Namespace()),
+			Rule:    violation.Tag(),
+			Message: message(violation),
+		})
+	}
+	return fieldErrors
+}
+
+// BindJSON decodes the body into out and validates it. On failure the errors are rendered and false is returned,
+// so handlers only have to return.
+func BindJSON(ctx *gin.Context, out interface{}) bool {
+	if err := ctx.ShouldBindJSON(out); err != nil {
+		Render(ctx, decodeError(err))
+		return false
+	}
+	return check(ctx, out)
+}
+
+// BindQuery is BindJSON for the query parameters.
+func BindQuery(ctx *gin.Context, out interface{}) bool {
+	if err := ctx.ShouldBindQuery(out); err != nil {
+		Render(ctx, Errors{{Field: "query", Rule: "format", Message: "is malformed: " + err.Error()}})
+		return false
+	}
+	return check(ctx, out)
+}
+
+// Render answers 400 with every field error of err.
+func Render(ctx *gin.Context, err error) {
+	var fieldErrors Errors
+	if !errors.As(err, &fieldErrors) {
+		fieldErrors = Errors{{Field: "body", Rule: "format", Message: "is malformed"}}
+	}
+	ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"status": "error", "message": "invalid request", "errors": fieldErrors})
+}
+
+func check(ctx *gin.Context, out interface{}) bool {
+	if err := Struct(out); err != nil {
+		Render(ctx, err)
+		return false
+	}
+	return true
+}
+
+func decodeError(err error) error {
+	var typeError *json.UnmarshalTypeError
+	if errors.As(err, &typeError) && typeError.Field != "" {
+		return Errors{{Field: typeError.Field, Rule: "type", Message: "must be a " + typeError.Type.String()}}
+	}
+	return Errors{{Field: "body", Rule: "format", Message: "is malformed"}}
+}
+
+// fieldPath drops the name of the request struct from namespace.
+func fieldPath(namespace string) string {
+	if i := strings.Index(namespace, "."); i >= 0 {
+		return namespace[i+1:]
+	}
+	return namespace
+}
+
+func message(violation validator.FieldError) string {
+	param := violation.Param()
+	kind := violation.Kind()
+	switch violation.Tag() {
+	case "required":
+		return "is required"
+	case "uuid", "uuid4":
+		return "must be a uuid"
+	case "iso4217":
+		return "must be an ISO 4217 currency code"
+	case "oneof":
+		return "must be one of " + strings.ReplaceAll(param, " ", ", ")
+	case "gt":
+		return "must be greater than " + param
+	case "gte":
+		return "must be at least " + param
+	case "lt":
+		return "must be less than " + param
+	case "lte":
+		return "must be at most " + param
+	case "min", "max":
+		bound := "at least"
+		if violation.Tag() == "max" {
+			bound = "at most"
+		}
+		switch kind {
+		case reflect.String:
+			return fmt.Sprintf("must be %s %s characters long", bound, param)
+		case reflect.Slice, reflect.Array, reflect.Map:
+			return fmt.Sprintf("must have %s %s items", bound, param)
+		}
+		return fmt.Sprintf("must be %s %s", bound, param)
+	}
+	return "failed the " + violation.Tag() + " rule"
+}
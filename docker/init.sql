@@ -1,8 +1,19 @@
-insert into products (product_id, name, price, create_date, update_date)
-values (uuid_generate_v4(), 'Harry Potter and the goblet of fire', 20, now(), now());
+CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
 
-insert into products (product_id, name, price, create_date, update_date)
-values (uuid_generate_v4(), 'Harry potter and the chamber of secrets', 10, now(), now());
+insert into products (product_id, name, price, currency, create_date, update_date)
+values (uuid_generate_v4(), 'Harry Potter and the goblet of fire', 20, 'EUR', now(), now());
 
-insert into accounts (account_id, amount, update_date)
-values (uuid_generate_v4(), 10000, now());
\ No newline at end of file
+insert into products (product_id, name, price, currency, create_date, update_date)
+values (uuid_generate_v4(), 'Harry potter and the chamber of secrets', 10, 'USD', now(), now());
+
+insert into accounts (account_id, amount, currency, update_date)
+values (uuid_generate_v4(), 10000, 'EUR', now());
+
+insert into fx_rates (fx_rate_id, base_currency, quote_currency, rate, effective_date, create_date)
+values (uuid_generate_v4(), 'USD', 'EUR', 0.92, now(), now());
+
+insert into fx_rates (fx_rate_id, base_currency, quote_currency, rate, effective_date, create_date)
+values (uuid_generate_v4(), 'EUR', 'USD', 1.09, now(), now());
+
+insert into stocks (product_id, quantity, update_date)
+select product_id, 100, now() from products;
@@ -0,0 +1,310 @@
+package e2e
+
+import (
+	"common/auth"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"order-service/dto/response"
+	orderModel "order-service/model"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	uuid "github.com/satori/go.uuid"
+)
+
+var authSecret = []byte("e2e-secret")
+
+// hs256Verifier verifies tokens signed with authSecret, read from a key file like the services do.
+func hs256Verifier(t *testing.T) *auth.Verifier {
+	t.Helper()
+	keyFile := filepath.Join(t.TempDir(), "jwt.key")
+	if err := os.WriteFile(keyFile, authSecret, 0o600); err != nil {
+		t.Fatalf("write key file: %v", err)
+	}
+	verifier, err := auth.NewVerifier(auth.Config{Algorithm: auth.AlgorithmHS256, KeyFile: keyFile, Issuer: "e2e"})
+	if err != nil {
+		t.Fatalf("verifier: %v", err)
+	}
+	return verifier
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, key interface{}, kid string, subject string, expiresIn time.Duration) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, jwt.RegisteredClaims{
+		Subject:   subject,
+		Issuer:    "e2e",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)),
+	})
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	return signed
+}
+
+// signStaff signs an HS256 token of subject with roles, valid for a minute.
+func signStaff(t *testing.T, subject string, roles ...string) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, auth.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   subject,
+			Issuer:    "e2e",
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		},
+		Roles: roles,
+	})
+	signed, err := token.SignedString(authSecret)
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	return signed
+}
+
+func TestOrdersAreOnlyAcceptedForOwnAccounts(t *testing.T) {
+	h := New(t, Options{Verifier: hs256Verifier(t)})
+	productId := h.SeedProduct(20, "EUR", 10)
+	ownAccount := h.SeedCustomerAccount("alice", 100, "EUR")
+	otherAccount := h.SeedCustomerAccount("bob", 100, "EUR")
+	token := sign(t, jwt.SigningMethodHS256, authSecret, "", "alice", time.Minute)
+
+	if status := h.PostJSON(h.OrderURL+"/api/order/create", orderRequest(uuid.NewV4().String(), ownAccount, productId, 1), nil); status != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without a token, got %d", status)
+	}
+	expired := sign(t, jwt.SigningMethodHS256, authSecret, "", "alice", -time.Minute)
+	if status := h.PostJSONWithToken(expired, h.OrderURL+"/api/order/create", orderRequest(uuid.NewV4().String(), ownAccount, productId, 1), nil); status != http.StatusUnauthorized {
+		t.Fatalf("expected 401 with an expired token, got %d", status)
+	}
+
+	foreignId := uuid.NewV4().String()
+	if status := h.PostJSONWithToken(token, h.OrderURL+"/api/order/create", orderRequest(foreignId, otherAccount, productId, 1), nil); status != http.StatusForbidden {
+		t.Fatalf("expected 403 for an account of another customer, got %d", status)
+	}
+	var orders int64
+	if err := h.OrderDB.Model(&orderModel.ProductOrder{}).Where("request_id = ?", foreignId).Count(&orders).Error; err != nil || orders != 0 {
+		t.Fatalf("expected no order for a foreign account, got %d (%v)", orders, err)
+	}
+
+	// the payment-service sees the forwarded token and rejects charging the other account directly as well
+	status := h.PostPayment(token, map[string]interface{}{
+		"requestId": uuid.NewV4().String(),
+		"uuid":      uuid.NewV4().String(),
+		"orderId":   uuid.NewV4().String(),
+		"amount":    20,
+		"currency":  "EUR",
+		"accountId": otherAccount,
+	})
+	if status != http.StatusForbidden {
+		t.Fatalf("expected the payment-service to answer 403, got %d", status)
+	}
+	if amount := h.Account(otherAccount).Amount; amount != 100 {
+		t.Fatalf("expected the other account untouched, got %v", amount)
+	}
+
+	status = h.PostJSONWithToken(token, h.OrderURL+"/api/order/create", orderRequest(uuid.NewV4().String(), ownAccount, productId, 2), nil)
+	if status != http.StatusOK {
+		t.Fatalf("expected 200 for an own account, got %d", status)
+	}
+	if amount := h.Account(ownAccount).Amount; amount != 60 {
+		t.Fatalf("expected account balance 60, got %v", amount)
+	}
+}
+
+func TestAsyncOrderForwardsClaimsToPayment(t *testing.T) {
+	h := New(t, Options{ProcessingMode: "async", Verifier: hs256Verifier(t)})
+	productId := h.SeedProduct(20, "EUR", 10)
+	accountId := h.SeedCustomerAccount("alice", 100, "EUR")
+	requestId := uuid.NewV4().String()
+	token := sign(t, jwt.SigningMethodHS256, authSecret, "", "alice", time.Minute)
+
+	if status := h.PostJSONWithToken(token, h.OrderURL+"/api/order/create", orderRequest(requestId, accountId, productId, 1), nil); status != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d", status)
+	}
+	h.Eventually(sagaTimeout, func() bool {
+		return h.Order(requestId).Status == orderModel.OrderStatusConfirmed
+	}, "order %s confirmed", requestId)
+	if amount := h.Account(accountId).Amount; amount != 80 {
+		t.Fatalf("expected account balance 80, got %v", amount)
+	}
+}
+
+func TestOrdersAreOnlyVisibleToTheirOwner(t *testing.T) {
+	h := New(t, Options{Verifier: hs256Verifier(t)})
+	productId := h.SeedProduct(20, "EUR", 10)
+	ownAccount := h.SeedCustomerAccount("alice", 100, "EUR")
+	otherAccount := h.SeedCustomerAccount("bob", 100, "EUR")
+	alice := sign(t, jwt.SigningMethodHS256, authSecret, "", "alice", time.Minute)
+	bob := sign(t, jwt.SigningMethodHS256, authSecret, "", "bob", time.Minute)
+	requestId := uuid.NewV4().String()
+
+	var order response.OrderResponse
+	if status := h.PostJSONWithToken(alice, h.OrderURL+"/api/order/create", orderRequest(requestId, ownAccount, productId, 1), &order); status != http.StatusOK {
+		t.Fatalf("expected 200 for an own account, got %d", status)
+	}
+
+	lookups := []string{
+		"/api/order/" + order.ProductOrderId,
+		"/api/order/" + order.ProductOrderId + "/status",
+		"/api/order/" + order.ProductOrderId + "/events",
+		"/api/order/by-request/" + requestId,
+	}
+	for _, path := range lookups {
+		if status := h.GetJSON(h.OrderURL+path, nil); status != http.StatusUnauthorized {
+			t.Fatalf("expected 401 for %s without a token, got %d", path, status)
+		}
+		// orders of other customers are not found, their ids can not be probed
+		if status := h.GetJSONWithToken(bob, h.OrderURL+path, nil); status != http.StatusNotFound {
+			t.Fatalf("expected 404 for %s of another customer, got %d", path, status)
+		}
+	}
+	var own response.OrderResponse
+	if status := h.GetJSONWithToken(alice, h.OrderURL+"/api/order/by-request/"+requestId, &own); status != http.StatusOK || own.ProductOrderId != order.ProductOrderId {
+		t.Fatalf("expected the own order, got %d with %+v", status, own)
+	}
+
+	if status := h.GetJSON(h.OrderURL+"/api/order?accountId="+ownAccount, nil); status != http.StatusUnauthorized {
+		t.Fatalf("expected 401 listing orders without a token, got %d", status)
+	}
+	if status := h.GetJSONWithToken(alice, h.OrderURL+"/api/order", nil); status != http.StatusBadRequest {
+		t.Fatalf("expected 400 listing orders without an account, got %d", status)
+	}
+	if status := h.GetJSONWithToken(alice, h.OrderURL+"/api/order?accountId="+otherAccount, nil); status != http.StatusForbidden {
+		t.Fatalf("expected 403 listing the orders of another customer, got %d", status)
+	}
+	var page response.OrderPageResponse
+	if status := h.GetJSONWithToken(alice, h.OrderURL+"/api/order?accountId="+ownAccount, &page); status != http.StatusOK || len(page.Items) != 1 {
+		t.Fatalf("expected the own order listed, got %d with %+v", status, page)
+	}
+}
+
+func TestReconciliationIsOnlyRunByAdmins(t *testing.T) {
+	h := New(t, Options{Verifier: hs256Verifier(t)})
+	customer := sign(t, jwt.SigningMethodHS256, authSecret, "", "alice", time.Minute)
+	support := signStaff(t, "carol", auth.RoleSupport)
+	admin := signStaff(t, "dave", auth.RoleAdmin)
+
+	routes := []struct {
+		method string
+		path   string
+	}{
+		{http.MethodPost, "/api/reconciliation/run"},
+		{http.MethodGet, "/api/reconciliation/findings"},
+		{http.MethodGet, "/api/reconciliation/findings/" + uuid.NewV4().String()},
+	}
+	call := func(token string, method string, path string) int {
+		if method == http.MethodPost {
+			return h.PostJSONWithToken(token, h.OrderURL+path, nil, nil)
+		}
+		return h.GetJSONWithToken(token, h.OrderURL+path, nil)
+	}
+	for _, route := range routes {
+		if status := call("", route.method, route.path); status != http.StatusUnauthorized {
+			t.Fatalf("expected 401 for %s %s without a token, got %d", route.method, route.path, status)
+		}
+		for _, token := range []string{customer, support} {
+			if status := call(token, route.method, route.path); status != http.StatusForbidden {
+				t.Fatalf("expected 403 for %s %s without the admin role, got %d", route.method, route.path, status)
+			}
+		}
+	}
+
+	var run response.ReconciliationRunResponse
+	if status := h.PostJSONWithToken(admin, h.OrderURL+"/api/reconciliation/run", nil, &run); status != http.StatusOK {
+		t.Fatalf("expected an admin to run the reconciliation, got %d", status)
+	}
+	var page response.FindingPageResponse
+	if status := h.GetJSONWithToken(admin, h.OrderURL+"/api/reconciliation/findings", &page); status != http.StatusOK {
+		t.Fatalf("expected an admin to list the findings, got %d", status)
+	}
+}
+
+func TestSupportLooksUpTransactionsOfARequest(t *testing.T) {
+	h := New(t, Options{Verifier: hs256Verifier(t)})
+	productId := h.SeedProduct(20, "EUR", 10)
+	accountId := h.SeedCustomerAccount("alice", 100, "EUR")
+	customer := sign(t, jwt.SigningMethodHS256, authSecret, "", "alice", time.Minute)
+	requestId := uuid.NewV4().String()
+
+	if status := h.PostJSONWithToken(customer, h.OrderURL+"/api/order/create", orderRequest(requestId, accountId, productId, 1), nil); status != http.StatusOK {
+		t.Fatalf("expected 200, got %d", status)
+	}
+	h.Eventually(sagaTimeout, func() bool {
+		return h.Order(requestId).Status == orderModel.OrderStatusConfirmed
+	}, "order %s confirmed", requestId)
+
+	path := h.PaymentURL + "/api/payment/transaction/by-request/" + requestId
+	if status := h.GetJSON(path, nil); status != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without a signature or token, got %d", status)
+	}
+	if status := h.GetJSONWithToken("garbage", path, nil); status != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for an invalid token, got %d", status)
+	}
+	if status := h.GetJSONWithToken(customer, path, nil); status != http.StatusForbidden {
+		t.Fatalf("expected 403 for a customer token, got %d", status)
+	}
+	for _, token := range []string{signStaff(t, "carol", auth.RoleSupport), signStaff(t, "dave", auth.RoleAdmin)} {
+		var transactions []map[string]interface{}
+		if status := h.GetJSONWithToken(token, path, &transactions); status != http.StatusOK || len(transactions) != 1 {
+			t.Fatalf("expected the charge of the request for staff, got %d with %v", status, transactions)
+		}
+	}
+}
+
+func TestRS256TokensAreVerifiedAgainstJWKS(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	jwks, err := json.Marshal(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "e2e-1",
+			"alg": auth.AlgorithmRS256,
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}},
+	})
+	if err != nil {
+		t.Fatalf("marshal jwks: %v", err)
+	}
+	jwksFile := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(jwksFile, jwks, 0o600); err != nil {
+		t.Fatalf("write jwks file: %v", err)
+	}
+	verifier, err := auth.NewVerifier(auth.Config{Algorithm: auth.AlgorithmRS256, JWKSFile: jwksFile})
+	if err != nil {
+		t.Fatalf("verifier: %v", err)
+	}
+
+	h := New(t, Options{Verifier: verifier})
+	productId := h.SeedProduct(20, "EUR", 10)
+	accountId := h.SeedCustomerAccount("alice", 100, "EUR")
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	forged := sign(t, jwt.SigningMethodRS256, otherKey, "e2e-1", "alice", time.Minute)
+	if status := h.PostJSONWithToken(forged, h.OrderURL+"/api/order/create", orderRequest(uuid.NewV4().String(), accountId, productId, 1), nil); status != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for a token of an unknown key, got %d", status)
+	}
+	hs256 := sign(t, jwt.SigningMethodHS256, authSecret, "e2e-1", "alice", time.Minute)
+	if status := h.PostJSONWithToken(hs256, h.OrderURL+"/api/order/create", orderRequest(uuid.NewV4().String(), accountId, productId, 1), nil); status != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for a token of another algorithm, got %d", status)
+	}
+
+	token := sign(t, jwt.SigningMethodRS256, key, "e2e-1", "alice", time.Minute)
+	if status := h.PostJSONWithToken(token, h.OrderURL+"/api/order/create", orderRequest(uuid.NewV4().String(), accountId, productId, 1), nil); status != http.StatusOK {
+		t.Fatalf("expected 200, got %d", status)
+	}
+}
@@ -0,0 +1,93 @@
+package e2e
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"testing"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// concurrent charges on one account may only take what is on it, the losers are declined or asked to retry.
+func TestConcurrentPaymentsDoNotOverdraw(t *testing.T) {
+	chargeConcurrently(t, New(t, Options{}))
+}
+
+// SQLite serializes all writers, only postgres lets the conditional updates of the charges race for the row.
+func TestConcurrentPaymentsDoNotOverdrawOnPostgres(t *testing.T) {
+	chargeConcurrently(t, New(t, Options{PaymentPostgresDSN: postgresDSN(t)}))
+}
+
+func chargeConcurrently(t *testing.T, h *Harness) {
+	t.Helper()
+	accountId := h.SeedAccount(100, "EUR")
+
+	const payments = 40
+	statuses := make(chan int, payments)
+	var wg sync.WaitGroup
+	for i := 0; i < payments; i++ {
+		body, err := json.Marshal(map[string]interface{}{
+			"requestId": uuid.NewV4().String(),
+			"uuid":      uuid.NewV4().String(),
+			"orderId":   uuid.NewV4().String(),
+			"amount":    10,
+			"currency":  "EUR",
+			"accountId": accountId,
+		})
+		if err != nil {
+			t.Fatalf("marshal request: %v", err)
+		}
+		req, err := http.NewRequest(http.MethodPost, h.PaymentURL+"/api/payment/process", bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("build request: %v", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if err := h.Signer.Sign(req, body); err != nil {
+			t.Fatalf("sign request: %v", err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				statuses <- 0
+				return
+			}
+			resp.Body.Close()
+			statuses <- resp.StatusCode
+		}()
+	}
+	wg.Wait()
+	close(statuses)
+
+	charged := 0
+	for status := range statuses {
+		switch status {
+		case http.StatusOK:
+			charged++
+		case http.StatusPaymentRequired, http.StatusServiceUnavailable:
+		default:
+			t.Errorf("expected 200, 402 or 503, got %d", status)
+		}
+	}
+	if charged > 10 {
+		t.Fatalf("expected at most 10 charges of 10 on 100, got %d", charged)
+	}
+
+	account := h.Account(accountId)
+	if account.Amount < 0 || account.Amount != 100-float64(10*charged) {
+		t.Fatalf("expected a balance of %d after %d charges, got %v", 100-10*charged, charged, account.Amount)
+	}
+	var transactions int64
+	if err := h.PaymentDB.Table("transactions").Where("account_id = ?", accountId).Count(&transactions).Error; err != nil {
+		t.Fatalf("count transactions: %v", err)
+	}
+	if transactions != int64(charged) {
+		t.Fatalf("expected %d transactions, got %d", charged, transactions)
+	}
+	if account.Version != int64(charged) {
+		t.Fatalf("expected version %d, got %d", charged, account.Version)
+	}
+}
@@ -0,0 +1,216 @@
+package e2e
+
+import (
+	"common/broker"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+)
+
+// startNats runs a JetStream enabled nats server on a free port for a single test.
+func startNats(t *testing.T) string {
+	t.Helper()
+	natsServer, err := server.NewServer(&server.Options{
+		Host:      "127.0.0.1",
+		Port:      server.RANDOM_PORT,
+		JetStream: true,
+		StoreDir:  t.TempDir(),
+		NoLog:     true,
+		NoSigs:    true,
+	})
+	if err != nil {
+		t.Fatalf("create nats server: %v", err)
+	}
+	go natsServer.Start()
+	t.Cleanup(natsServer.Shutdown)
+	if !natsServer.ReadyForConnections(5 * time.Second) {
+		t.Fatal("nats server did not start")
+	}
+	return natsServer.ClientURL()
+}
+
+// every broker has to keep the same contract, rabbitmq is only covered by the deployments.
+func TestBrokers(t *testing.T) {
+	brokers := map[string]func(t *testing.T) broker.Broker{
+		"memory": func(t *testing.T) broker.Broker {
+			return broker.NewMemoryBroker()
+		},
+		"nats": func(t *testing.T) broker.Broker {
+			natsBroker, err := broker.NewNatsBroker(startNats(t))
+			if err != nil {
+				t.Fatalf("connect to nats: %v", err)
+			}
+			t.Cleanup(func() { natsBroker.Close() })
+			return natsBroker
+		},
+	}
+	for name, newBroker := range brokers {
+		t.Run(name, func(t *testing.T) {
+			t.Run("publish", func(t *testing.T) { testBrokerPublish(t, newBroker(t)) })
+			t.Run("competing subscribers", func(t *testing.T) { testBrokerCompetingSubscribers(t, newBroker(t)) })
+			t.Run("nack", func(t *testing.T) { testBrokerNack(t, newBroker(t)) })
+			t.Run("resubscribe", func(t *testing.T) { testBrokerResubscribe(t, newBroker(t)) })
+		})
+	}
+}
+
+// subscribe collects the deliveries of a queue, handle decides what becomes of each of them.
+func subscribe(t *testing.T, ctx context.Context, b broker.Broker, queue string, handle func(broker.Delivery) error) <-chan broker.Message {
+	t.Helper()
+	received := make(chan broker.Message, 100)
+	err := b.Subscribe(ctx, queue, func(ctx context.Context, delivery broker.Delivery) {
+		if err := handle(delivery); err != nil {
+			t.Errorf("settle delivery: %v", err)
+		}
+		received <- delivery.Message
+	})
+	if err != nil {
+		t.Fatalf("subscribe to %s: %v", queue, err)
+	}
+	return received
+}
+
+func ack(delivery broker.Delivery) error {
+	return delivery.Ack()
+}
+
+func publish(t *testing.T, b broker.Broker, queue string, msg broker.Message) {
+	t.Helper()
+	if err := b.Publish(context.Background(), queue, msg); err != nil {
+		t.Fatalf("publish to %s: %v", queue, err)
+	}
+}
+
+func receive(t *testing.T, received <-chan broker.Message) broker.Message {
+	t.Helper()
+	select {
+	case msg := <-received:
+		return msg
+	case <-time.After(sagaTimeout):
+		t.Fatal("expected a delivery")
+	}
+	return broker.Message{}
+}
+
+func expectNothing(t *testing.T, received <-chan broker.Message) {
+	t.Helper()
+	select {
+	case msg := <-received:
+		t.Fatalf("expected no delivery, got %s", msg.Body)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func testBrokerPublish(t *testing.T, b broker.Broker) {
+	// declaring is repeated by every service on start
+	for i := 0; i < 2; i++ {
+		if err := b.Declare("orders", "payments"); err != nil {
+			t.Fatalf("declare: %v", err)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	orders := subscribe(t, ctx, b, "orders", ack)
+	payments := subscribe(t, ctx, b, "payments", ack)
+
+	publish(t, b, "orders", broker.Message{Body: []byte(`{"id":1}`), Headers: map[string]string{broker.HeaderOriginalQueue: "payments"}})
+
+	msg := receive(t, orders)
+	if string(msg.Body) != `{"id":1}` || msg.Headers[broker.HeaderOriginalQueue] != "payments" || msg.Redelivered {
+		t.Fatalf("expected the message with its headers, got %+v", msg)
+	}
+	expectNothing(t, payments)
+	expectNothing(t, orders)
+}
+
+// replicas share a queue, every message is handled by one of them.
+func testBrokerCompetingSubscribers(t *testing.T, b broker.Broker) {
+	if err := b.Declare("orders"); err != nil {
+		t.Fatalf("declare: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	received := make(chan broker.Message, 100)
+	for subscriber := 0; subscriber < 2; subscriber++ {
+		err := b.Subscribe(ctx, "orders", func(ctx context.Context, delivery broker.Delivery) {
+			if err := delivery.Ack(); err != nil {
+				t.Errorf("ack: %v", err)
+			}
+			received <- delivery.Message
+		})
+		if err != nil {
+			t.Fatalf("subscribe: %v", err)
+		}
+	}
+
+	const messages = 20
+	for i := 0; i < messages; i++ {
+		publish(t, b, "orders", broker.Message{Body: []byte(fmt.Sprint(i))})
+	}
+	seen := map[string]bool{}
+	for i := 0; i < messages; i++ {
+		body := string(receive(t, received).Body)
+		if seen[body] {
+			t.Fatalf("expected message %s to be handled once", body)
+		}
+		seen[body] = true
+	}
+	expectNothing(t, received)
+}
+
+// a requeued message is handed out again flagged redelivered, a rejected one is dropped.
+func testBrokerNack(t *testing.T, b broker.Broker) {
+	if err := b.Declare("rollbacks"); err != nil {
+		t.Fatalf("declare: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	received := subscribe(t, ctx, b, "rollbacks", func(delivery broker.Delivery) error {
+		switch {
+		case string(delivery.Body) == "dropped":
+			return delivery.Nack(false)
+		case !delivery.Redelivered:
+			return delivery.Nack(true)
+		}
+		return delivery.Ack()
+	})
+
+	publish(t, b, "rollbacks", broker.Message{Body: []byte("retried"), Headers: map[string]string{"attempt": "1"}})
+	if msg := receive(t, received); msg.Redelivered {
+		t.Fatalf("expected the first delivery not to be flagged redelivered, got %+v", msg)
+	}
+	if msg := receive(t, received); string(msg.Body) != "retried" || !msg.Redelivered || msg.Headers["attempt"] != "1" {
+		t.Fatalf("expected the message to be redelivered with its headers, got %+v", msg)
+	}
+
+	publish(t, b, "rollbacks", broker.Message{Body: []byte("dropped")})
+	receive(t, received)
+	expectNothing(t, received)
+}
+
+// messages published while nobody listens wait for the next subscriber of the queue.
+func testBrokerResubscribe(t *testing.T, b broker.Broker) {
+	if err := b.Declare("expired"); err != nil {
+		t.Fatalf("declare: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	first := subscribe(t, ctx, b, "expired", ack)
+	publish(t, b, "expired", broker.Message{Body: []byte("first")})
+	receive(t, first)
+	cancel()
+	// the stopped subscription is given a moment to leave before the next message arrives
+	time.Sleep(100 * time.Millisecond)
+
+	publish(t, b, "expired", broker.Message{Body: []byte("second")})
+	expectNothing(t, first)
+
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	second := subscribe(t, ctx, b, "expired", ack)
+	if msg := receive(t, second); string(msg.Body) != "second" {
+		t.Fatalf("expected the waiting message, got %s", msg.Body)
+	}
+}
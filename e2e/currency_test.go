@@ -0,0 +1,79 @@
+package e2e
+
+import (
+	"net/http"
+	paymentModel "payment-service/model"
+	"testing"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+func payIn(t *testing.T, h *Harness, accountId string, amount float64, currency string) paymentModel.Transaction {
+	t.Helper()
+	requestId := uuid.NewV4().String()
+	status := h.PostPayment("", map[string]interface{}{
+		"requestId": requestId,
+		"uuid":      uuid.NewV4().String(),
+		"orderId":   uuid.NewV4().String(),
+		"amount":    amount,
+		"currency":  currency,
+		"accountId": accountId,
+	})
+	if status != http.StatusOK {
+		t.Fatalf("expected the payment of %v %s to succeed, got %d", amount, currency, status)
+	}
+	transactions := h.Transactions(requestId)
+	if len(transactions) != 1 {
+		t.Fatalf("expected a single transaction, got %+v", transactions)
+	}
+	return transactions[0]
+}
+
+// payments in another currency are converted into the account currency, the transaction keeps what was asked for.
+func TestPaymentInAnotherCurrencyIsConverted(t *testing.T) {
+	h := New(t, Options{})
+	h.SeedFxRate("USD", "EUR", 0.9)
+	accountId := h.SeedAccount(100, "EUR")
+
+	transaction := payIn(t, h, accountId, 20, "USD")
+
+	if transaction.Amount != 18 || transaction.Currency != "EUR" {
+		t.Fatalf("expected 18 EUR to be charged, got %v %s", transaction.Amount, transaction.Currency)
+	}
+	if transaction.OriginalAmount != 20 || transaction.OriginalCurrency != "USD" || transaction.FxRate != 0.9 {
+		t.Fatalf("expected 20 USD at 0.9, got %v %s at %v", transaction.OriginalAmount, transaction.OriginalCurrency, transaction.FxRate)
+	}
+	if amount := h.Account(accountId).Amount; amount != 82 {
+		t.Fatalf("expected 82 EUR left, got %v", amount)
+	}
+}
+
+// without a rate for the pair the inverse of the opposite pair is applied.
+func TestPaymentIsConvertedWithTheInverseRate(t *testing.T) {
+	h := New(t, Options{})
+	h.SeedFxRate("USD", "EUR", 0.8)
+	accountId := h.SeedAccount(100, "USD")
+
+	transaction := payIn(t, h, accountId, 10, "EUR")
+
+	if transaction.Amount != 12.5 || transaction.Currency != "USD" {
+		t.Fatalf("expected 12.5 USD to be charged, got %v %s", transaction.Amount, transaction.Currency)
+	}
+	if transaction.OriginalAmount != 10 || transaction.OriginalCurrency != "EUR" || transaction.FxRate != 1.25 {
+		t.Fatalf("expected 10 EUR at 1.25, got %v %s at %v", transaction.OriginalAmount, transaction.OriginalCurrency, transaction.FxRate)
+	}
+	if amount := h.Account(accountId).Amount; amount != 87.5 {
+		t.Fatalf("expected 87.5 USD left, got %v", amount)
+	}
+}
+
+func TestPaymentInTheAccountCurrencyIsNotConverted(t *testing.T) {
+	h := New(t, Options{})
+	accountId := h.SeedAccount(100, "EUR")
+
+	transaction := payIn(t, h, accountId, 30, "EUR")
+
+	if transaction.Amount != 30 || transaction.OriginalAmount != 30 || transaction.OriginalCurrency != "EUR" || transaction.FxRate != 1 {
+		t.Fatalf("expected 30 EUR at 1, got %+v", transaction)
+	}
+}
@@ -0,0 +1,140 @@
+package e2e
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"order-service/event"
+	orderModel "order-service/model"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+type streamedEvent struct {
+	name  string
+	order event.OrderEvent
+}
+
+// openStream returns once the first status was sent, the stream is subscribed to the transitions of the order then.
+func openStream(t *testing.T, h *Harness, orderId string) *http.Response {
+	t.Helper()
+	client := http.Client{Timeout: sagaTimeout}
+	resp, err := client.Get(h.OrderURL + "/api/order/" + orderId + "/events")
+	if err != nil {
+		t.Fatalf("stream events of %s: %v", orderId, err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		t.Fatalf("expected an event stream, got %d with %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	return resp
+}
+
+// readEvents reads the status events of a stream until the service ends it, heartbeats are skipped.
+func readEvents(t *testing.T, resp *http.Response) []streamedEvent {
+	t.Helper()
+	var events []streamedEvent
+	var current streamedEvent
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event:"):
+			current.name = strings.TrimPrefix(line, "event:")
+		case strings.HasPrefix(line, "data:") && current.name == "status":
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data:")), &current.order); err != nil {
+				t.Fatalf("decode event %q: %v", line, err)
+			}
+		case line == "":
+			if current.name == "status" {
+				events = append(events, current)
+			}
+			current = streamedEvent{}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("read events: %v", err)
+	}
+	return events
+}
+
+// expectStatuses checks the statuses streamed for an order, every event carries the order and a timestamp.
+func expectStatuses(t *testing.T, events []streamedEvent, orderId string, requestId string, statuses ...string) {
+	t.Helper()
+	streamed := []string{}
+	for _, streamedEvent := range events {
+		streamed = append(streamed, streamedEvent.order.Status)
+		if streamedEvent.order.ProductOrderId != orderId || streamedEvent.order.RequestId != requestId || streamedEvent.order.Timestamp.IsZero() {
+			t.Fatalf("expected an event of order %s of %s, got %+v", orderId, requestId, streamedEvent.order)
+		}
+	}
+	if !reflect.DeepEqual(streamed, statuses) {
+		t.Fatalf("expected statuses %v, got %v", statuses, streamed)
+	}
+}
+
+// the stream of a finished order only tells its status and ends.
+func TestEventStreamOfAFinishedOrderEnds(t *testing.T) {
+	h := New(t, Options{})
+	productId := h.SeedProduct(20, "EUR", 10)
+	accountId := h.SeedAccount(100, "EUR")
+	requestId := uuid.NewV4().String()
+	status, order := h.CreateOrder(orderRequest(requestId, accountId, productId, 1))
+	if status != http.StatusOK {
+		t.Fatalf("expected the order to be confirmed, got %d", status)
+	}
+
+	events := readEvents(t, openStream(t, h, order.ProductOrderId))
+
+	expectStatuses(t, events, order.ProductOrderId, requestId, orderModel.OrderStatusConfirmed)
+}
+
+// transitions published while a client listens are streamed until the order reaches a final status.
+func TestEventStreamFollowsTheOrder(t *testing.T) {
+	h := New(t, Options{})
+	orderId := uuid.NewV4().String()
+	requestId := uuid.NewV4().String()
+	now := time.Now().UTC()
+	h.create(h.OrderDB, &orderModel.ProductOrder{
+		ProductOrderId: orderId,
+		AccountId:      uuid.NewV4().String(),
+		Status:         orderModel.OrderStatusPending,
+		TotalAmount:    20,
+		Currency:       "EUR",
+		CreateDate:     now,
+		UpdateDate:     now,
+		RequestId:      requestId,
+	})
+
+	stream := openStream(t, h, orderId)
+	channels := h.Redis.PubSubChannels("*:" + orderId)
+	if len(channels) != 1 {
+		t.Fatalf("expected the stream to listen on the channel of %s, got %v", orderId, channels)
+	}
+	for _, status := range []string{orderModel.OrderStatusPaymentAccepted, orderModel.OrderStatusConfirmed} {
+		data, err := json.Marshal(event.OrderEvent{ProductOrderId: orderId, RequestId: requestId, Status: status, Timestamp: time.Now().UTC()})
+		if err != nil {
+			t.Fatalf("marshal event: %v", err)
+		}
+		h.Redis.Publish(channels[0], string(data))
+	}
+
+	expectStatuses(t, readEvents(t, stream), orderId, requestId,
+		orderModel.OrderStatusPending, orderModel.OrderStatusPaymentAccepted, orderModel.OrderStatusConfirmed)
+}
+
+func TestEventStreamOfAnUnknownOrderIsNotFound(t *testing.T) {
+	h := New(t, Options{})
+	resp, err := http.Get(h.OrderURL + "/api/order/" + uuid.NewV4().String() + "/events")
+	if err != nil {
+		t.Fatalf("stream events: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound || !strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+		t.Fatalf("expected 404 with a json error, got %d with %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+}
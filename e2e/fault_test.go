@@ -0,0 +1,340 @@
+//go:build faults
+
+package e2e
+
+import (
+	"common/broker"
+	"common/fault"
+	"common/statestore"
+	"context"
+	"errors"
+	"net/http"
+	orderModel "order-service/model"
+	paymentModel "payment-service/model"
+	"testing"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// arm sets faults for a single test, the registry is process wide so every test starts and ends clean.
+func arm(t *testing.T, spec string) {
+	t.Helper()
+	fault.Reset()
+	t.Cleanup(fault.Reset)
+	if err := fault.Configure(spec); err != nil {
+		t.Fatalf("arm faults %q: %v", spec, err)
+	}
+}
+
+// the payment is charged but its response never reaches the order-service, the orchestrator has to refund it.
+func TestLostPaymentResponseIsRefunded(t *testing.T) {
+	h := New(t, Options{})
+	arm(t, fault.PaymentClientProcess+"="+fault.KindLostResponse+"*1")
+	productId := h.SeedProduct(20, "EUR", 10)
+	accountId := h.SeedAccount(100, "EUR")
+	requestId := uuid.NewV4().String()
+
+	status, _ := h.CreateOrder(orderRequest(requestId, accountId, productId, 1))
+
+	if status == http.StatusOK {
+		t.Fatal("expected the order to fail")
+	}
+	h.Eventually(sagaTimeout, func() bool {
+		return h.Order(requestId).Status == orderModel.OrderStatusCompensated
+	}, "order %s compensated", requestId)
+	h.Eventually(sagaTimeout, func() bool {
+		return h.Account(accountId).Amount == 100
+	}, "account %s refunded", accountId)
+	h.Eventually(sagaTimeout, func() bool {
+		return h.Stock(productId) == 10
+	}, "stock of %s released", productId)
+	transactions := h.Transactions(requestId)
+	if len(transactions) != 2 || transactions[1].Type != paymentModel.TransactionTypeRefund {
+		t.Fatalf("expected a charge and a refund, got %+v", transactions)
+	}
+}
+
+func TestPaymentServerErrorCompensatesOrder(t *testing.T) {
+	h := New(t, Options{})
+	arm(t, fault.PaymentClientProcess+"="+fault.KindStatus+":500*1")
+	productId := h.SeedProduct(20, "EUR", 10)
+	accountId := h.SeedAccount(100, "EUR")
+	requestId := uuid.NewV4().String()
+
+	status, _ := h.CreateOrder(orderRequest(requestId, accountId, productId, 1))
+
+	if status == http.StatusOK {
+		t.Fatal("expected the order to fail")
+	}
+	h.Eventually(sagaTimeout, func() bool {
+		return h.Order(requestId).Status == orderModel.OrderStatusCompensated
+	}, "order %s compensated", requestId)
+	h.Eventually(sagaTimeout, func() bool {
+		return h.Stock(productId) == 10
+	}, "stock of %s released", productId)
+	if transactions := h.Transactions(requestId); len(transactions) != 0 {
+		t.Fatalf("expected no transactions, got %+v", transactions)
+	}
+}
+
+func TestPaymentTimeoutCompensatesOrder(t *testing.T) {
+	h := New(t, Options{})
+	arm(t, fault.PaymentClientProcess+"="+fault.KindTimeout+":100ms*1")
+	productId := h.SeedProduct(20, "EUR", 10)
+	accountId := h.SeedAccount(100, "EUR")
+	requestId := uuid.NewV4().String()
+
+	status, _ := h.CreateOrder(orderRequest(requestId, accountId, productId, 1))
+
+	if status == http.StatusOK {
+		t.Fatal("expected the order to fail")
+	}
+	h.Eventually(sagaTimeout, func() bool {
+		return h.Order(requestId).Status == orderModel.OrderStatusCompensated
+	}, "order %s compensated", requestId)
+	if amount := h.Account(accountId).Amount; amount != 100 {
+		t.Fatalf("expected account balance 100, got %v", amount)
+	}
+}
+
+// the order never commits, the orchestration it started expires and is cleaned up without touching the account.
+func TestFailedOrderCommitLeavesNothingBehind(t *testing.T) {
+	h := New(t, Options{OrchestrationExpiration: time.Second})
+	arm(t, fault.OrderCommitBefore+"="+fault.KindError+"*1")
+	productId := h.SeedProduct(20, "EUR", 10)
+	accountId := h.SeedAccount(100, "EUR")
+	requestId := uuid.NewV4().String()
+
+	status, _ := h.CreateOrder(orderRequest(requestId, accountId, productId, 1))
+
+	if status == http.StatusOK {
+		t.Fatal("expected the order to fail")
+	}
+	var orders int64
+	h.OrderDB.Model(&orderModel.ProductOrder{}).Where("request_id = ?", requestId).Count(&orders)
+	if orders != 0 {
+		t.Fatalf("expected no order, got %d", orders)
+	}
+	h.Eventually(sagaTimeout, func() bool {
+		_, err := h.Store.Get(context.Background(), requestId)
+		return errors.Is(err, statestore.ErrNotFound)
+	}, "orchestration %s removed", requestId)
+	if amount := h.Account(accountId).Amount; amount != 100 {
+		t.Fatalf("expected account balance 100, got %v", amount)
+	}
+	if stock := h.Stock(productId); stock != 10 {
+		t.Fatalf("expected stock 10, got %d", stock)
+	}
+}
+
+// the orchestrator fails after publishing, the record expires again and the rollback is replayed without a second refund.
+func TestOrchestratorFailureBeforeDeleteReplaysRollback(t *testing.T) {
+	h := New(t, Options{OrchestrationExpiration: time.Second})
+	arm(t, fault.OrchestratorRollbackDelete+"="+fault.KindError+"*1")
+	productId := h.SeedProduct(20, "EUR", 10)
+	accountId := h.SeedAccount(100, "EUR")
+	requestId := uuid.NewV4().String()
+
+	// arm the lost response through the admin endpoint, next to the config armed fault
+	status := h.PostJSON(h.OrderURL+"/api/admin/faults", map[string]interface{}{
+		"point":     fault.PaymentClientProcess,
+		"kind":      fault.KindLostResponse,
+		"remaining": 1,
+	}, nil)
+	if status != http.StatusOK {
+		t.Fatalf("expected the admin endpoint to arm the fault, got %d", status)
+	}
+
+	status, _ = h.CreateOrder(orderRequest(requestId, accountId, productId, 1))
+
+	if status == http.StatusOK {
+		t.Fatal("expected the order to fail")
+	}
+	h.Eventually(3*sagaTimeout, func() bool {
+		_, err := h.Store.Get(context.Background(), requestId)
+		return errors.Is(err, statestore.ErrNotFound)
+	}, "orchestration %s removed", requestId)
+	if order := h.Order(requestId); order.Status != orderModel.OrderStatusCompensated {
+		t.Fatalf("expected compensated order, got %s", order.Status)
+	}
+	if amount := h.Account(accountId).Amount; amount != 100 {
+		t.Fatalf("expected account balance 100, got %v", amount)
+	}
+	if transactions := h.Transactions(requestId); len(transactions) != 2 {
+		t.Fatalf("expected a single charge and refund, got %+v", transactions)
+	}
+	if stock := h.Stock(productId); stock != 10 {
+		t.Fatalf("expected stock 10, got %d", stock)
+	}
+}
+
+// a compensation failing once is requeued and applied on its redelivery, on each of the services.
+func TestFailedRollbackIsRedelivered(t *testing.T) {
+	for _, point := range []string{fault.OrderRollback, fault.PaymentRollback, fault.InventoryRollback} {
+		t.Run(point, func(t *testing.T) {
+			h := New(t, Options{})
+			arm(t, fault.PaymentClientProcess+"="+fault.KindLostResponse+"*1,"+point+"="+fault.KindError+"*1")
+			productId := h.SeedProduct(20, "EUR", 10)
+			accountId := h.SeedAccount(100, "EUR")
+			requestId := uuid.NewV4().String()
+
+			if status, _ := h.CreateOrder(orderRequest(requestId, accountId, productId, 1)); status == http.StatusOK {
+				t.Fatal("expected the order to fail")
+			}
+			h.Eventually(sagaTimeout, func() bool {
+				return h.Order(requestId).Status == orderModel.OrderStatusCompensated
+			}, "order %s compensated", requestId)
+			h.Eventually(sagaTimeout, func() bool {
+				return h.Account(accountId).Amount == 100
+			}, "account %s refunded", accountId)
+			h.Eventually(sagaTimeout, func() bool {
+				return h.Stock(productId) == 10
+			}, "stock of %s released", productId)
+		})
+	}
+}
+
+// a compensation failing on its redelivery as well is parked in the dead-letter queue and applied once replayed.
+func TestRollbackFailingTwiceIsDeadLettered(t *testing.T) {
+	h := New(t, Options{})
+	arm(t, fault.PaymentClientProcess+"="+fault.KindLostResponse+"*1,"+fault.PaymentRollback+"="+fault.KindError+"*2")
+	deadLettered := make(chan broker.Message, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	err := h.Broker.Subscribe(ctx, deadLetterQueue, func(ctx context.Context, delivery broker.Delivery) {
+		deadLettered <- delivery.Message
+		delivery.Ack()
+	})
+	if err != nil {
+		t.Fatalf("subscribe to dead-letter: %v", err)
+	}
+	productId := h.SeedProduct(20, "EUR", 10)
+	accountId := h.SeedAccount(100, "EUR")
+	requestId := uuid.NewV4().String()
+
+	if status, _ := h.CreateOrder(orderRequest(requestId, accountId, productId, 1)); status == http.StatusOK {
+		t.Fatal("expected the order to fail")
+	}
+	var parked broker.Message
+	select {
+	case parked = <-deadLettered:
+	case <-time.After(sagaTimeout):
+		t.Fatalf("expected the payment rollback of %s to be dead-lettered", requestId)
+	}
+	if string(parked.Body) != requestId || parked.Headers[broker.HeaderOriginalQueue] != rollbackPaymentQueue {
+		t.Fatalf("expected %s from %s, got %s with %v", requestId, rollbackPaymentQueue, string(parked.Body), parked.Headers)
+	}
+	if amount := h.Account(accountId).Amount; amount != 80 {
+		t.Fatalf("expected the charge to stand until the replay, got a balance of %v", amount)
+	}
+
+	// replayed like orchestrationctl does, with the signature headers it was parked with
+	if err := h.Broker.Publish(context.Background(), rollbackPaymentQueue, broker.Message{Body: parked.Body, Headers: parked.Headers}); err != nil {
+		t.Fatalf("replay rollback: %v", err)
+	}
+	h.Eventually(sagaTimeout, func() bool {
+		return h.Account(accountId).Amount == 100
+	}, "account %s refunded", accountId)
+}
+
+// a duplicate arriving while the first request is still charging is refused, it has not got an outcome to replay yet.
+func TestDuplicateOfAnOrderInProgressIsRefused(t *testing.T) {
+	h := New(t, Options{})
+	arm(t, fault.PaymentClientProcess+"="+fault.KindDelay+":500ms*1")
+	productId := h.SeedProduct(20, "EUR", 10)
+	accountId := h.SeedAccount(100, "EUR")
+	requestId := uuid.NewV4().String()
+
+	first := make(chan int, 1)
+	go func() {
+		status, _ := h.CreateOrder(orderRequest(requestId, accountId, productId, 1))
+		first <- status
+	}()
+	h.Eventually(sagaTimeout, func() bool {
+		return countOrders(t, h, requestId) == 1
+	}, "order %s persisted", requestId)
+
+	if status, _ := h.CreateOrder(orderRequest(requestId, accountId, productId, 1)); status != http.StatusConflict {
+		t.Fatalf("expected 409 while the first request is in progress, got %d", status)
+	}
+	if status := <-first; status != http.StatusOK {
+		t.Fatalf("expected the first request to succeed, got %d", status)
+	}
+	if status, _ := h.CreateOrder(orderRequest(requestId, accountId, productId, 1)); status != http.StatusOK {
+		t.Fatalf("expected the outcome replayed once the first request finished, got %d", status)
+	}
+	if amount := h.Account(accountId).Amount; amount != 80 {
+		t.Fatalf("expected a single charge leaving 80, got %v", amount)
+	}
+}
+
+// an order turned away by a full worker queue is not persisted, so the same request id goes through once there is room.
+func TestOrderRejectedByAFullQueueCanBeRetried(t *testing.T) {
+	h := New(t, Options{ProcessingMode: "async", OrderWorkerCount: 1, OrderWorkerQueueSize: 1})
+	arm(t, fault.PaymentClientProcess+"="+fault.KindDelay+":500ms*1")
+	productId := h.SeedProduct(20, "EUR", 10)
+	accountId := h.SeedAccount(100, "EUR")
+
+	busy := uuid.NewV4().String()
+	if status, _ := h.CreateOrder(orderRequest(busy, accountId, productId, 1)); status != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d", status)
+	}
+	// the worker reserves the stock of the first order and then hangs in its payment
+	h.Eventually(sagaTimeout, func() bool {
+		return h.Stock(productId) == 9
+	}, "order %s picked up", busy)
+	if status, _ := h.CreateOrder(orderRequest(uuid.NewV4().String(), accountId, productId, 1)); status != http.StatusAccepted {
+		t.Fatalf("expected the queued order to be accepted, got %d", status)
+	}
+
+	requestId := uuid.NewV4().String()
+	if status, _ := h.CreateOrder(orderRequest(requestId, accountId, productId, 1)); status != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 with a full queue, got %d", status)
+	}
+	if orders := countOrders(t, h, requestId); orders != 0 {
+		t.Fatalf("expected the rejected order not to be persisted, got %d", orders)
+	}
+	h.Eventually(sagaTimeout, func() bool {
+		status, _ := h.CreateOrder(orderRequest(requestId, accountId, productId, 1))
+		return status == http.StatusAccepted
+	}, "order %s accepted on retry", requestId)
+	h.Eventually(sagaTimeout, func() bool {
+		return h.Order(requestId).Status == orderModel.OrderStatusConfirmed
+	}, "order %s confirmed", requestId)
+}
+
+// the payment hangs long enough for the stream to follow every transition the workers make.
+func TestEventStreamFollowsAConfirmedOrder(t *testing.T) {
+	h := New(t, Options{ProcessingMode: "async"})
+	arm(t, fault.PaymentClientProcess+"="+fault.KindDelay+":500ms*1")
+	productId := h.SeedProduct(20, "EUR", 10)
+	accountId := h.SeedAccount(100, "EUR")
+	requestId := uuid.NewV4().String()
+	status, order := h.CreateOrder(orderRequest(requestId, accountId, productId, 1))
+	if status != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d", status)
+	}
+
+	events := readEvents(t, openStream(t, h, order.ProductOrderId))
+
+	expectStatuses(t, events, order.ProductOrderId, requestId,
+		orderModel.OrderStatusPending, orderModel.OrderStatusPaymentAccepted, orderModel.OrderStatusConfirmed)
+}
+
+func TestEventStreamFollowsACompensatedOrder(t *testing.T) {
+	h := New(t, Options{ProcessingMode: "async"})
+	arm(t, fault.PaymentClientProcess+"="+fault.KindDelay+":500ms*1")
+	productId := h.SeedProduct(20, "EUR", 10)
+	accountId := h.SeedAccount(10, "EUR")
+	requestId := uuid.NewV4().String()
+	status, order := h.CreateOrder(orderRequest(requestId, accountId, productId, 1))
+	if status != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d", status)
+	}
+
+	events := readEvents(t, openStream(t, h, order.ProductOrderId))
+
+	expectStatuses(t, events, order.ProductOrderId, requestId,
+		orderModel.OrderStatusPending, orderModel.OrderStatusRollingBack, orderModel.OrderStatusCompensated)
+}
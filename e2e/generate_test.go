@@ -0,0 +1,59 @@
+package e2e
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/oapi-codegen/oapi-codegen/v2/pkg/codegen"
+	"github.com/oapi-codegen/oapi-codegen/v2/pkg/util"
+	"gopkg.in/yaml.v2"
+)
+
+// the payment client of the order-service is generated from the payment-service spec, regenerating it with the
+// oapi-codegen version of its go:generate directive has to reproduce the committed file.
+func TestPaymentClientIsGeneratedFromTheSpec(t *testing.T) {
+	const clientDir = "../order-service/client/paymentapi/"
+	data, err := os.ReadFile(clientDir + "config.yaml")
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	var config struct {
+		codegen.Configuration `yaml:",inline"`
+		Output                string `yaml:"output"`
+	}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		t.Fatalf("parse config: %v", err)
+	}
+	config.Configuration = config.Configuration.UpdateDefaults()
+	if err := config.Configuration.Validate(); err != nil {
+		t.Fatalf("invalid config: %v", err)
+	}
+
+	spec, err := util.LoadSwagger("../payment-service/openapi/openapi.yaml")
+	if err != nil {
+		t.Fatalf("load spec: %v", err)
+	}
+	generated, err := codegen.Generate(spec, config.Configuration)
+	if err != nil {
+		t.Fatalf("generate client: %v", err)
+	}
+	committed, err := os.ReadFile(clientDir + config.Output)
+	if err != nil {
+		t.Fatalf("read client: %v", err)
+	}
+	if withoutGenerator(generated) != withoutGenerator(string(committed)) {
+		t.Fatalf("%s drifted from the payment-service spec, run go generate ./client/paymentapi in order-service", config.Output)
+	}
+}
+
+// withoutGenerator drops the header naming the generator, which names the test binary when run from here.
+func withoutGenerator(code string) string {
+	lines := strings.Split(code, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, "// Code generated by ") {
+			return strings.Join(append(lines[:i:i], lines[i+1:]...), "\n")
+		}
+	}
+	return code
+}
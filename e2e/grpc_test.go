@@ -0,0 +1,205 @@
+package e2e
+
+import (
+	"common/paymentpb"
+	"context"
+	"net/http"
+	"order-service/client"
+	orderModel "order-service/model"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	uuid "github.com/satori/go.uuid"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// expectStatus expects err to carry code and, when reason is set, an ErrorInfo with it.
+func expectStatus(t *testing.T, err error, code codes.Code, reason string) *status.Status {
+	t.Helper()
+	callStatus := status.Convert(err)
+	if callStatus.Code() != code {
+		t.Fatalf("expected %s, got %s: %s", code, callStatus.Code(), callStatus.Message())
+	}
+	if reason == "" {
+		return callStatus
+	}
+	for _, detail := range callStatus.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok && info.Reason == reason {
+			return callStatus
+		}
+	}
+	t.Fatalf("expected %s with reason %s, got details %v", code, reason, callStatus.Details())
+	return nil
+}
+
+func callContext(t *testing.T) context.Context {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), sagaTimeout)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func TestOrdersArePaidOverGrpc(t *testing.T) {
+	h := New(t, Options{PaymentTransport: client.TransportGRPC})
+	productId := h.SeedProduct(20, "EUR", 10)
+	accountId := h.SeedAccount(50, "EUR")
+	requestId := uuid.NewV4().String()
+
+	status, order := h.CreateOrder(orderRequest(requestId, accountId, productId, 2))
+
+	if status != http.StatusOK || order.Status != orderModel.OrderStatusConfirmed {
+		t.Fatalf("expected a confirmed order, got %d with %s", status, order.Status)
+	}
+	if amount := h.Account(accountId).Amount; amount != 10 {
+		t.Fatalf("expected account balance 10, got %v", amount)
+	}
+
+	// the insufficient balance comes back as FAILED_PRECONDITION and declines the order like a 402 does
+	declinedId := uuid.NewV4().String()
+	if status, _ := h.CreateOrder(orderRequest(declinedId, accountId, productId, 1)); status != http.StatusBadRequest {
+		t.Fatalf("expected the order to be declined, got %d", status)
+	}
+	h.Eventually(sagaTimeout, func() bool {
+		return h.Order(declinedId).Status == orderModel.OrderStatusCompensated
+	}, "order %s compensated", declinedId)
+
+	// the reconciliation pages through the transactions over gRPC as well
+	if run := h.Reconcile(); len(run.Findings) != 0 {
+		t.Fatalf("expected no findings, got %+v", run.Findings)
+	}
+}
+
+func TestGrpcPaymentCallsAreSignedAndReportRichStatuses(t *testing.T) {
+	h := New(t, Options{})
+	api := h.PaymentGrpcClient(h.Signer)
+	accountId := h.SeedAccount(100, "EUR")
+	requestId := uuid.NewV4().String()
+	payment := &paymentpb.ProcessPaymentRequest{
+		RequestId: requestId,
+		Uuid:      uuid.NewV4().String(),
+		OrderId:   uuid.NewV4().String(),
+		AccountId: accountId,
+		Amount:    30,
+		Currency:  "EUR",
+	}
+
+	_, err := h.PaymentGrpcClient(nil).ProcessPayment(callContext(t), payment)
+	expectStatus(t, err, codes.Unauthenticated, "")
+
+	_, err = api.ProcessPayment(callContext(t), &paymentpb.ProcessPaymentRequest{RequestId: "x", AccountId: accountId, Amount: -5, Currency: "EURO"})
+	invalid := expectStatus(t, err, codes.InvalidArgument, "")
+	violations := map[string]string{}
+	for _, detail := range invalid.Details() {
+		if badRequest, ok := detail.(*errdetails.BadRequest); ok {
+			for _, violation := range badRequest.FieldViolations {
+				violations[violation.Field] = violation.Reason
+			}
+		}
+	}
+	expectRules(t, violations, map[string]string{"requestId": "UUID", "uuid": "REQUIRED", "orderId": "REQUIRED", "amount": "GT", "currency": "ISO4217"})
+
+	charged, err := api.ProcessPayment(callContext(t), payment)
+	if err != nil {
+		t.Fatalf("expected the payment to succeed, got %v", err)
+	}
+	if charged.Charge.GetType() != paymentpb.TransactionType_TRANSACTION_TYPE_CHARGE || charged.Charge.GetAmount() != 30 {
+		t.Fatalf("expected a charge of 30, got %v", charged.Charge)
+	}
+	// the payment uuid makes retries safe, the charge is handed out again
+	retried, err := api.ProcessPayment(callContext(t), payment)
+	if err != nil || retried.Charge.GetTransactionId() != charged.Charge.GetTransactionId() {
+		t.Fatalf("expected the retry to return the first charge, got %v (%v)", retried, err)
+	}
+	if amount := h.Account(accountId).Amount; amount != 70 {
+		t.Fatalf("expected a single charge, got a balance of %v", amount)
+	}
+
+	duplicate := &paymentpb.ProcessPaymentRequest{RequestId: requestId, Uuid: uuid.NewV4().String(), OrderId: payment.OrderId, AccountId: accountId, Amount: 30, Currency: "EUR"}
+	_, err = api.ProcessPayment(callContext(t), duplicate)
+	expectStatus(t, err, codes.AlreadyExists, "DUPLICATE_PAYMENT")
+
+	_, err = api.ProcessPayment(callContext(t), &paymentpb.ProcessPaymentRequest{RequestId: uuid.NewV4().String(), Uuid: uuid.NewV4().String(), OrderId: uuid.NewV4().String(), AccountId: accountId, Amount: 500, Currency: "EUR"})
+	expectStatus(t, err, codes.FailedPrecondition, "INSUFFICIENT_BALANCE")
+
+	_, err = api.ProcessPayment(callContext(t), &paymentpb.ProcessPaymentRequest{RequestId: uuid.NewV4().String(), Uuid: uuid.NewV4().String(), OrderId: uuid.NewV4().String(), AccountId: accountId, Amount: 5, Currency: "JPY"})
+	expectStatus(t, err, codes.FailedPrecondition, "NO_FX_RATE")
+
+	_, err = api.GetPaymentByRequest(callContext(t), &paymentpb.GetPaymentByRequestRequest{RequestId: uuid.NewV4().String()})
+	expectStatus(t, err, codes.NotFound, "CHARGE_NOT_FOUND")
+
+	refunded, err := api.Refund(callContext(t), &paymentpb.RefundRequest{RequestId: requestId})
+	if err != nil || refunded.Refund.GetType() != paymentpb.TransactionType_TRANSACTION_TYPE_REFUND {
+		t.Fatalf("expected a refund, got %v (%v)", refunded, err)
+	}
+	again, err := api.Refund(callContext(t), &paymentpb.RefundRequest{RequestId: requestId})
+	if err != nil || again.Refund.GetTransactionId() != refunded.Refund.GetTransactionId() {
+		t.Fatalf("expected refunding again to return the first refund, got %v (%v)", again, err)
+	}
+	if amount := h.Account(accountId).Amount; amount != 100 {
+		t.Fatalf("expected a single refund, got a balance of %v", amount)
+	}
+
+	transactions, err := api.GetPaymentByRequest(callContext(t), &paymentpb.GetPaymentByRequestRequest{RequestId: requestId})
+	if err != nil || len(transactions.Transactions) != 2 {
+		t.Fatalf("expected the charge and the refund, got %v (%v)", transactions, err)
+	}
+}
+
+func TestReplayedGrpcCallIsRejected(t *testing.T) {
+	h := New(t, Options{})
+	conn := h.dialPayment()
+	request := &paymentpb.GetPaymentByRequestRequest{RequestId: uuid.NewV4().String()}
+	ctx, err := h.Signer.SignCall(callContext(t), paymentpb.PaymentService_GetPaymentByRequest_FullMethodName, request)
+	if err != nil {
+		t.Fatalf("sign call: %v", err)
+	}
+
+	reply := &paymentpb.GetPaymentByRequestResponse{}
+	err = conn.Invoke(ctx, paymentpb.PaymentService_GetPaymentByRequest_FullMethodName, request, reply)
+	expectStatus(t, err, codes.NotFound, "")
+	err = conn.Invoke(ctx, paymentpb.PaymentService_GetPaymentByRequest_FullMethodName, request, reply)
+	expectStatus(t, err, codes.Unauthenticated, "")
+
+	// a signature only covers the request it was made for
+	ctx, err = h.Signer.SignCall(callContext(t), paymentpb.PaymentService_Refund_FullMethodName, &paymentpb.RefundRequest{RequestId: uuid.NewV4().String()})
+	if err != nil {
+		t.Fatalf("sign call: %v", err)
+	}
+	err = conn.Invoke(ctx, paymentpb.PaymentService_Refund_FullMethodName, &paymentpb.RefundRequest{RequestId: uuid.NewV4().String()}, &paymentpb.RefundResponse{})
+	expectStatus(t, err, codes.Unauthenticated, "")
+}
+
+func TestGrpcCallsForwardTheTokenOfTheCustomer(t *testing.T) {
+	h := New(t, Options{Verifier: hs256Verifier(t), PaymentTransport: client.TransportGRPC})
+	api := h.PaymentGrpcClient(h.Signer)
+	productId := h.SeedProduct(20, "EUR", 10)
+	ownAccount := h.SeedCustomerAccount("alice", 100, "EUR")
+	otherAccount := h.SeedCustomerAccount("bob", 100, "EUR")
+	token := sign(t, jwt.SigningMethodHS256, authSecret, "", "alice", time.Minute)
+
+	_, err := api.GetAccount(callContext(t), &paymentpb.GetAccountRequest{AccountId: ownAccount})
+	expectStatus(t, err, codes.Unauthenticated, "")
+
+	withToken := metadata.AppendToOutgoingContext(callContext(t), paymentpb.MetadataAuthorization, "Bearer "+token)
+	account, err := api.GetAccount(withToken, &paymentpb.GetAccountRequest{AccountId: ownAccount})
+	if err != nil || account.GetCustomerId() != "alice" || account.GetAmount() != 100 {
+		t.Fatalf("expected the account of alice, got %v (%v)", account, err)
+	}
+	_, err = api.GetAccount(withToken, &paymentpb.GetAccountRequest{AccountId: otherAccount})
+	expectStatus(t, err, codes.PermissionDenied, "ACCOUNT_NOT_OWNED")
+
+	// the order-service checks the owner and charges over gRPC with the token of its caller
+	if status := h.PostJSONWithToken(token, h.OrderURL+"/api/order/create", orderRequest(uuid.NewV4().String(), otherAccount, productId, 1), nil); status != http.StatusForbidden {
+		t.Fatalf("expected 403 for an account of another customer, got %d", status)
+	}
+	if status := h.PostJSONWithToken(token, h.OrderURL+"/api/order/create", orderRequest(uuid.NewV4().String(), ownAccount, productId, 1), nil); status != http.StatusOK {
+		t.Fatalf("expected 200 for an own account, got %d", status)
+	}
+	if amount := h.Account(ownAccount).Amount; amount != 80 {
+		t.Fatalf("expected account balance 80, got %v", amount)
+	}
+}
@@ -0,0 +1,634 @@
+// Package e2e boots the order, payment, inventory and orchestration services in one process,
+// so the saga can be exercised without postgres, redis or rabbitmq.
+package e2e
+
+import (
+	"bytes"
+	"common/apispec"
+	"common/auth"
+	"common/broker"
+	"common/paymentpb"
+	"common/signing"
+	"common/statestore"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/go-redis/redis/v8"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	inventoryApp "inventory-service/app"
+	inventoryConfigs "inventory-service/configs"
+	inventoryModel "inventory-service/model"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	orchestrationConfig "orchestration-service/config"
+	"orchestration-service/orchestrator"
+	orderApp "order-service/app"
+	"order-service/client"
+	orderConfigs "order-service/configs"
+	"order-service/dto/request"
+	"order-service/dto/response"
+	orderModel "order-service/model"
+	orderOpenAPI "order-service/openapi"
+	"os"
+	"path/filepath"
+	paymentApp "payment-service/app"
+	paymentConfigs "payment-service/configs"
+	paymentModel "payment-service/model"
+	paymentOpenAPI "payment-service/openapi"
+	"strings"
+	"testing"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+const (
+	clientOrigin           = "http://localhost"
+	orchestrationMapName   = "orchestration"
+	expiredQueue           = "orchestration-expired-events"
+	deadLetterQueue        = "orchestration-dead-letter-events"
+	rollbackOrderQueue     = "orchestration-rollback-order-events"
+	rollbackPaymentQueue   = "orchestration-rollback-payment-events"
+	rollbackInventoryQueue = "orchestration-rollback-inventory-events"
+	quarantineQueue        = "orchestration-quarantine-events"
+	// the order-service signs with the current key, the payment-service still accepts the retired one
+	signingKeyId        = "e2e-2"
+	signingSecret       = "e2e-signing-secret"
+	retiredSigningKeyId = "e2e-1"
+	retiredSigningKey   = "e2e-retired-secret"
+	// keys the order-service and the orchestrator sign their messages with
+	orderMessageKeyId        = "order-1"
+	orderMessageSecret       = "e2e-order-messages"
+	orchestratorMessageKeyId = "orchestrator-1"
+	orchestratorMessageKey   = "e2e-orchestrator-messages"
+)
+
+// Options tune the harness, the zero value runs orders synchronously with a five second orchestration expiry.
+type Options struct {
+	ProcessingMode          string
+	OrchestrationExpiration time.Duration
+	StaleJobSchedulePeriod  time.Duration
+	// workers and queue of the async processing mode, 4 workers with room for 100 orders by default
+	OrderWorkerCount     int
+	OrderWorkerQueueSize int
+	// enables auth on the order and payment services
+	Verifier *auth.Verifier
+	// only the RATE_LIMIT_ fields are used, rate limits are disabled by default
+	RateLimits orderConfigs.Config
+	// transport the order-service calls the payment-service over, http by default
+	PaymentTransport string
+	// runs the payment-service on this postgres database instead of SQLite, in a schema of its own
+	PaymentPostgresDSN string
+}
+
+// Harness holds the running services and the stand-ins they share. Redis is a miniredis,
+// the broker is in-memory and every service gets a SQLite database of its own.
+type Harness struct {
+	t testing.TB
+
+	OrderURL     string
+	PaymentURL   string
+	InventoryURL string
+	// address of the gRPC API of the payment-service
+	PaymentGrpcAddress string
+
+	OrderDB     *gorm.DB
+	PaymentDB   *gorm.DB
+	InventoryDB *gorm.DB
+
+	// the running order-service, for reloading its config
+	OrderApp *orderApp.App
+
+	Redis *miniredis.Miniredis
+	// idempotency cache of the payment-service, apart from Redis so it can be stopped while the nonces of signed
+	// calls are still checked
+	PaymentCache *miniredis.Miniredis
+	Broker       *broker.MemoryBroker
+	Store        statestore.StateStore
+	// signs direct calls to the payment-service like the order-service does
+	Signer *signing.Signer
+	// signs messages like the orchestrator does
+	MessageSigner *signing.Signer
+}
+
+func New(t testing.TB, options Options) *Harness {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	if options.ProcessingMode == "" {
+		options.ProcessingMode = "sync"
+	}
+	if options.OrchestrationExpiration == 0 {
+		options.OrchestrationExpiration = 5 * time.Second
+	}
+	if options.StaleJobSchedulePeriod == 0 {
+		options.StaleJobSchedulePeriod = 50 * time.Millisecond
+	}
+	if options.OrderWorkerCount == 0 {
+		options.OrderWorkerCount = 4
+	}
+	if options.OrderWorkerQueueSize == 0 {
+		options.OrderWorkerQueueSize = 100
+	}
+	if options.PaymentTransport == "" {
+		options.PaymentTransport = client.TransportHTTP
+	}
+
+	h := &Harness{
+		t:            t,
+		Redis:        miniredis.RunT(t),
+		PaymentCache: miniredis.RunT(t),
+		Broker:       broker.NewMemoryBroker(),
+		Signer:       signing.NewSigner(signingKeyId, signingSecret),
+
+		MessageSigner: signing.NewSigner(orchestratorMessageKeyId, orchestratorMessageKey),
+	}
+	orchestratorKeys := signing.NewMessageVerifier(map[string][]byte{orchestratorMessageKeyId: []byte(orchestratorMessageKey)})
+	redisClient := redis.NewClient(&redis.Options{Addr: h.Redis.Addr()})
+	t.Cleanup(func() { redisClient.Close() })
+	h.Store = statestore.NewRedisStore(redisClient, orchestrationMapName)
+	paymentCache := redis.NewClient(&redis.Options{Addr: h.PaymentCache.Addr()})
+	t.Cleanup(func() { paymentCache.Close() })
+
+	h.OrderDB = openDB(t, "order", &orderModel.Product{}, &orderModel.ProductOrder{}, &orderModel.ProductOrderItem{}, &orderModel.IdempotencyKey{}, &orderModel.ReconciliationFinding{})
+	paymentModels := []interface{}{&paymentModel.Account{}, &paymentModel.Transaction{}, &paymentModel.FxRate{}, &paymentModel.IdempotencyKey{}, &paymentModel.Settlement{}}
+	if options.PaymentPostgresDSN != "" {
+		h.PaymentDB = openPostgresDB(t, options.PaymentPostgresDSN, "payment", paymentModels...)
+	} else {
+		h.PaymentDB = openDB(t, "payment", paymentModels...)
+	}
+	h.InventoryDB = openDB(t, "inventory", &inventoryModel.Stock{}, &inventoryModel.StockReservation{}, &inventoryModel.Reservation{})
+	// unique constraints the services rely on, created by the postgres migrations
+	h.exec(h.OrderDB, "create unique index product_orders_request_id_idx on product_orders (request_id)")
+	h.exec(h.PaymentDB, "create unique index transactions_request_id_type_idx on transactions (request_id, type)")
+	h.exec(h.OrderDB, "create unique index reconciliation_findings_request_id_kind_idx on reconciliation_findings (request_id, kind)")
+	h.exec(h.InventoryDB, "create unique index stock_reservations_request_id_product_id_idx on stock_reservations (request_id, product_id)")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	expiration := int64(options.OrchestrationExpiration / time.Second)
+
+	payment := paymentApp.New(&paymentConfigs.Config{
+		ClientOrigin:                 clientOrigin,
+		RMQExpiredEventQueue:         expiredQueue,
+		RMQRollbackEventPaymentQueue: rollbackPaymentQueue,
+		RMQQuarantineQueue:           quarantineQueue,
+		RMQDeadLetterQueue:           deadLetterQueue,
+		IdempotencyKeyTTLSeconds:     300,
+	}, paymentApp.Dependencies{
+		DB:       h.PaymentDB,
+		Redis:    paymentCache,
+		Broker:   h.Broker,
+		Verifier: options.Verifier,
+		Signatures: signing.NewVerifier(map[string][]byte{
+			signingKeyId:        []byte(signingSecret),
+			retiredSigningKeyId: []byte(retiredSigningKey),
+		}, time.Minute, signing.NewRedisNonceStore(redisClient, "payment-nonce:")),
+		MessageVerifier: orchestratorKeys,
+		Spec:            loadSpec(t, paymentOpenAPI.Spec),
+	})
+	h.PaymentURL = h.serve(payment.Router)
+	h.PaymentGrpcAddress = h.serveGrpc(payment.GrpcServer)
+	h.start(payment.Start(ctx))
+
+	inventory := inventoryApp.New(&inventoryConfigs.Config{
+		ClientOrigin:                   clientOrigin,
+		RMQRollbackEventInventoryQueue: rollbackInventoryQueue,
+		RMQQuarantineQueue:             quarantineQueue,
+		RMQDeadLetterQueue:             deadLetterQueue,
+	}, inventoryApp.Dependencies{DB: h.InventoryDB, Broker: h.Broker, MessageVerifier: orchestratorKeys})
+	h.InventoryURL = h.serve(inventory.Router)
+	h.start(inventory.Start(ctx))
+
+	var paymentConn grpc.ClientConnInterface
+	if options.PaymentTransport == client.TransportGRPC {
+		paymentConn = h.dialPayment()
+	}
+	order := orderApp.New(&orderConfigs.Config{
+		ClientOrigin:                       clientOrigin,
+		OrderProcessingMode:                options.ProcessingMode,
+		OrderWorkerCount:                   options.OrderWorkerCount,
+		OrderWorkerQueueSize:               options.OrderWorkerQueueSize,
+		PaymentClientTransport:             options.PaymentTransport,
+		PaymentClientBaseUrl:               h.PaymentURL,
+		PaymentGrpcAddress:                 h.PaymentGrpcAddress,
+		PaymentGrpcTimeoutSeconds:          5,
+		InventoryClientBaseUrl:             h.InventoryURL,
+		IdempotencyKeyTTLSeconds:           300,
+		OrderEventsChannel:                 "order-events",
+		OrchestrationExpirationTimeSeconds: expiration,
+		OrchestrationMapName:               orchestrationMapName,
+		RMQExpiredEventQueue:               expiredQueue,
+		RMQRollbackEventOrderQueue:         rollbackOrderQueue,
+		RMQQuarantineQueue:                 quarantineQueue,
+		RMQDeadLetterQueue:                 deadLetterQueue,
+		ServiceSigningKeyId:                signingKeyId,
+		ServiceSigningSecret:               signingSecret,
+		RateLimitEnabled:                   options.RateLimits.RateLimitEnabled,
+		RateLimitWindowSeconds:             options.RateLimits.RateLimitWindowSeconds,
+		RateLimitPerAccount:                options.RateLimits.RateLimitPerAccount,
+		RateLimitPerIP:                     options.RateLimits.RateLimitPerIP,
+		RateLimitGlobal:                    options.RateLimits.RateLimitGlobal,
+		TrustedProxies:                     options.RateLimits.TrustedProxies,
+		// the schedule stays off, tests trigger runs through the API
+		ReconciliationWindowSeconds:  3600,
+		ReconciliationAutoCompensate: true,
+	}, orderApp.Dependencies{
+		DB:              h.OrderDB,
+		Redis:           redisClient,
+		Broker:          h.Broker,
+		Store:           h.Store,
+		Verifier:        options.Verifier,
+		MessageSigner:   signing.NewSigner(orderMessageKeyId, orderMessageSecret),
+		MessageVerifier: orchestratorKeys,
+		Spec:            loadSpec(t, orderOpenAPI.Spec),
+		PaymentConn:     paymentConn,
+	})
+	h.OrderApp = order
+	h.OrderURL = h.serve(order.Router)
+	h.start(order.Start(ctx))
+
+	orchestratorDone := make(chan struct{})
+	go func() {
+		orchestrator.New(h.Store, h.Broker, orchestrationConfig.Config{
+			RMQExpiredEventQueue:               expiredQueue,
+			RMQRollbackEventPaymentQueue:       rollbackPaymentQueue,
+			RMQRollbackEventOrderQueue:         rollbackOrderQueue,
+			RMQRollbackEventInventoryQueue:     rollbackInventoryQueue,
+			RMQDeadLetterQueue:                 deadLetterQueue,
+			RMQQuarantineQueue:                 quarantineQueue,
+			OrchestrationExpirationTimeSeconds: expiration,
+			OrchestrationMapName:               orchestrationMapName,
+			StaleJobSchedulePeriodMilliseconds: options.StaleJobSchedulePeriod.Milliseconds(),
+		}, h.MessageSigner, signing.NewMessageVerifier(map[string][]byte{
+			orderMessageKeyId:        []byte(orderMessageSecret),
+			orchestratorMessageKeyId: []byte(orchestratorMessageKey),
+		})).Run(ctx)
+		close(orchestratorDone)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-orchestratorDone
+	})
+
+	return h
+}
+
+// SeedProduct adds a product to the catalogue and puts stock of it into the inventory.
+func (h *Harness) SeedProduct(price float64, currency string, stock int) string {
+	h.t.Helper()
+	productId := uuid.NewV4().String()
+	now := time.Now().UTC()
+	h.create(h.OrderDB, &orderModel.Product{
+		ProductId:  productId,
+		Name:       "product " + productId,
+		Price:      price,
+		Currency:   currency,
+		CreateDate: now,
+		UpdateDate: now,
+	})
+	h.create(h.InventoryDB, &inventoryModel.Stock{
+		ProductId:  productId,
+		Quantity:   stock,
+		UpdateDate: now,
+	})
+	return productId
+}
+
+func (h *Harness) SeedAccount(amount float64, currency string) string {
+	h.t.Helper()
+	return h.SeedCustomerAccount("", amount, currency)
+}
+
+// SeedCustomerAccount seeds an account owned by customerId, the subject of the tokens that may charge it.
+func (h *Harness) SeedCustomerAccount(customerId string, amount float64, currency string) string {
+	h.t.Helper()
+	accountId := uuid.NewV4().String()
+	h.create(h.PaymentDB, &paymentModel.Account{
+		AccountId:  accountId,
+		CustomerId: customerId,
+		Amount:     amount,
+		Currency:   currency,
+		UpdateDate: time.Now().UTC(),
+	})
+	return accountId
+}
+
+// SeedFxRate seeds a rate converting one unit of base into rate units of quote, effective since an hour.
+func (h *Harness) SeedFxRate(base string, quote string, rate float64) {
+	h.t.Helper()
+	now := time.Now().UTC()
+	h.create(h.PaymentDB, &paymentModel.FxRate{
+		FxRateId:      uuid.NewV4().String(),
+		BaseCurrency:  base,
+		QuoteCurrency: quote,
+		Rate:          rate,
+		EffectiveDate: now.Add(-time.Hour),
+		CreateDate:    now,
+	})
+}
+
+// CreateOrder posts an order and returns the status code with the decoded order, which is empty on errors.
+func (h *Harness) CreateOrder(orderRequest request.OrderRequest) (int, response.OrderResponse) {
+	h.t.Helper()
+	var orderResponse response.OrderResponse
+	status := h.PostJSON(h.OrderURL+"/api/order/create", orderRequest, &orderResponse)
+	return status, orderResponse
+}
+
+// Reconcile runs the order-service reconciliation and returns its summary.
+func (h *Harness) Reconcile() response.ReconciliationRunResponse {
+	h.t.Helper()
+	var run response.ReconciliationRunResponse
+	if status := h.PostJSON(h.OrderURL+"/api/reconciliation/run", nil, &run); status != http.StatusOK {
+		h.t.Fatalf("expected reconciliation to run, got %d", status)
+	}
+	return run
+}
+
+// GetJSON decodes a successful response into out.
+func (h *Harness) GetJSON(url string, out interface{}) int {
+	h.t.Helper()
+	return h.GetJSONWithToken("", url, out)
+}
+
+// GetJSONWithToken is GetJSON with token as bearer token when set.
+func (h *Harness) GetJSONWithToken(token string, url string, out interface{}) int {
+	h.t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		h.t.Fatalf("build request: %v", err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		h.t.Fatalf("get %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+
+	if out != nil && resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			h.t.Fatalf("decode response of %s: %v", url, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+// PostJSON posts payload and decodes a successful response into out when it is not nil.
+func (h *Harness) PostJSON(url string, payload interface{}, out interface{}) int {
+	h.t.Helper()
+	return h.PostJSONWithToken("", url, payload, out)
+}
+
+// PostJSONWithToken is PostJSON with token as bearer token, an empty token sends no Authorization header.
+func (h *Harness) PostJSONWithToken(token string, url string, payload interface{}, out interface{}) int {
+	h.t.Helper()
+	return h.post(url, token, nil, payload, out)
+}
+
+// PostPayment posts payload to the payment-service signed as the order-service, with token as bearer token when set.
+func (h *Harness) PostPayment(token string, payload interface{}) int {
+	h.t.Helper()
+	return h.post(h.PaymentURL+"/api/payment/process", token, h.Signer, payload, nil)
+}
+
+func (h *Harness) post(url string, token string, signer *signing.Signer, payload interface{}, out interface{}) int {
+	h.t.Helper()
+	body, err := json.Marshal(payload)
+	if err != nil {
+		h.t.Fatalf("marshal request: %v", err)
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		h.t.Fatalf("build request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if signer != nil {
+		if err := signer.Sign(req, body); err != nil {
+			h.t.Fatalf("sign request: %v", err)
+		}
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		h.t.Fatalf("post %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+
+	if out != nil && resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			h.t.Fatalf("decode response of %s: %v", url, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func (h *Harness) Order(requestId string) orderModel.ProductOrder {
+	h.t.Helper()
+	var order orderModel.ProductOrder
+	if err := h.OrderDB.Where("request_id = ?", requestId).First(&order).Error; err != nil {
+		h.t.Fatalf("fetch order %s: %v", requestId, err)
+	}
+	return order
+}
+
+func (h *Harness) Account(accountId string) paymentModel.Account {
+	h.t.Helper()
+	var account paymentModel.Account
+	if err := h.PaymentDB.Where("account_id = ?", accountId).First(&account).Error; err != nil {
+		h.t.Fatalf("fetch account %s: %v", accountId, err)
+	}
+	return account
+}
+
+func (h *Harness) Stock(productId string) int {
+	h.t.Helper()
+	var stock inventoryModel.Stock
+	if err := h.InventoryDB.Where("product_id = ?", productId).First(&stock).Error; err != nil {
+		h.t.Fatalf("fetch stock %s: %v", productId, err)
+	}
+	return stock.Quantity
+}
+
+func (h *Harness) Transactions(requestId string) []paymentModel.Transaction {
+	h.t.Helper()
+	var transactions []paymentModel.Transaction
+	if err := h.PaymentDB.Where("request_id = ?", requestId).Order("create_date").Find(&transactions).Error; err != nil {
+		h.t.Fatalf("fetch transactions %s: %v", requestId, err)
+	}
+	return transactions
+}
+
+// Eventually polls condition until it holds, failing the test with message after timeout.
+func (h *Harness) Eventually(timeout time.Duration, condition func() bool, message string, args ...interface{}) {
+	h.t.Helper()
+	deadline := time.Now().Add(timeout)
+	for !condition() {
+		if time.Now().After(deadline) {
+			h.t.Fatalf("timed out after %s: %s", timeout, fmt.Sprintf(message, args...))
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func (h *Harness) serve(handler http.Handler) string {
+	server := httptest.NewServer(handler)
+	h.t.Cleanup(server.Close)
+	return server.URL
+}
+
+func (h *Harness) serveGrpc(server *grpc.Server) string {
+	h.t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		h.t.Fatalf("listen for grpc: %v", err)
+	}
+	go server.Serve(listener)
+	h.t.Cleanup(server.Stop)
+	return listener.Addr().String()
+}
+
+// PaymentGrpcClient calls the gRPC API of the payment-service directly, signed with signer unless it is nil.
+func (h *Harness) PaymentGrpcClient(signer *signing.Signer) paymentpb.PaymentServiceClient {
+	h.t.Helper()
+	var options []grpc.DialOption
+	if signer != nil {
+		options = append(options, grpc.WithUnaryInterceptor(signer.UnaryClientInterceptor()))
+	}
+	return paymentpb.NewPaymentServiceClient(h.dialPayment(options...))
+}
+
+func (h *Harness) dialPayment(options ...grpc.DialOption) *grpc.ClientConn {
+	h.t.Helper()
+	conn, err := grpc.NewClient(h.PaymentGrpcAddress, append(options, grpc.WithTransportCredentials(insecure.NewCredentials()))...)
+	if err != nil {
+		h.t.Fatalf("dial payment grpc: %v", err)
+	}
+	h.t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func (h *Harness) start(err error) {
+	h.t.Helper()
+	if err != nil {
+		h.t.Fatalf("start service: %v", err)
+	}
+}
+
+func (h *Harness) create(db *gorm.DB, value interface{}) {
+	h.t.Helper()
+	if err := db.Create(value).Error; err != nil {
+		h.t.Fatalf("seed %T: %v", value, err)
+	}
+}
+
+func (h *Harness) exec(db *gorm.DB, sql string) {
+	h.t.Helper()
+	if err := db.Exec(sql).Error; err != nil {
+		h.t.Fatalf("exec %s: %v", sql, err)
+	}
+}
+
+// openDB creates a SQLite database per service, WAL and a busy timeout let the services write concurrently.
+func openDB(t testing.TB, name string, models ...interface{}) *gorm.DB {
+	t.Helper()
+	dsn := filepath.Join(t.TempDir(), name+".db") + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{
+		TranslateError: true,
+		Logger:         logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open %s database: %v", name, err)
+	}
+	if err := db.AutoMigrate(models...); err != nil {
+		t.Fatalf("migrate %s database: %v", name, err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+// openPostgresDB creates a schema per test on the postgres database of dsn, which is dropped again on cleanup.
+func openPostgresDB(t testing.TB, dsn string, name string, models ...interface{}) *gorm.DB {
+	t.Helper()
+	db := postgresSchema(t, dsn, name)
+	if err := db.AutoMigrate(models...); err != nil {
+		t.Fatalf("migrate %s database: %v", name, err)
+	}
+	return db
+}
+
+// postgresSchema connects to an empty schema of its own on the postgres database of dsn, it is dropped on cleanup.
+func postgresSchema(t testing.TB, dsn string, name string) *gorm.DB {
+	t.Helper()
+	admin, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open %s database: %v", name, err)
+	}
+	schema := "e2e_" + name + "_" + strings.ReplaceAll(uuid.NewV4().String(), "-", "")
+	if err := admin.Exec("create schema " + schema).Error; err != nil {
+		t.Fatalf("create schema %s: %v", schema, err)
+	}
+	t.Cleanup(func() {
+		admin.Exec("drop schema " + schema + " cascade")
+		if sqlDB, err := admin.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	config, err := pgx.ParseConfig(dsn)
+	if err != nil {
+		t.Fatalf("parse %s dsn: %v", name, err)
+	}
+	config.RuntimeParams["search_path"] = schema
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: stdlib.OpenDB(*config)}), &gorm.Config{
+		TranslateError: true,
+		Logger:         logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open %s database: %v", name, err)
+	}
+	// cleanups run last in first out, the pool is closed before the schema is dropped
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+// postgresDSN returns the database of E2E_POSTGRES_DSN, tests needing postgres are skipped without one.
+func postgresDSN(t testing.TB) string {
+	t.Helper()
+	dsn := os.Getenv("E2E_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("E2E_POSTGRES_DSN is not set")
+	}
+	return dsn
+}
+
+// loadSpec checks the responses of the services strictly, so a handler drifting from its spec fails the tests.
+func loadSpec(t testing.TB, data []byte) *apispec.Spec {
+	t.Helper()
+	spec, err := apispec.Load(data, apispec.ResponsesStrict)
+	if err != nil {
+		t.Fatalf("load spec: %v", err)
+	}
+	return spec
+}
@@ -0,0 +1,127 @@
+package e2e
+
+import (
+	"net/http"
+	orderModel "order-service/model"
+	"testing"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+func countOrders(t *testing.T, h *Harness, requestId string) int64 {
+	t.Helper()
+	var orders int64
+	if err := h.OrderDB.Model(&orderModel.ProductOrder{}).Where("request_id = ?", requestId).Count(&orders).Error; err != nil {
+		t.Fatalf("count orders %s: %v", requestId, err)
+	}
+	return orders
+}
+
+func TestDuplicateOrderReplaysTheFirstResponse(t *testing.T) {
+	h := New(t, Options{})
+	productId := h.SeedProduct(20, "EUR", 10)
+	accountId := h.SeedAccount(100, "EUR")
+	requestId := uuid.NewV4().String()
+
+	status, first := h.CreateOrder(orderRequest(requestId, accountId, productId, 1))
+	if status != http.StatusOK {
+		t.Fatalf("expected 200, got %d", status)
+	}
+	status, cached := h.CreateOrder(orderRequest(requestId, accountId, productId, 1))
+	if status != http.StatusOK || cached.ProductOrderId != first.ProductOrderId || cached.Status != first.Status {
+		t.Fatalf("expected the first response replayed from the cache, got %d with %+v", status, cached)
+	}
+	// postgres answers once the cache lost the outcome
+	h.Redis.FlushAll()
+	status, stored := h.CreateOrder(orderRequest(requestId, accountId, productId, 1))
+	if status != http.StatusOK || stored.ProductOrderId != first.ProductOrderId {
+		t.Fatalf("expected the first response replayed from postgres, got %d with %+v", status, stored)
+	}
+
+	if orders := countOrders(t, h, requestId); orders != 1 {
+		t.Fatalf("expected a single order, got %d", orders)
+	}
+	if amount := h.Account(accountId).Amount; amount != 80 {
+		t.Fatalf("expected a single charge leaving 80, got %v", amount)
+	}
+	if stock := h.Stock(productId); stock != 9 {
+		t.Fatalf("expected a single reservation leaving 9, got %d", stock)
+	}
+}
+
+func TestDuplicateOfAFailedOrderReplaysTheFailure(t *testing.T) {
+	h := New(t, Options{})
+	productId := h.SeedProduct(20, "EUR", 10)
+	accountId := h.SeedAccount(10, "EUR")
+	requestId := uuid.NewV4().String()
+
+	for i := 0; i < 2; i++ {
+		if status, _ := h.CreateOrder(orderRequest(requestId, accountId, productId, 1)); status != http.StatusBadRequest {
+			t.Fatalf("expected attempt %d to be declined with 400, got %d", i, status)
+		}
+	}
+	if orders := countOrders(t, h, requestId); orders != 1 {
+		t.Fatalf("expected a single order, got %d", orders)
+	}
+}
+
+func TestRequestIdReusedWithAnotherPayloadIsRejected(t *testing.T) {
+	h := New(t, Options{})
+	productId := h.SeedProduct(20, "EUR", 10)
+	accountId := h.SeedAccount(100, "EUR")
+	requestId := uuid.NewV4().String()
+
+	if status, _ := h.CreateOrder(orderRequest(requestId, accountId, productId, 1)); status != http.StatusOK {
+		t.Fatalf("expected 200, got %d", status)
+	}
+	for _, cached := range []bool{true, false} {
+		if !cached {
+			h.Redis.FlushAll()
+		}
+		if status, _ := h.CreateOrder(orderRequest(requestId, accountId, productId, 2)); status != http.StatusUnprocessableEntity {
+			t.Fatalf("expected 422 for another payload (cached %t), got %d", cached, status)
+		}
+	}
+	if amount := h.Account(accountId).Amount; amount != 80 {
+		t.Fatalf("expected only the first order charged, got %v", amount)
+	}
+}
+
+// with the idempotency cache down the payments table decides, a duplicate uuid is answered without charging again.
+func TestDuplicatePaymentIsChargedOnceWhileTheCacheIsDown(t *testing.T) {
+	h := New(t, Options{})
+	accountId := h.SeedAccount(100, "EUR")
+	h.PaymentCache.Close()
+
+	payment := map[string]interface{}{
+		"requestId": uuid.NewV4().String(),
+		"uuid":      uuid.NewV4().String(),
+		"orderId":   uuid.NewV4().String(),
+		"amount":    30,
+		"currency":  "EUR",
+		"accountId": accountId,
+	}
+	for i := 0; i < 3; i++ {
+		if status := h.PostPayment("", payment); status != http.StatusOK {
+			t.Fatalf("expected payment %d to be answered with 200, got %d", i, status)
+		}
+	}
+
+	if amount := h.Account(accountId).Amount; amount != 70 {
+		t.Fatalf("expected the account to be charged once, got %v left", amount)
+	}
+	if transactions := h.Transactions(payment["requestId"].(string)); len(transactions) != 1 {
+		t.Fatalf("expected a single transaction, got %+v", transactions)
+	}
+
+	// a cold cache after the restart knows nothing about the payment either
+	if err := h.PaymentCache.Restart(); err != nil {
+		t.Fatalf("restart cache: %v", err)
+	}
+	if status := h.PostPayment("", payment); status != http.StatusOK {
+		t.Fatalf("expected the replay to be answered with 200, got %d", status)
+	}
+	if amount := h.Account(accountId).Amount; amount != 70 {
+		t.Fatalf("expected the account to be charged once, got %v left", amount)
+	}
+}
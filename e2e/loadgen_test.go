@@ -0,0 +1,57 @@
+package e2e
+
+import (
+	"context"
+	"loadgen/loadtest"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestLoadgenTrafficKeepsInvariants(t *testing.T) {
+	h := New(t, Options{})
+	fixture, err := loadtest.Seed(h.OrderDB, h.PaymentDB, h.InventoryDB, loadtest.SeedOptions{
+		Products:            3,
+		Price:               10,
+		Stock:               1000,
+		Accounts:            4,
+		Balance:             1000,
+		UnderfundedAccounts: 2,
+		UnderfundedBalance:  5,
+		Currency:            "EUR",
+	})
+	if err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+
+	result := loadtest.Run(context.Background(), &http.Client{Timeout: sagaTimeout}, h.OrderURL, fixture, loadtest.TrafficOptions{
+		Orders:             60,
+		Concurrency:        6,
+		MaxQuantity:        3,
+		DuplicateRate:      0.2,
+		UnknownProductRate: 0.1,
+		UnderfundedRate:    0.2,
+		RandomSeed:         1,
+	})
+	if len(result.Samples) != 60 {
+		t.Fatalf("expected 60 samples, got %d", len(result.Samples))
+	}
+	if err := loadtest.Settle(context.Background(), h.OrderDB, fixture, 2*sagaTimeout); err != nil {
+		t.Fatalf("settle: %v", err)
+	}
+	// refunds land shortly after the order is compensated, so the ledger is given a moment to catch up
+	deadline := time.Now().Add(sagaTimeout)
+	for {
+		violations, err := loadtest.Verify(h.OrderDB, h.PaymentDB, fixture)
+		if err != nil {
+			t.Fatalf("verify: %v", err)
+		}
+		if len(violations) == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected no violations, got %+v", violations)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
@@ -0,0 +1,117 @@
+package e2e
+
+import (
+	"common/broker"
+	"common/signing"
+	"common/statestore"
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// quarantined collects the messages moved to the quarantine queue.
+func quarantined(t *testing.T, h *Harness) <-chan broker.Message {
+	t.Helper()
+	messages := make(chan broker.Message, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	err := h.Broker.Subscribe(ctx, quarantineQueue, func(ctx context.Context, delivery broker.Delivery) {
+		messages <- delivery.Message
+		delivery.Ack()
+	})
+	if err != nil {
+		t.Fatalf("subscribe to quarantine: %v", err)
+	}
+	return messages
+}
+
+func expectQuarantined(t *testing.T, messages <-chan broker.Message, queue string, body string) {
+	t.Helper()
+	select {
+	case msg := <-messages:
+		if string(msg.Body) != body || msg.Headers[signing.HeaderOriginalQueue] != queue || msg.Headers[signing.HeaderQuarantineReason] == "" {
+			t.Fatalf("expected %s from %s with a reason, got %s with %v", body, queue, string(msg.Body), msg.Headers)
+		}
+	case <-time.After(sagaTimeout):
+		t.Fatalf("expected %s from %s to be quarantined", body, queue)
+	}
+}
+
+func TestForgedRollbackMessagesAreQuarantined(t *testing.T) {
+	h := New(t, Options{})
+	messages := quarantined(t, h)
+	accountId := h.SeedAccount(100, "EUR")
+	requestId := uuid.NewV4().String()
+	status := h.PostPayment("", map[string]interface{}{
+		"requestId": requestId,
+		"uuid":      uuid.NewV4().String(),
+		"orderId":   uuid.NewV4().String(),
+		"amount":    30,
+		"currency":  "EUR",
+		"accountId": accountId,
+	})
+	if status != http.StatusOK {
+		t.Fatalf("expected payment to succeed, got %d", status)
+	}
+
+	if err := h.Broker.Publish(context.Background(), rollbackPaymentQueue, broker.Message{Body: []byte(requestId)}); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	expectQuarantined(t, messages, rollbackPaymentQueue, requestId)
+
+	forged := broker.Message{Body: []byte(requestId)}
+	signing.NewSigner(orchestratorMessageKeyId, "guessed").SignMessage(&forged)
+	if err := h.Broker.Publish(context.Background(), rollbackPaymentQueue, forged); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	expectQuarantined(t, messages, rollbackPaymentQueue, requestId)
+
+	// a message signed for another request does not refund this one
+	tampered := broker.Message{Body: []byte(uuid.NewV4().String())}
+	h.MessageSigner.SignMessage(&tampered)
+	tampered.Body = []byte(requestId)
+	if err := h.Broker.Publish(context.Background(), rollbackPaymentQueue, tampered); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	expectQuarantined(t, messages, rollbackPaymentQueue, requestId)
+
+	if amount := h.Account(accountId).Amount; amount != 70 {
+		t.Fatalf("expected no refund, got a balance of %v", amount)
+	}
+
+	signed := broker.Message{Body: []byte(requestId)}
+	h.MessageSigner.SignMessage(&signed)
+	if err := h.Broker.Publish(context.Background(), rollbackPaymentQueue, signed); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	h.Eventually(sagaTimeout, func() bool {
+		return h.Account(accountId).Amount == 100
+	}, "account %s refunded", accountId)
+}
+
+func TestUnsignedExpiredEventIsQuarantined(t *testing.T) {
+	h := New(t, Options{})
+	messages := quarantined(t, h)
+	requestId := uuid.NewV4().String()
+	err := h.Store.Create(context.Background(), statestore.Record{
+		UUID:           requestId,
+		Status:         "ROLLBACK",
+		ExpirationTime: time.Now().Add(time.Minute).UnixMilli(),
+	})
+	if err != nil {
+		t.Fatalf("create orchestration: %v", err)
+	}
+
+	if err := h.Broker.Publish(context.Background(), expiredQueue, broker.Message{Body: []byte(requestId)}); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	expectQuarantined(t, messages, expiredQueue, requestId)
+
+	record, err := h.Store.Get(context.Background(), requestId)
+	if err != nil || record.Status != "ROLLBACK" {
+		t.Fatalf("expected the orchestration untouched, got %+v (%v)", record, err)
+	}
+}
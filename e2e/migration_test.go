@@ -0,0 +1,205 @@
+package e2e
+
+import (
+	"common/migration"
+	inventoryMigration "inventory-service/migration"
+	inventoryModel "inventory-service/model"
+	orderMigration "order-service/migration"
+	orderModel "order-service/model"
+	paymentMigration "payment-service/migration"
+	paymentModel "payment-service/model"
+	"testing"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	"gorm.io/gorm"
+)
+
+// baselineSchema is the docker/schema.sql the services ran on before they migrated their own tables.
+const baselineSchema = `
+create table products
+(
+    product_id varchar(512) not null,
+    name varchar(128),
+    price numeric,
+    create_date date,
+    update_date date
+);
+
+create table product_orders
+(
+    product_order_id varchar(512) not null,
+    product_id varchar(512) not null,
+    account_id varchar(512) not null,
+    create_date date,
+    request_id varchar(512) not null
+);
+
+create table accounts
+(
+    account_id varchar(512) not null,
+    amount numeric,
+    update_date date
+);
+
+create table transactions
+(
+    transaction_id varchar(512) not null,
+    product_id varchar(512) not null,
+    amount numeric,
+    create_date date,
+    request_id varchar(512) not null,
+    account_id varchar(512) not null
+);
+`
+
+// migrateAll applies the migrations of every service to db, they share it like in docker-compose.
+func migrateAll(t *testing.T, db *gorm.DB) {
+	t.Helper()
+	for name, newMigrator := range map[string]func(*gorm.DB) (*migration.Migrator, error){
+		"order":     orderMigration.NewMigrator,
+		"payment":   paymentMigration.NewMigrator,
+		"inventory": inventoryMigration.NewMigrator,
+	} {
+		migrator, err := newMigrator(db)
+		if err != nil {
+			t.Fatalf("load %s migrations: %v", name, err)
+		}
+		if _, err := migrator.Up(); err != nil {
+			t.Fatalf("migrate %s: %v", name, err)
+		}
+		statuses, err := migrator.Status()
+		if err != nil {
+			t.Fatalf("status of %s migrations: %v", name, err)
+		}
+		for _, status := range statuses {
+			if status.AppliedAt == nil {
+				t.Fatalf("expected %s migration %d_%s applied", name, status.Migration.Version, status.Migration.Name)
+			}
+		}
+	}
+}
+
+// expectWritable stores rows of the current models, which fails on a schema lagging behind them.
+func expectWritable(t *testing.T, db *gorm.DB) {
+	t.Helper()
+	now := time.Now().UTC()
+	productId := uuid.NewV4().String()
+	accountId := uuid.NewV4().String()
+	orderId := uuid.NewV4().String()
+	requestId := uuid.NewV4().String()
+	rows := []interface{}{
+		&orderModel.Product{ProductId: productId, Name: "product", Price: 20, Currency: "USD", CreateDate: now, UpdateDate: now},
+		&orderModel.ProductOrder{
+			ProductOrderId: orderId,
+			AccountId:      accountId,
+			Status:         orderModel.OrderStatusPending,
+			TotalAmount:    20,
+			Currency:       "USD",
+			CreateDate:     now,
+			UpdateDate:     now,
+			RequestId:      requestId,
+			Items: []orderModel.ProductOrderItem{{
+				ProductOrderItemId: uuid.NewV4().String(),
+				ProductId:          productId,
+				Quantity:           1,
+				UnitPrice:          20,
+				LineTotal:          20,
+			}},
+		},
+		&paymentModel.Account{AccountId: accountId, Amount: 100, Currency: "EUR", UpdateDate: now, CustomerId: "alice"},
+		&paymentModel.Transaction{
+			TransactionId:    uuid.NewV4().String(),
+			OrderId:          orderId,
+			Type:             paymentModel.TransactionTypeCharge,
+			Amount:           18,
+			Currency:         "EUR",
+			OriginalAmount:   20,
+			OriginalCurrency: "USD",
+			FxRate:           0.9,
+			CreateDate:       now,
+			RequestId:        requestId,
+			AccountId:        accountId,
+		},
+		&paymentModel.Settlement{RequestId: requestId, Status: paymentModel.SettlementStatusCharged, CreateDate: now, UpdateDate: now},
+		&inventoryModel.Stock{ProductId: productId, Quantity: 9, UpdateDate: now},
+	}
+	for _, row := range rows {
+		if err := db.Create(row).Error; err != nil {
+			t.Fatalf("store %T: %v", row, err)
+		}
+	}
+}
+
+func TestMigrationsCreateAFreshDatabase(t *testing.T) {
+	db := postgresSchema(t, postgresDSN(t), "fresh")
+	migrateAll(t, db)
+	expectWritable(t, db)
+
+	// every migration can be reverted and applied again
+	migrator, err := paymentMigration.NewMigrator(db)
+	if err != nil {
+		t.Fatalf("load payment migrations: %v", err)
+	}
+	for {
+		reverted, err := migrator.Down()
+		if err != nil {
+			t.Fatalf("revert payment migration: %v", err)
+		}
+		if reverted == nil {
+			break
+		}
+	}
+	if _, err := migrator.Up(); err != nil {
+		t.Fatalf("migrate payment again: %v", err)
+	}
+}
+
+func TestMigrationsAdoptTheBaselineSchema(t *testing.T) {
+	db := postgresSchema(t, postgresDSN(t), "baseline")
+	if err := db.Exec(baselineSchema).Error; err != nil {
+		t.Fatalf("create baseline schema: %v", err)
+	}
+	productId := uuid.NewV4().String()
+	accountId := uuid.NewV4().String()
+	orderId := uuid.NewV4().String()
+	requestId := uuid.NewV4().String()
+	seed := []string{
+		"insert into products values ('" + productId + "', 'legacy', 15, current_date, current_date)",
+		"insert into product_orders values ('" + orderId + "', '" + productId + "', '" + accountId + "', current_date, '" + requestId + "')",
+		"insert into accounts values ('" + accountId + "', 85, current_date)",
+		"insert into transactions values ('" + uuid.NewV4().String() + "', '" + productId + "', 15, current_date, '" + requestId + "', '" + accountId + "')",
+	}
+	for _, statement := range seed {
+		if err := db.Exec(statement).Error; err != nil {
+			t.Fatalf("seed baseline: %v", err)
+		}
+	}
+
+	migrateAll(t, db)
+	expectWritable(t, db)
+
+	// orders were only kept once charged, their product becomes their only item
+	var order orderModel.ProductOrder
+	if err := db.Preload("Items").Where("product_order_id = ?", orderId).First(&order).Error; err != nil {
+		t.Fatalf("fetch adopted order: %v", err)
+	}
+	if order.Status != orderModel.OrderStatusConfirmed || order.TotalAmount != 15 || order.Currency != "EUR" {
+		t.Fatalf("expected a CONFIRMED order of 15 EUR, got %+v", order)
+	}
+	if len(order.Items) != 1 || order.Items[0].ProductId != productId || order.Items[0].LineTotal != 15 {
+		t.Fatalf("expected the product of the order as its item, got %+v", order.Items)
+	}
+
+	var charge paymentModel.Transaction
+	if err := db.Where("request_id = ?", requestId).First(&charge).Error; err != nil {
+		t.Fatalf("fetch adopted transaction: %v", err)
+	}
+	if charge.Type != paymentModel.TransactionTypeCharge || charge.OriginalAmount != 15 || charge.FxRate != 1 {
+		t.Fatalf("expected a charge of 15 at rate 1, got %+v", charge)
+	}
+	var settlement paymentModel.Settlement
+	if err := db.Where("request_id = ?", requestId).First(&settlement).Error; err != nil || settlement.Status != paymentModel.SettlementStatusCharged {
+		t.Fatalf("expected the adopted charge settled, got %+v with %v", settlement, err)
+	}
+}
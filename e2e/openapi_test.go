@@ -0,0 +1,89 @@
+package e2e
+
+import (
+	"common/apispec"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestServicesServeTheirSpecs(t *testing.T) {
+	h := New(t, Options{})
+
+	for url, path := range map[string]string{
+		h.OrderURL:   "/api/order/create",
+		h.PaymentURL: "/api/payment/process",
+	} {
+		var document struct {
+			OpenAPI string                 `json:"openapi"`
+			Paths   map[string]interface{} `json:"paths"`
+		}
+		if status := h.GetJSON(url+"/openapi.json", &document); status != http.StatusOK {
+			t.Fatalf("expected the spec of %s, got %d", url, status)
+		}
+		if !strings.HasPrefix(document.OpenAPI, "3.") || document.Paths[path] == nil {
+			t.Fatalf("expected an OpenAPI 3 document describing %s, got %s with %d paths", path, document.OpenAPI, len(document.Paths))
+		}
+
+		resp, err := http.Get(url + "/docs")
+		if err != nil {
+			t.Fatalf("get docs: %v", err)
+		}
+		page, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || !strings.Contains(string(page), "/openapi.json") {
+			t.Fatalf("expected a docs page rendering the spec, got %d", resp.StatusCode)
+		}
+	}
+}
+
+const driftSpec = `
+openapi: 3.0.3
+info:
+  title: drift
+  version: "1"
+paths:
+  /widget:
+    get:
+      responses:
+        "200":
+          description: a widget
+          content:
+            application/json:
+              schema:
+                type: object
+                required: [Name]
+                properties:
+                  Name:
+                    type: string
+`
+
+// a handler drifting from its spec is caught in strict mode and only logged otherwise
+func TestResponsesBreakingTheSpecAreCaught(t *testing.T) {
+	for mode, expected := range map[string]int{
+		apispec.ResponsesStrict: http.StatusInternalServerError,
+		apispec.ResponsesLog:    http.StatusOK,
+	} {
+		spec, err := apispec.Load([]byte(driftSpec), mode)
+		if err != nil {
+			t.Fatalf("load spec: %v", err)
+		}
+		server := gin.New()
+		server.GET("/widget", spec.Validate(), func(ctx *gin.Context) {
+			ctx.JSON(http.StatusOK, gin.H{"Title": "renamed"})
+		})
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/widget", nil))
+		if recorder.Code != expected {
+			t.Fatalf("expected %d in %s mode, got %d", expected, mode, recorder.Code)
+		}
+	}
+
+	if _, err := apispec.Load([]byte(driftSpec), "sometimes"); err == nil {
+		t.Fatalf("expected an unknown mode to be refused")
+	}
+}
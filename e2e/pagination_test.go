@@ -0,0 +1,171 @@
+package e2e
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"order-service/dto/response"
+	orderModel "order-service/model"
+	paymentResponse "payment-service/dto/response"
+	paymentModel "payment-service/model"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// pageDates has rows sharing a create date across page boundaries whatever the page size.
+var pageDates = []time.Duration{0, 0, 0, -time.Second, -time.Second, -2 * time.Second, -3 * time.Second}
+
+type pagedRow struct {
+	id         string
+	createDate time.Time
+}
+
+// newestFirst is the order the pages are expected in, ties on the create date are broken by id.
+func newestFirst(rows []pagedRow) []string {
+	sort.Slice(rows, func(i, j int) bool {
+		if !rows[i].createDate.Equal(rows[j].createDate) {
+			return rows[i].createDate.After(rows[j].createDate)
+		}
+		return rows[i].id > rows[j].id
+	})
+	ids := make([]string, 0, len(rows))
+	for _, row := range rows {
+		ids = append(ids, row.id)
+	}
+	return ids
+}
+
+// walkPages follows the cursors from the first page and fails on a page larger than limit or a cursor leading nowhere.
+func walkPages(t *testing.T, limit int, fetch func(cursor string) ([]string, string)) []string {
+	t.Helper()
+	var ids []string
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages > len(pageDates) {
+			t.Fatalf("expected the pages to end, got %v so far", ids)
+		}
+		page, next := fetch(cursor)
+		if len(page) > limit || (next != "" && len(page) != limit) {
+			t.Fatalf("expected pages of %d, got %d with next cursor %q", limit, len(page), next)
+		}
+		ids = append(ids, page...)
+		if next == "" {
+			return ids
+		}
+		cursor = next
+	}
+}
+
+func pageQuery(limit int, cursor string) url.Values {
+	query := url.Values{"limit": {fmt.Sprint(limit)}}
+	if cursor != "" {
+		query.Set("cursor", cursor)
+	}
+	return query
+}
+
+func TestOrdersArePagedWithoutGapsOrDuplicates(t *testing.T) {
+	h := New(t, Options{})
+	accountId := uuid.NewV4().String()
+	base := time.Now().UTC().Truncate(time.Second)
+	var rows []pagedRow
+	for _, offset := range pageDates {
+		rows = append(rows, pagedRow{id: seedOrder(h, accountId, base.Add(offset)), createDate: base.Add(offset)})
+	}
+	// orders of other accounts are not listed
+	seedOrder(h, uuid.NewV4().String(), base)
+	expected := newestFirst(rows)
+
+	for _, limit := range []int{1, 2, 3, len(rows)} {
+		t.Run(fmt.Sprintf("limit %d", limit), func(t *testing.T) {
+			ids := walkPages(t, limit, func(cursor string) ([]string, string) {
+				query := pageQuery(limit, cursor)
+				query.Set("accountId", accountId)
+				var page response.OrderPageResponse
+				if status := h.GetJSON(h.OrderURL+"/api/order?"+query.Encode(), &page); status != http.StatusOK {
+					t.Fatalf("expected the page to be listed, got %d", status)
+				}
+				ids := []string{}
+				for _, order := range page.Items {
+					ids = append(ids, order.ProductOrderId)
+				}
+				return ids, page.NextCursor
+			})
+			if !reflect.DeepEqual(ids, expected) {
+				t.Fatalf("expected every order once newest first %v, got %v", expected, ids)
+			}
+		})
+	}
+}
+
+func seedOrder(h *Harness, accountId string, createDate time.Time) string {
+	h.t.Helper()
+	orderId := uuid.NewV4().String()
+	h.create(h.OrderDB, &orderModel.ProductOrder{
+		ProductOrderId: orderId,
+		AccountId:      accountId,
+		Status:         orderModel.OrderStatusConfirmed,
+		TotalAmount:    10,
+		Currency:       "EUR",
+		CreateDate:     createDate,
+		UpdateDate:     createDate,
+		RequestId:      uuid.NewV4().String(),
+	})
+	return orderId
+}
+
+func TestTransactionsArePagedWithoutGapsOrDuplicates(t *testing.T) {
+	h := New(t, Options{})
+	accountId := h.SeedAccount(100, "EUR")
+	otherAccountId := h.SeedAccount(100, "EUR")
+	base := time.Now().UTC().Truncate(time.Second)
+	var rows []pagedRow
+	for _, offset := range pageDates {
+		rows = append(rows, pagedRow{id: seedTransaction(h, accountId, base.Add(offset)), createDate: base.Add(offset)})
+	}
+	seedTransaction(h, otherAccountId, base)
+	expected := newestFirst(rows)
+
+	for _, limit := range []int{1, 2, 3, len(rows)} {
+		t.Run(fmt.Sprintf("limit %d", limit), func(t *testing.T) {
+			ids := walkPages(t, limit, func(cursor string) ([]string, string) {
+				var page paymentResponse.TransactionPageResponse
+				pageURL := h.PaymentURL + "/api/payment/account/" + accountId + "/transactions?" + pageQuery(limit, cursor).Encode()
+				if status := h.GetJSON(pageURL, &page); status != http.StatusOK {
+					t.Fatalf("expected the page to be listed, got %d", status)
+				}
+				ids := []string{}
+				for _, transaction := range page.Items {
+					ids = append(ids, transaction.TransactionId)
+				}
+				return ids, page.NextCursor
+			})
+			if !reflect.DeepEqual(ids, expected) {
+				t.Fatalf("expected every transaction once newest first %v, got %v", expected, ids)
+			}
+		})
+	}
+}
+
+func seedTransaction(h *Harness, accountId string, createDate time.Time) string {
+	h.t.Helper()
+	transactionId := uuid.NewV4().String()
+	h.create(h.PaymentDB, &paymentModel.Transaction{
+		TransactionId:    transactionId,
+		OrderId:          uuid.NewV4().String(),
+		Type:             paymentModel.TransactionTypeCharge,
+		Amount:           10,
+		Currency:         "EUR",
+		OriginalAmount:   10,
+		OriginalCurrency: "EUR",
+		FxRate:           1,
+		CreateDate:       createDate,
+		RequestId:        uuid.NewV4().String(),
+		AccountId:        accountId,
+	})
+	return transactionId
+}
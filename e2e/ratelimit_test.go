@@ -0,0 +1,199 @@
+package e2e
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	orderConfigs "order-service/configs"
+	"order-service/dto/request"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	uuid "github.com/satori/go.uuid"
+)
+
+// postOrder posts an order and returns the response with its headers, the body is closed already.
+func postOrder(t *testing.T, h *Harness, orderRequest request.OrderRequest) *http.Response {
+	t.Helper()
+	body, err := json.Marshal(orderRequest)
+	if err != nil {
+		t.Fatalf("marshal request: %v", err)
+	}
+	resp, err := http.Post(h.OrderURL+"/api/order/create", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("post order: %v", err)
+	}
+	resp.Body.Close()
+	return resp
+}
+
+func expectRateLimited(t *testing.T, resp *http.Response) {
+	t.Helper()
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", resp.StatusCode)
+	}
+	if retryAfter, err := strconv.Atoi(resp.Header.Get("Retry-After")); err != nil || retryAfter < 1 {
+		t.Fatalf("expected a Retry-After in seconds, got %q", resp.Header.Get("Retry-After"))
+	}
+	if resp.Header.Get("RateLimit-Remaining") != "0" {
+		t.Fatalf("expected no remaining requests, got %q", resp.Header.Get("RateLimit-Remaining"))
+	}
+}
+
+func TestOrdersAreRateLimitedPerAccount(t *testing.T) {
+	h := New(t, Options{RateLimits: orderConfigs.Config{
+		RateLimitEnabled:       true,
+		RateLimitWindowSeconds: 60,
+		RateLimitPerAccount:    2,
+		RateLimitPerIP:         10,
+	}})
+	productId := h.SeedProduct(10, "EUR", 10)
+	accountId := h.SeedAccount(100, "EUR")
+
+	for i, remaining := range []string{"1", "0"} {
+		resp := postOrder(t, h, orderRequest(uuid.NewV4().String(), accountId, productId, 1))
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected order %d to be accepted, got %d", i, resp.StatusCode)
+		}
+		if resp.Header.Get("RateLimit-Limit") != "2" || resp.Header.Get("RateLimit-Remaining") != remaining {
+			t.Fatalf("expected limit 2 with %s remaining, got %q and %q", remaining, resp.Header.Get("RateLimit-Limit"), resp.Header.Get("RateLimit-Remaining"))
+		}
+	}
+	expectRateLimited(t, postOrder(t, h, orderRequest(uuid.NewV4().String(), accountId, productId, 1)))
+	if amount := h.Account(accountId).Amount; amount != 80 {
+		t.Fatalf("expected two charges, got a balance of %v", amount)
+	}
+
+	// other accounts of the same client are not affected
+	otherAccount := h.SeedAccount(100, "EUR")
+	if resp := postOrder(t, h, orderRequest(uuid.NewV4().String(), otherAccount, productId, 1)); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected an order of another account to be accepted, got %d", resp.StatusCode)
+	}
+
+	// raised limits apply without a restart
+	h.OrderApp.ReloadRateLimits(&orderConfigs.Config{
+		RateLimitEnabled:       true,
+		RateLimitWindowSeconds: 60,
+		RateLimitPerAccount:    3,
+		RateLimitPerIP:         10,
+	})
+	if resp := postOrder(t, h, orderRequest(uuid.NewV4().String(), accountId, productId, 1)); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected the order to be accepted after the reload, got %d", resp.StatusCode)
+	}
+}
+
+func TestOrdersAreRateLimitedPerIPAndGlobally(t *testing.T) {
+	h := New(t, Options{RateLimits: orderConfigs.Config{
+		RateLimitEnabled:       true,
+		RateLimitWindowSeconds: 1,
+		RateLimitPerIP:         3,
+	}})
+	productId := h.SeedProduct(10, "EUR", 20)
+
+	// malformed orders count against the ip as well
+	if resp := postOrder(t, h, request.OrderRequest{}); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an empty order, got %d", resp.StatusCode)
+	}
+	for i := 0; i < 2; i++ {
+		if resp := postOrder(t, h, orderRequest(uuid.NewV4().String(), h.SeedAccount(100, "EUR"), productId, 1)); resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected order %d to be accepted, got %d", i, resp.StatusCode)
+		}
+	}
+	expectRateLimited(t, postOrder(t, h, orderRequest(uuid.NewV4().String(), h.SeedAccount(100, "EUR"), productId, 1)))
+
+	// the window slides on
+	time.Sleep(1100 * time.Millisecond)
+	if resp := postOrder(t, h, orderRequest(uuid.NewV4().String(), h.SeedAccount(100, "EUR"), productId, 1)); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected an order after the window to be accepted, got %d", resp.StatusCode)
+	}
+
+	h.OrderApp.ReloadRateLimits(&orderConfigs.Config{
+		RateLimitEnabled:       true,
+		RateLimitWindowSeconds: 60,
+		RateLimitGlobal:        1,
+	})
+	if resp := postOrder(t, h, orderRequest(uuid.NewV4().String(), h.SeedAccount(100, "EUR"), productId, 1)); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected the first order of the global window to be accepted, got %d", resp.StatusCode)
+	}
+	expectRateLimited(t, postOrder(t, h, orderRequest(uuid.NewV4().String(), h.SeedAccount(100, "EUR"), productId, 1)))
+
+	h.OrderApp.ReloadRateLimits(&orderConfigs.Config{})
+	if resp := postOrder(t, h, orderRequest(uuid.NewV4().String(), h.SeedAccount(100, "EUR"), productId, 1)); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected orders to be accepted with rate limiting disabled, got %d", resp.StatusCode)
+	}
+}
+
+func TestForwardedForIsOnlyTrustedFromConfiguredProxies(t *testing.T) {
+	postFrom := func(h *Harness, forwardedFor string, orderRequest request.OrderRequest) *http.Response {
+		t.Helper()
+		body, err := json.Marshal(orderRequest)
+		if err != nil {
+			t.Fatalf("marshal request: %v", err)
+		}
+		req, err := http.NewRequest(http.MethodPost, h.OrderURL+"/api/order/create", bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("build request: %v", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("post order: %v", err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+	limits := orderConfigs.Config{RateLimitEnabled: true, RateLimitWindowSeconds: 60, RateLimitPerIP: 1}
+
+	// a spoofed X-Forwarded-For does not get a client a fresh quota
+	h := New(t, Options{RateLimits: limits})
+	productId := h.SeedProduct(10, "EUR", 10)
+	if resp := postFrom(h, "203.0.113.1", orderRequest(uuid.NewV4().String(), h.SeedAccount(100, "EUR"), productId, 1)); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected the first order to be accepted, got %d", resp.StatusCode)
+	}
+	expectRateLimited(t, postFrom(h, "203.0.113.2", orderRequest(uuid.NewV4().String(), h.SeedAccount(100, "EUR"), productId, 1)))
+
+	// behind a trusted proxy every forwarded client is counted on its own
+	limits.TrustedProxies = "127.0.0.1, ::1"
+	h = New(t, Options{RateLimits: limits})
+	productId = h.SeedProduct(10, "EUR", 10)
+	for _, client := range []string{"203.0.113.1", "203.0.113.2"} {
+		if resp := postFrom(h, client, orderRequest(uuid.NewV4().String(), h.SeedAccount(100, "EUR"), productId, 1)); resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected the first order of %s to be accepted, got %d", client, resp.StatusCode)
+		}
+	}
+	expectRateLimited(t, postFrom(h, "203.0.113.1", orderRequest(uuid.NewV4().String(), h.SeedAccount(100, "EUR"), productId, 1)))
+}
+
+func TestAnonymousCallersCanNotUseUpTheQuotaOfACustomer(t *testing.T) {
+	h := New(t, Options{Verifier: hs256Verifier(t), RateLimits: orderConfigs.Config{
+		RateLimitEnabled:       true,
+		RateLimitWindowSeconds: 60,
+		RateLimitPerAccount:    2,
+		RateLimitPerIP:         20,
+	}})
+	productId := h.SeedProduct(10, "EUR", 10)
+	accountId := h.SeedCustomerAccount("alice", 100, "EUR")
+	alice := sign(t, jwt.SigningMethodHS256, authSecret, "", "alice", time.Minute)
+	bob := sign(t, jwt.SigningMethodHS256, authSecret, "", "bob", time.Minute)
+
+	for i := 0; i < 3; i++ {
+		if resp := postOrder(t, h, orderRequest(uuid.NewV4().String(), accountId, productId, 1)); resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("expected 401 without a token, got %d", resp.StatusCode)
+		}
+		// other customers are counted on their own subject, not on the account they name
+		if status := h.PostJSONWithToken(bob, h.OrderURL+"/api/order/create", orderRequest(uuid.NewV4().String(), accountId, productId, 1), nil); status != http.StatusForbidden && status != http.StatusTooManyRequests {
+			t.Fatalf("expected bob to be rejected, got %d", status)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if status := h.PostJSONWithToken(alice, h.OrderURL+"/api/order/create", orderRequest(uuid.NewV4().String(), accountId, productId, 1), nil); status != http.StatusOK {
+			t.Fatalf("expected order %d of alice to be accepted, got %d", i, status)
+		}
+	}
+	if status := h.PostJSONWithToken(alice, h.OrderURL+"/api/order/create", orderRequest(uuid.NewV4().String(), accountId, productId, 1), nil); status != http.StatusTooManyRequests {
+		t.Fatalf("expected alice to be limited after two orders, got %d", status)
+	}
+}
@@ -0,0 +1,99 @@
+package e2e
+
+import (
+	"net/http"
+	"order-service/dto/response"
+	orderModel "order-service/model"
+	"testing"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// a charge whose order was rolled back after the orchestration was deleted is only caught by the reconciliation.
+func TestReconciliationCompensatesOrphanedCharge(t *testing.T) {
+	h := New(t, Options{})
+	accountId := h.SeedAccount(100, "EUR")
+	requestId := uuid.NewV4().String()
+	status := h.PostPayment("", map[string]interface{}{
+		"requestId": requestId,
+		"uuid":      uuid.NewV4().String(),
+		"orderId":   uuid.NewV4().String(),
+		"amount":    30,
+		"currency":  "EUR",
+		"accountId": accountId,
+	})
+	if status != http.StatusOK {
+		t.Fatalf("expected payment to succeed, got %d", status)
+	}
+
+	run := h.Reconcile()
+
+	if len(run.Findings) != 1 {
+		t.Fatalf("expected a single finding, got %+v", run.Findings)
+	}
+	finding := run.Findings[0]
+	if finding.Kind != orderModel.FindingKindOrphanedCharge || finding.RequestId != requestId || !finding.CompensationTriggered {
+		t.Fatalf("expected a compensated orphaned charge of %s, got %+v", requestId, finding)
+	}
+	if finding.ChargedAmount != 30 || finding.Currency != "EUR" {
+		t.Fatalf("expected 30 EUR charged, got %v %s", finding.ChargedAmount, finding.Currency)
+	}
+	h.Eventually(sagaTimeout, func() bool {
+		return h.Account(accountId).Amount == 100
+	}, "account %s refunded", accountId)
+
+	run = h.Reconcile()
+
+	if len(run.Findings) != 0 || run.Resolved != 1 {
+		t.Fatalf("expected the finding to be resolved, got %+v", run)
+	}
+	var stored response.FindingResponse
+	if status := h.GetJSON(h.OrderURL+"/api/reconciliation/findings/"+finding.FindingId, &stored); status != http.StatusOK {
+		t.Fatalf("expected the finding to be stored, got %d", status)
+	}
+	if stored.Status != orderModel.FindingStatusResolved || stored.ResolvedDate == nil {
+		t.Fatalf("expected a resolved finding, got %+v", stored)
+	}
+}
+
+func TestReconciliationReportsConfirmedOrderWithoutCharge(t *testing.T) {
+	h := New(t, Options{})
+	productId := h.SeedProduct(20, "EUR", 10)
+	accountId := h.SeedAccount(100, "EUR")
+	confirmedId := uuid.NewV4().String()
+	if status, _ := h.CreateOrder(orderRequest(confirmedId, accountId, productId, 1)); status != http.StatusOK {
+		t.Fatalf("expected 200, got %d", status)
+	}
+	uncharged := uuid.NewV4().String()
+	now := time.Now().UTC()
+	h.create(h.OrderDB, &orderModel.ProductOrder{
+		ProductOrderId: uuid.NewV4().String(),
+		AccountId:      accountId,
+		Status:         orderModel.OrderStatusConfirmed,
+		TotalAmount:    20,
+		Currency:       "EUR",
+		CreateDate:     now,
+		UpdateDate:     now,
+		RequestId:      uncharged,
+	})
+
+	run := h.Reconcile()
+
+	if run.Orders != 2 || run.Transactions != 1 {
+		t.Fatalf("expected 2 orders and 1 transaction in the window, got %d and %d", run.Orders, run.Transactions)
+	}
+	if len(run.Findings) != 1 {
+		t.Fatalf("expected a single finding, got %+v", run.Findings)
+	}
+	if finding := run.Findings[0]; finding.Kind != orderModel.FindingKindMissingCharge || finding.RequestId != uncharged || finding.CompensationTriggered {
+		t.Fatalf("expected an uncompensated missing charge of %s, got %+v", uncharged, finding)
+	}
+	var page response.FindingPageResponse
+	if status := h.GetJSON(h.OrderURL+"/api/reconciliation/findings?status=OPEN&kind="+orderModel.FindingKindMissingCharge, &page); status != http.StatusOK {
+		t.Fatalf("expected 200, got %d", status)
+	}
+	if len(page.Items) != 1 || page.Items[0].RequestId != uncharged {
+		t.Fatalf("expected the open finding to be listed, got %+v", page.Items)
+	}
+}
@@ -0,0 +1,289 @@
+package e2e
+
+import (
+	"common/broker"
+	"common/statestore"
+	"context"
+	"errors"
+	inventoryModel "inventory-service/model"
+	"net/http"
+	"order-service/dto/request"
+	orderModel "order-service/model"
+	paymentModel "payment-service/model"
+	"testing"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+const sagaTimeout = 5 * time.Second
+
+func TestOrderIsConfirmed(t *testing.T) {
+	h := New(t, Options{})
+	productId := h.SeedProduct(20, "EUR", 10)
+	accountId := h.SeedAccount(100, "EUR")
+	requestId := uuid.NewV4().String()
+
+	status, order := h.CreateOrder(orderRequest(requestId, accountId, productId, 2))
+
+	if status != http.StatusOK {
+		t.Fatalf("expected 200, got %d", status)
+	}
+	if order.Status != orderModel.OrderStatusConfirmed || order.TotalAmount != 40 {
+		t.Fatalf("expected confirmed order of 40, got %s of %v", order.Status, order.TotalAmount)
+	}
+	if amount := h.Account(accountId).Amount; amount != 60 {
+		t.Fatalf("expected account balance 60, got %v", amount)
+	}
+	if stock := h.Stock(productId); stock != 8 {
+		t.Fatalf("expected stock 8, got %d", stock)
+	}
+	if _, err := h.Store.Get(context.Background(), requestId); !errors.Is(err, statestore.ErrNotFound) {
+		t.Fatalf("expected orchestration to be ended, got %v", err)
+	}
+}
+
+func TestAsyncOrderIsConfirmedByWorkers(t *testing.T) {
+	h := New(t, Options{ProcessingMode: "async"})
+	productId := h.SeedProduct(20, "EUR", 10)
+	accountId := h.SeedAccount(100, "EUR")
+	requestId := uuid.NewV4().String()
+
+	status, order := h.CreateOrder(orderRequest(requestId, accountId, productId, 1))
+
+	if status != http.StatusAccepted || order.Status != orderModel.OrderStatusPending {
+		t.Fatalf("expected 202 with a pending order, got %d with %s", status, order.Status)
+	}
+	h.Eventually(sagaTimeout, func() bool {
+		return h.Order(requestId).Status == orderModel.OrderStatusConfirmed
+	}, "order %s confirmed", requestId)
+	if amount := h.Account(accountId).Amount; amount != 80 {
+		t.Fatalf("expected account balance 80, got %v", amount)
+	}
+}
+
+func TestDeclinedPaymentCompensatesOrder(t *testing.T) {
+	h := New(t, Options{})
+	productId := h.SeedProduct(20, "EUR", 10)
+	accountId := h.SeedAccount(10, "EUR")
+	requestId := uuid.NewV4().String()
+
+	status, _ := h.CreateOrder(orderRequest(requestId, accountId, productId, 1))
+
+	if status != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", status)
+	}
+	h.Eventually(sagaTimeout, func() bool {
+		return h.Order(requestId).Status == orderModel.OrderStatusCompensated
+	}, "order %s compensated", requestId)
+	h.Eventually(sagaTimeout, func() bool {
+		return h.Stock(productId) == 10
+	}, "stock of %s released", productId)
+	if amount := h.Account(accountId).Amount; amount != 10 {
+		t.Fatalf("expected account balance 10, got %v", amount)
+	}
+	if transactions := h.Transactions(requestId); len(transactions) != 0 {
+		t.Fatalf("expected no transactions, got %d", len(transactions))
+	}
+	expectPaymentRolledBack(t, h, requestId)
+}
+
+func TestOutOfStockCompensatesOrder(t *testing.T) {
+	h := New(t, Options{})
+	productId := h.SeedProduct(20, "EUR", 1)
+	accountId := h.SeedAccount(100, "EUR")
+	requestId := uuid.NewV4().String()
+
+	status, _ := h.CreateOrder(orderRequest(requestId, accountId, productId, 2))
+
+	if status != http.StatusConflict {
+		t.Fatalf("expected 409, got %d", status)
+	}
+	h.Eventually(sagaTimeout, func() bool {
+		return h.Order(requestId).Status == orderModel.OrderStatusCompensated
+	}, "order %s compensated", requestId)
+	if amount := h.Account(accountId).Amount; amount != 100 {
+		t.Fatalf("expected account balance 100, got %v", amount)
+	}
+	if stock := h.Stock(productId); stock != 1 {
+		t.Fatalf("expected stock 1, got %d", stock)
+	}
+	expectPaymentRolledBack(t, h, requestId)
+}
+
+// expectPaymentRolledBack waits for the payment rollback of a request that was never charged, which is handled
+// by leaving a tombstone and not parked in the dead-letter queue.
+func expectPaymentRolledBack(t *testing.T, h *Harness, requestId string) {
+	t.Helper()
+	h.Eventually(sagaTimeout, func() bool {
+		var tombstones int64
+		h.PaymentDB.Model(&paymentModel.Settlement{}).Where("request_id = ? AND status = ?", requestId, paymentModel.SettlementStatusRolledBack).Count(&tombstones)
+		return tombstones == 1 && h.Broker.Len(rollbackPaymentQueue) == 0
+	}, "payment rollback of %s handled", requestId)
+	if dead := h.Broker.Len(deadLetterQueue); dead != 0 {
+		t.Fatalf("expected no dead-lettered messages, got %d", dead)
+	}
+}
+
+// an order-service that dies after charging leaves the orchestration behind, it expires and both sides are rolled back.
+func TestExpiredOrchestrationRollsBackBothSides(t *testing.T) {
+	h := New(t, Options{})
+	productId := h.SeedProduct(20, "EUR", 10)
+	accountId := h.SeedAccount(100, "EUR")
+	requestId := uuid.NewV4().String()
+	orderId := uuid.NewV4().String()
+	now := time.Now().UTC()
+
+	h.create(h.OrderDB, &orderModel.ProductOrder{
+		ProductOrderId: orderId,
+		AccountId:      accountId,
+		Status:         orderModel.OrderStatusPending,
+		TotalAmount:    20,
+		Currency:       "EUR",
+		CreateDate:     now,
+		UpdateDate:     now,
+		RequestId:      requestId,
+	})
+	status := h.PostJSON(h.InventoryURL+"/api/inventory/reserve", map[string]interface{}{
+		"requestId": requestId,
+		"items":     []map[string]interface{}{{"productId": productId, "quantity": 1}},
+	}, nil)
+	if status != http.StatusOK {
+		t.Fatalf("expected reservation to succeed, got %d", status)
+	}
+	status = h.PostPayment("", map[string]interface{}{
+		"requestId": requestId,
+		"uuid":      uuid.NewV4().String(),
+		"orderId":   orderId,
+		"amount":    20,
+		"currency":  "EUR",
+		"accountId": accountId,
+	})
+	if status != http.StatusOK {
+		t.Fatalf("expected payment to succeed, got %d", status)
+	}
+	err := h.Store.Create(context.Background(), statestore.Record{
+		UUID:           requestId,
+		Status:         "IN_PROGRESS",
+		ExpirationTime: now.Add(-time.Second).UnixMilli(),
+	})
+	if err != nil {
+		t.Fatalf("create orchestration: %v", err)
+	}
+
+	h.Eventually(sagaTimeout, func() bool {
+		return h.Order(requestId).Status == orderModel.OrderStatusCompensated
+	}, "order %s compensated", requestId)
+	h.Eventually(sagaTimeout, func() bool {
+		return h.Account(accountId).Amount == 100
+	}, "account %s refunded", accountId)
+	h.Eventually(sagaTimeout, func() bool {
+		return h.Stock(productId) == 10
+	}, "stock of %s released", productId)
+	transactions := h.Transactions(requestId)
+	if len(transactions) != 2 || transactions[1].Type != paymentModel.TransactionTypeRefund {
+		t.Fatalf("expected a charge and a refund, got %+v", transactions)
+	}
+	if _, err := h.Store.Get(context.Background(), requestId); !errors.Is(err, statestore.ErrNotFound) {
+		t.Fatalf("expected orchestration to be removed, got %v", err)
+	}
+}
+
+// the rollback of a request overtakes its reservation, the tombstone it leaves keeps the late reservation from
+// holding the stock forever.
+func TestReservationArrivingAfterItsRollbackIsRefused(t *testing.T) {
+	h := New(t, Options{})
+	productId := h.SeedProduct(20, "EUR", 10)
+	requestId := uuid.NewV4().String()
+
+	rollback := broker.Message{Body: []byte(requestId)}
+	h.MessageSigner.SignMessage(&rollback)
+	if err := h.Broker.Publish(context.Background(), rollbackInventoryQueue, rollback); err != nil {
+		t.Fatalf("publish rollback: %v", err)
+	}
+	h.Eventually(sagaTimeout, func() bool {
+		var tombstones int64
+		h.InventoryDB.Model(&inventoryModel.Reservation{}).Where("request_id = ? AND status = ?", requestId, inventoryModel.ReservationStatusReleased).Count(&tombstones)
+		return tombstones == 1
+	}, "tombstone of %s recorded", requestId)
+
+	reservation := map[string]interface{}{
+		"requestId": requestId,
+		"items":     []map[string]interface{}{{"productId": productId, "quantity": 3}},
+	}
+	if status := h.PostJSON(h.InventoryURL+"/api/inventory/reserve", reservation, nil); status != http.StatusConflict {
+		t.Fatalf("expected the late reservation to be refused, got %d", status)
+	}
+	if stock := h.Stock(productId); stock != 10 {
+		t.Fatalf("expected stock 10, got %d", stock)
+	}
+}
+
+func TestChargeArrivingAfterItsRollbackIsRefused(t *testing.T) {
+	h := New(t, Options{})
+	accountId := h.SeedAccount(100, "EUR")
+	requestId := uuid.NewV4().String()
+
+	rollback := broker.Message{Body: []byte(requestId)}
+	h.MessageSigner.SignMessage(&rollback)
+	if err := h.Broker.Publish(context.Background(), rollbackPaymentQueue, rollback); err != nil {
+		t.Fatalf("publish rollback: %v", err)
+	}
+	expectPaymentRolledBack(t, h, requestId)
+
+	status := h.PostPayment("", map[string]interface{}{
+		"requestId": requestId,
+		"uuid":      uuid.NewV4().String(),
+		"orderId":   uuid.NewV4().String(),
+		"amount":    20,
+		"currency":  "EUR",
+		"accountId": accountId,
+	})
+	if status != http.StatusConflict {
+		t.Fatalf("expected the late charge to be refused, got %d", status)
+	}
+	if amount := h.Account(accountId).Amount; amount != 100 {
+		t.Fatalf("expected account balance 100, got %v", amount)
+	}
+	if transactions := h.Transactions(requestId); len(transactions) != 0 {
+		t.Fatalf("expected no transactions, got %d", len(transactions))
+	}
+}
+
+func TestReservationIsTakenOncePerRequest(t *testing.T) {
+	h := New(t, Options{})
+	productId := h.SeedProduct(20, "EUR", 10)
+	requestId := uuid.NewV4().String()
+	// lines of the same product are held by a single reservation
+	reservation := map[string]interface{}{
+		"requestId": requestId,
+		"items":     []map[string]interface{}{{"productId": productId, "quantity": 1}, {"productId": productId, "quantity": 2}},
+	}
+
+	for i := 0; i < 2; i++ {
+		if status := h.PostJSON(h.InventoryURL+"/api/inventory/reserve", reservation, nil); status != http.StatusOK {
+			t.Fatalf("expected reservation %d to succeed, got %d", i, status)
+		}
+	}
+	if stock := h.Stock(productId); stock != 7 {
+		t.Fatalf("expected stock 7, got %d", stock)
+	}
+
+	if status := h.PostJSON(h.InventoryURL+"/api/inventory/release", map[string]interface{}{"requestId": requestId}, nil); status != http.StatusOK {
+		t.Fatalf("expected release to succeed, got %d", status)
+	}
+	if stock := h.Stock(productId); stock != 10 {
+		t.Fatalf("expected stock 10 after the release, got %d", stock)
+	}
+	if status := h.PostJSON(h.InventoryURL+"/api/inventory/reserve", reservation, nil); status != http.StatusConflict {
+		t.Fatalf("expected a released request not to be reserved again, got %d", status)
+	}
+}
+
+func orderRequest(requestId string, accountId string, productId string, quantity int) request.OrderRequest {
+	return request.OrderRequest{
+		Items:     []request.OrderItemRequest{{ProductId: productId, Quantity: quantity}},
+		RequestId: requestId,
+		AccountID: accountId,
+	}
+}
@@ -0,0 +1,170 @@
+package e2e
+
+import (
+	"bytes"
+	"common/signing"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+func paymentBody(t *testing.T, accountId string, amount float64) []byte {
+	t.Helper()
+	body, err := json.Marshal(map[string]interface{}{
+		"requestId": uuid.NewV4().String(),
+		"uuid":      uuid.NewV4().String(),
+		"orderId":   uuid.NewV4().String(),
+		"amount":    amount,
+		"currency":  "EUR",
+		"accountId": accountId,
+	})
+	if err != nil {
+		t.Fatalf("marshal request: %v", err)
+	}
+	return body
+}
+
+// sendPayment posts body with the signature headers of signed, which may have been made for another body.
+func sendPayment(t *testing.T, h *Harness, body []byte, signed http.Header) int {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, h.PaymentURL+"/api/payment/process", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("build request: %v", err)
+	}
+	req.Header = signed.Clone()
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("post payment: %v", err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func signatureOf(t *testing.T, signer *signing.Signer, body []byte) http.Header {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, "http://payment/api/payment/process", nil)
+	if err != nil {
+		t.Fatalf("build request: %v", err)
+	}
+	if err := signer.Sign(req, body); err != nil {
+		t.Fatalf("sign request: %v", err)
+	}
+	return req.Header
+}
+
+func TestPaymentsRequireAValidSignature(t *testing.T) {
+	h := New(t, Options{})
+	accountId := h.SeedAccount(100, "EUR")
+
+	if status := sendPayment(t, h, paymentBody(t, accountId, 10), http.Header{}); status != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for an unsigned payment, got %d", status)
+	}
+
+	// the signature of a 10 EUR payment does not carry a zero amount
+	signed := signatureOf(t, h.Signer, paymentBody(t, accountId, 10))
+	if status := sendPayment(t, h, paymentBody(t, accountId, 0), signed); status != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for a tampered body, got %d", status)
+	}
+
+	forged := signatureOf(t, signing.NewSigner(signingKeyId, "guessed"), paymentBody(t, accountId, 10))
+	if status := sendPayment(t, h, paymentBody(t, accountId, 10), forged); status != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for a wrong secret, got %d", status)
+	}
+
+	zero := paymentBody(t, accountId, 0)
+	if status := sendPayment(t, h, zero, signatureOf(t, h.Signer, zero)); status != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a zero amount, got %d", status)
+	}
+
+	if amount := h.Account(accountId).Amount; amount != 100 {
+		t.Fatalf("expected the account untouched, got %v", amount)
+	}
+}
+
+func TestReplayedPaymentIsRejected(t *testing.T) {
+	h := New(t, Options{})
+	accountId := h.SeedAccount(100, "EUR")
+	body := paymentBody(t, accountId, 10)
+	signed := signatureOf(t, h.Signer, body)
+
+	if status := sendPayment(t, h, body, signed); status != http.StatusOK {
+		t.Fatalf("expected the payment to succeed, got %d", status)
+	}
+	if status := sendPayment(t, h, body, signed); status != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for a replay, got %d", status)
+	}
+	if amount := h.Account(accountId).Amount; amount != 90 {
+		t.Fatalf("expected a single charge, got a balance of %v", amount)
+	}
+}
+
+func TestRetiredSigningKeyIsAcceptedDuringRotation(t *testing.T) {
+	h := New(t, Options{})
+	productId := h.SeedProduct(20, "EUR", 10)
+	accountId := h.SeedAccount(100, "EUR")
+
+	// orders are signed with the current key
+	if status, _ := h.CreateOrder(orderRequest(uuid.NewV4().String(), accountId, productId, 1)); status != http.StatusOK {
+		t.Fatalf("expected 200, got %d", status)
+	}
+
+	body := paymentBody(t, accountId, 10)
+	retired := signatureOf(t, signing.NewSigner(retiredSigningKeyId, retiredSigningKey), body)
+	if status := sendPayment(t, h, body, retired); status != http.StatusOK {
+		t.Fatalf("expected the retired key to be accepted, got %d", status)
+	}
+
+	unknown := paymentBody(t, accountId, 10)
+	if status := sendPayment(t, h, unknown, signatureOf(t, signing.NewSigner("e2e-0", retiredSigningKey), unknown)); status != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for an unknown key, got %d", status)
+	}
+	if amount := h.Account(accountId).Amount; amount != 70 {
+		t.Fatalf("expected account balance 70, got %v", amount)
+	}
+}
+
+// getSigned signs a call of the reconciliation listing with signedQuery and sends it with sentQuery.
+func getSigned(t *testing.T, h *Harness, signedQuery string, sentQuery string) int {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, h.PaymentURL+"/api/payment/transactions?"+signedQuery, nil)
+	if err != nil {
+		t.Fatalf("build request: %v", err)
+	}
+	if err := h.Signer.Sign(req, nil); err != nil {
+		t.Fatalf("sign request: %v", err)
+	}
+	req.URL.RawQuery = sentQuery
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("list transactions: %v", err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestSignatureCoversTheQuery(t *testing.T) {
+	h := New(t, Options{})
+
+	if status := getSigned(t, h, "type=CHARGE&limit=5", "type=CHARGE&limit=5"); status != http.StatusOK {
+		t.Fatalf("expected the signed query to be accepted, got %d", status)
+	}
+	// the query is compared in its canonical form, the order and escaping of the parameters do not matter
+	if status := getSigned(t, h, "type=CHARGE&limit=5", "limit=%35&type=CHARGE"); status != http.StatusOK {
+		t.Fatalf("expected the reordered query to be accepted, got %d", status)
+	}
+
+	tampered := map[string]string{
+		"changed parameter": "type=REFUND&limit=5",
+		"added parameter":   "type=CHARGE&limit=5&cursor=x",
+		"removed parameter": "type=CHARGE",
+		"dropped query":     "",
+	}
+	for name, query := range tampered {
+		if status := getSigned(t, h, "type=CHARGE&limit=5", query); status != http.StatusUnauthorized {
+			t.Fatalf("expected 401 for a %s, got %d", name, status)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package e2e
+
+import (
+	"common/statestore"
+	"context"
+	"errors"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	orderMigration "order-service/migration"
+	"sync"
+	"testing"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// orchestrationTable stands in for the orchestrations table of the order-service migrations on SQLite.
+type orchestrationTable struct {
+	UUID           string `gorm:"column:uuid;type:varchar(512);primaryKey"`
+	Status         string `gorm:"not null"`
+	ExpirationTime int64  `gorm:"not null"`
+	Version        int64  `gorm:"not null"`
+}
+
+func (orchestrationTable) TableName() string {
+	return statestore.TableName
+}
+
+// every store has to keep the same contract, the saga relies on a swap of a changed record being refused.
+func TestStateStores(t *testing.T) {
+	stores := map[string]func(t *testing.T) statestore.StateStore{
+		"memory": func(t *testing.T) statestore.StateStore {
+			return statestore.NewMemoryStore()
+		},
+		"redis": func(t *testing.T) statestore.StateStore {
+			client := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+			t.Cleanup(func() { client.Close() })
+			return statestore.NewRedisStore(client, orchestrationMapName)
+		},
+		"postgres on sqlite": func(t *testing.T) statestore.StateStore {
+			return statestore.NewPostgresStore(openDB(t, "store", &orchestrationTable{}))
+		},
+		"postgres": func(t *testing.T) statestore.StateStore {
+			db := postgresSchema(t, postgresDSN(t), "store")
+			migrator, err := orderMigration.NewMigrator(db)
+			if err != nil {
+				t.Fatalf("load order migrations: %v", err)
+			}
+			if _, err := migrator.Up(); err != nil {
+				t.Fatalf("migrate order database: %v", err)
+			}
+			return statestore.NewPostgresStore(db)
+		},
+	}
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			t.Run("create", func(t *testing.T) { testStoreCreate(t, newStore(t)) })
+			t.Run("compare and swap", func(t *testing.T) { testStoreCompareAndSwap(t, newStore(t)) })
+			t.Run("concurrent swaps", func(t *testing.T) { testStoreConcurrentSwaps(t, newStore(t)) })
+			t.Run("expiry", func(t *testing.T) { testStoreExpiry(t, newStore(t)) })
+		})
+	}
+}
+
+func newRecord(status string, expiresIn time.Duration) statestore.Record {
+	return statestore.Record{
+		UUID:           uuid.NewV4().String(),
+		Status:         status,
+		ExpirationTime: time.Now().Add(expiresIn).UnixMilli(),
+	}
+}
+
+func getRecord(t *testing.T, store statestore.StateStore, id string) statestore.Record {
+	t.Helper()
+	record, err := store.Get(context.Background(), id)
+	if err != nil {
+		t.Fatalf("get %s: %v", id, err)
+	}
+	return *record
+}
+
+func testStoreCreate(t *testing.T, store statestore.StateStore) {
+	ctx := context.Background()
+	record := newRecord("PENDING", time.Minute)
+	if err := store.Create(ctx, record); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	duplicate := newRecord("CONFIRMED", time.Hour)
+	duplicate.UUID = record.UUID
+	if err := store.Create(ctx, duplicate); !errors.Is(err, statestore.ErrExists) {
+		t.Fatalf("expected a second record with the same id to be refused, got %v", err)
+	}
+	if stored := getRecord(t, store, record.UUID); stored != record {
+		t.Fatalf("expected %+v to be stored, got %+v", record, stored)
+	}
+	if _, err := store.Get(ctx, uuid.NewV4().String()); !errors.Is(err, statestore.ErrNotFound) {
+		t.Fatalf("expected an unknown record not to be found, got %v", err)
+	}
+
+	if err := store.Delete(ctx, record.UUID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := store.Get(ctx, record.UUID); !errors.Is(err, statestore.ErrNotFound) {
+		t.Fatalf("expected the deleted record not to be found, got %v", err)
+	}
+}
+
+func testStoreCompareAndSwap(t *testing.T, store statestore.StateStore) {
+	ctx := context.Background()
+	record := newRecord("PENDING", time.Minute)
+	if err := store.Create(ctx, record); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	next := record
+	next.Status = "CONFIRMED"
+	next.ExpirationTime = record.ExpirationTime + 1000
+	if err := store.CompareAndSwap(ctx, record, next); err != nil {
+		t.Fatalf("swap: %v", err)
+	}
+	swapped := getRecord(t, store, record.UUID)
+	next.Version = record.Version + 1
+	if swapped != next {
+		t.Fatalf("expected %+v after the swap, got %+v", next, swapped)
+	}
+
+	// the record read before the swap is stale now, whichever of its fields changed
+	stale := []statestore.Record{record, swapped, swapped, swapped}
+	stale[1].Version = record.Version
+	stale[2].Status = "PENDING"
+	stale[3].ExpirationTime = record.ExpirationTime
+	for _, expected := range stale {
+		rollback := expected
+		rollback.Status = "ROLLBACK"
+		if err := store.CompareAndSwap(ctx, expected, rollback); !errors.Is(err, statestore.ErrConflict) {
+			t.Fatalf("expected a swap of %+v to conflict, got %v", expected, err)
+		}
+	}
+	if stored := getRecord(t, store, record.UUID); stored != swapped {
+		t.Fatalf("expected refused swaps to leave %+v, got %+v", swapped, stored)
+	}
+
+	missing := newRecord("PENDING", time.Minute)
+	if err := store.CompareAndSwap(ctx, missing, missing); !errors.Is(err, statestore.ErrNotFound) {
+		t.Fatalf("expected a swap of an unknown record not to find it, got %v", err)
+	}
+}
+
+// swaps racing from the same read leave a single winner, the others have to read the record again.
+func testStoreConcurrentSwaps(t *testing.T, store statestore.StateStore) {
+	ctx := context.Background()
+	record := newRecord("PENDING", time.Minute)
+	if err := store.Create(ctx, record); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	// records of other orchestrations change meanwhile, which must not be taken for a conflict
+	other := newRecord("PENDING", time.Minute)
+	if err := store.Create(ctx, other); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	const swaps = 10
+	results := make(chan error, swaps)
+	var wg sync.WaitGroup
+	for i := 0; i < swaps; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			next := record
+			next.Status = "CONFIRMED"
+			next.ExpirationTime = record.ExpirationTime + int64(i)
+			results <- store.CompareAndSwap(ctx, record, next)
+		}(i)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		next := other
+		next.Status = "ROLLBACK"
+		if err := store.CompareAndSwap(ctx, other, next); err != nil {
+			t.Errorf("swap of another record: %v", err)
+		}
+	}()
+	wg.Wait()
+	close(results)
+
+	won := 0
+	for err := range results {
+		switch {
+		case err == nil:
+			won++
+		case !errors.Is(err, statestore.ErrConflict):
+			t.Fatalf("expected a lost swap to conflict, got %v", err)
+		}
+	}
+	if won != 1 {
+		t.Fatalf("expected a single swap to win, got %d", won)
+	}
+	if stored := getRecord(t, store, record.UUID); stored.Version != record.Version+1 || stored.Status != "CONFIRMED" {
+		t.Fatalf("expected the record swapped once, got %+v", stored)
+	}
+}
+
+func testStoreExpiry(t *testing.T, store statestore.StateStore) {
+	ctx := context.Background()
+	expired := newRecord("PENDING", -time.Minute)
+	running := newRecord("PENDING", time.Minute)
+	for _, record := range []statestore.Record{expired, running} {
+		if err := store.Create(ctx, record); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+	}
+
+	records, err := store.ListExpired(ctx, time.Now())
+	if err != nil {
+		t.Fatalf("list expired: %v", err)
+	}
+	if len(records) != 1 || records[0] != expired {
+		t.Fatalf("expected only %+v to be expired, got %+v", expired, records)
+	}
+	records, err = store.List(ctx)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected both records to be listed, got %+v", records)
+	}
+}
@@ -0,0 +1,150 @@
+package e2e
+
+import (
+	"bytes"
+	"common/validation"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+type validationResponse struct {
+	Status string            `json:"status"`
+	Errors validation.Errors `json:"errors"`
+}
+
+// postInvalid posts body and expects a 400 listing the field errors, which are returned by field.
+func postInvalid(t *testing.T, url string, body string) map[string]string {
+	t.Helper()
+	resp, err := http.Post(url, "application/json", bytes.NewReader([]byte(body)))
+	if err != nil {
+		t.Fatalf("post %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 from %s, got %d", url, resp.StatusCode)
+	}
+	var rejection validationResponse
+	if err := json.NewDecoder(resp.Body).Decode(&rejection); err != nil {
+		t.Fatalf("decode response of %s: %v", url, err)
+	}
+	rules := map[string]string{}
+	for _, fieldError := range rejection.Errors {
+		if fieldError.Message == "" {
+			t.Fatalf("expected a message for %s", fieldError.Field)
+		}
+		rules[fieldError.Field] = fieldError.Rule
+	}
+	return rules
+}
+
+func expectRules(t *testing.T, rules map[string]string, expected map[string]string) {
+	t.Helper()
+	if len(rules) != len(expected) {
+		t.Fatalf("expected field errors %v, got %v", expected, rules)
+	}
+	for field, rule := range expected {
+		if rules[field] != rule {
+			t.Fatalf("expected %s to fail %s, got %v", field, rule, rules)
+		}
+	}
+}
+
+func TestOrderValidationReportsAllFieldErrors(t *testing.T) {
+	h := New(t, Options{})
+
+	rules := postInvalid(t, h.OrderURL+"/api/order/create", `{
+		"requestId": "not-a-uuid",
+		"items": [{"productId": "", "quantity": 1}, {"productId": "`+uuid.NewV4().String()+`", "quantity": 0}]
+	}`)
+	expectRules(t, rules, map[string]string{
+		"requestId":          "uuid",
+		"accountId":          "required",
+		"items[0].productId": "uuid",
+		"items[1].quantity":  "gt",
+	})
+
+	expectRules(t, postInvalid(t, h.OrderURL+"/api/order/create", `{"requestId": "`+uuid.NewV4().String()+`", "accountId": "`+uuid.NewV4().String()+`", "items": []}`),
+		map[string]string{"items": "min"})
+	expectRules(t, postInvalid(t, h.OrderURL+"/api/order/create", `{"requestId": 1}`),
+		map[string]string{"requestId": "type", "accountId": "required", "items": "required"})
+	expectRules(t, postInvalid(t, h.OrderURL+"/api/order/create", `{`), map[string]string{"body": "format"})
+
+	resp, err := http.Get(h.OrderURL + "/api/order?status=SHIPPED&accountId=1&limit=-1")
+	if err != nil {
+		t.Fatalf("list orders: %v", err)
+	}
+	defer resp.Body.Close()
+	var rejection validationResponse
+	if err := json.NewDecoder(resp.Body).Decode(&rejection); err != nil || resp.StatusCode != http.StatusBadRequest || len(rejection.Errors) != 3 {
+		t.Fatalf("expected 400 with three field errors, got %d with %+v (%v)", resp.StatusCode, rejection, err)
+	}
+}
+
+func TestPaymentAndReservationValidation(t *testing.T) {
+	h := New(t, Options{})
+	accountId := h.SeedAccount(100, "EUR")
+
+	status := h.PostPayment("", map[string]interface{}{
+		"requestId": uuid.NewV4().String(),
+		"orderId":   uuid.NewV4().String(),
+		"amount":    -5,
+		"currency":  "EURO",
+		"accountId": accountId,
+	})
+	if status != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a negative amount in an unknown currency, got %d", status)
+	}
+	// well formed payments the payment-service can not make are told apart from server errors
+	payment := func(accountId string, currency string) map[string]interface{} {
+		return map[string]interface{}{
+			"requestId": uuid.NewV4().String(),
+			"uuid":      uuid.NewV4().String(),
+			"orderId":   uuid.NewV4().String(),
+			"amount":    5,
+			"currency":  currency,
+			"accountId": accountId,
+		}
+	}
+	if status := h.PostPayment("", payment(uuid.NewV4().String(), "EUR")); status != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown account, got %d", status)
+	}
+	if status := h.PostPayment("", payment(accountId, "JPY")); status != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for a currency without fx rate, got %d", status)
+	}
+
+	// the payment uuid keeps retries from charging twice, a payment without one is rejected up front
+	status = h.PostPayment("", map[string]interface{}{
+		"requestId": uuid.NewV4().String(),
+		"orderId":   uuid.NewV4().String(),
+		"amount":    5,
+		"currency":  "EUR",
+		"accountId": accountId,
+	})
+	if status != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a payment without uuid, got %d", status)
+	}
+	// the amount is only meaningful with its currency, there is no fallback to the account currency
+	status = h.PostPayment("", map[string]interface{}{
+		"requestId": uuid.NewV4().String(),
+		"uuid":      uuid.NewV4().String(),
+		"orderId":   uuid.NewV4().String(),
+		"amount":    5,
+		"accountId": accountId,
+	})
+	if status != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a payment without currency, got %d", status)
+	}
+	if amount := h.Account(accountId).Amount; amount != 100 {
+		t.Fatalf("expected the account untouched, got %v", amount)
+	}
+
+	expectRules(t, postInvalid(t, h.InventoryURL+"/api/inventory/reserve", `{"items": [{"productId": "x", "quantity": 5000}]}`), map[string]string{
+		"requestId":          "required",
+		"items[0].productId": "uuid",
+		"items[0].quantity":  "lte",
+	})
+	expectRules(t, postInvalid(t, h.InventoryURL+"/api/inventory/release", `{}`), map[string]string{"requestId": "required"})
+}
@@ -0,0 +1,69 @@
+package app
+
+import (
+	"common/broker"
+	"common/fault"
+	"common/signing"
+	"context"
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"inventory-service/configs"
+	"inventory-service/handler"
+	"inventory-service/orchestration"
+	"inventory-service/repository"
+	"inventory-service/route"
+	"inventory-service/service"
+	"log"
+)
+
+// Dependencies are the connections the inventory-service runs on, main dials the real ones
+// and the end-to-end tests pass in-process stand-ins.
+type Dependencies struct {
+	DB     *gorm.DB
+	Broker broker.Broker
+	// verifies the consumed rollback messages, nil accepts unsigned ones
+	MessageVerifier *signing.MessageVerifier
+}
+
+// App is the wired inventory-service, Start runs its rollback consumer and Router serves its API.
+type App struct {
+	Router           *gin.Engine
+	rollbackConsumer *orchestration.RollbackConsumer
+}
+
+func New(cfg *configs.Config, deps Dependencies) *App {
+	stockRepository := repository.NewStockRepository()
+	stockReservationRepository := repository.NewStockReservationRepository()
+	inventoryService := service.NewInventoryService(deps.DB, stockRepository, stockReservationRepository, repository.NewReservationRepository())
+
+	inventoryController := handler.NewInventoryHandler(deps.DB, inventoryService, cfg)
+	inventoryRouteController := route.NewInventoryRouteHandler(inventoryController)
+
+	server := gin.Default()
+	corsConfig := cors.DefaultConfig()
+	corsConfig.AllowOrigins = []string{cfg.ClientOrigin}
+	corsConfig.AllowCredentials = true
+
+	server.Use(cors.New(corsConfig))
+	if fault.Enabled {
+		server.Any("/api/admin/faults", gin.WrapH(fault.Handler()))
+	}
+
+	router := server.Group("/api")
+	inventoryRouteController.InventoryRoute(router)
+
+	return &App{
+		Router:           server,
+		rollbackConsumer: orchestration.NewRollbackConsumer(cfg, deps.Broker, inventoryService, deps.MessageVerifier),
+	}
+}
+
+// Start runs the rollback consumer until ctx is done.
+func (a *App) Start(ctx context.Context) error {
+	if err := a.rollbackConsumer.Consume(ctx); err != nil {
+		return err
+	}
+	log.Println("Rollback consumer started")
+	return nil
+}
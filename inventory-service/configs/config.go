@@ -0,0 +1,54 @@
+package configs
+
+import "github.com/spf13/viper"
+
+type Config struct {
+	// database config
+	DBHost         string `mapstructure:"POSTGRES_HOST"`
+	DBUsername     string `mapstructure:"POSTGRES_USER"`
+	DBUserPassword string `mapstructure:"POSTGRES_PASSWORD"`
+	DBName         string `mapstructure:"POSTGRES_NAME"`
+	DBPort         string `mapstructure:"POSTGRES_PORT"`
+
+	// applies pending migrations before the server starts, otherwise run the migrate subcommand
+	MigrateOnStartup bool `mapstructure:"MIGRATE_ON_STARTUP"`
+
+	// app config
+	ServerPort   string `mapstructure:"SERVER_PORT"`
+	ClientOrigin string `mapstructure:"CLIENT_ORIGIN"`
+
+	// broker config, rabbitmq, nats or memory, the RMQ_ queue names are used on every broker
+	Broker  string `mapstructure:"BROKER"`
+	NatsUrl string `mapstructure:"NATS_URL"`
+
+	// rmq config
+	RMQUrl string `mapstructure:"RMQ_URL"`
+	// consume and release reservations of an order
+	RMQRollbackEventInventoryQueue string `mapstructure:"RMQ_ROLLBACK_INVENTORY_EVENT_QUEUE"`
+
+	// rollback messages have to be signed with one of these keyId:secret pairs separated by commas,
+	// unsigned and forged ones are moved to the quarantine queue
+	MessageSigningKeys string `mapstructure:"MESSAGE_SIGNING_KEYS"`
+	RMQQuarantineQueue string `mapstructure:"RMQ_QUARANTINE_QUEUE"`
+	// rollbacks failing twice are parked here to be replayed with orchestrationctl
+	RMQDeadLetterQueue string `mapstructure:"RMQ_DEAD_LETTER_QUEUE"`
+
+	// faults armed at startup, point=kind[:arg][*count] separated by commas, only honoured by -tags faults builds
+	Faults string `mapstructure:"FAULTS"`
+}
+
+func LoadConfig(path string) (config Config, err error) {
+	viper.AddConfigPath(path)
+	viper.SetConfigType("env")
+	viper.SetConfigName("app")
+
+	viper.AutomaticEnv()
+
+	err = viper.ReadInConfig()
+	if err != nil {
+		return
+	}
+
+	err = viper.Unmarshal(&config)
+	return
+}
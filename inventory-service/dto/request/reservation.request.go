@@ -0,0 +1,15 @@
+package request
+
+type ReservationRequest struct {
+	RequestId string                   `json:"requestId" validate:"required,uuid"`
+	Items     []ReservationItemRequest `json:"items" validate:"required,min=1,max=50,dive"`
+}
+
+type ReservationItemRequest struct {
+	ProductId string `json:"productId" validate:"required,uuid"`
+	Quantity  int    `json:"quantity" validate:"gt=0,lte=1000"`
+}
+
+type ReleaseRequest struct {
+	RequestId string `json:"requestId" validate:"required,uuid"`
+}
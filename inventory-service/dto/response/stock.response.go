@@ -0,0 +1,9 @@
+package response
+
+import "time"
+
+type StockResponse struct {
+	ProductId  string
+	Quantity   int
+	UpdateDate time.Time
+}
@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"common/validation"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"inventory-service/configs"
+	"inventory-service/dto/request"
+	"inventory-service/service"
+	"net/http"
+)
+
+type InventoryHandler struct {
+	postgresDB       *gorm.DB
+	inventoryService *service.InventoryService
+	config           *configs.Config
+}
+
+type InventoryHandlerInterface interface {
+	Reserve(ctx *gin.Context)
+	Release(ctx *gin.Context)
+	GetStock(ctx *gin.Context)
+}
+
+func NewInventoryHandler(postgresDB *gorm.DB, inventoryService *service.InventoryService, config *configs.Config) InventoryHandler {
+	return InventoryHandler{
+		postgresDB:       postgresDB,
+		inventoryService: inventoryService,
+		config:           config,
+	}
+}
+
+func (inventoryHandler InventoryHandler) Reserve(ctx *gin.Context) {
+	var reservationRequest request.ReservationRequest
+	if !validation.BindJSON(ctx, &reservationRequest) {
+		return
+	}
+
+	err := inventoryHandler.inventoryService.Reserve(reservationRequest)
+	if errors.Is(err, service.ErrInsufficientStock) || errors.Is(err, service.ErrReservationReleased) {
+		ctx.JSON(http.StatusConflict, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{})
+}
+
+func (inventoryHandler InventoryHandler) Release(ctx *gin.Context) {
+	var releaseRequest request.ReleaseRequest
+	if !validation.BindJSON(ctx, &releaseRequest) {
+		return
+	}
+
+	err := inventoryHandler.inventoryService.Release(releaseRequest.RequestId)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{})
+}
+
+func (inventoryHandler InventoryHandler) GetStock(ctx *gin.Context) {
+	stock, err := inventoryHandler.inventoryService.GetStock(ctx.Param("productId"))
+	if errors.Is(err, service.ErrStockNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, stock)
+}
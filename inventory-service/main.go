@@ -0,0 +1,92 @@
+package main
+
+import (
+	"common/broker"
+	"common/fault"
+	commonMigration "common/migration"
+	"common/signing"
+	"context"
+	"gorm.io/gorm"
+	"inventory-service/app"
+	"inventory-service/configs"
+	"inventory-service/migration"
+	"log"
+	"os"
+)
+
+func main() {
+	config, err := configs.LoadConfig(".")
+	if err != nil {
+		panic("Could not initialize app")
+	}
+	if err := fault.Configure(config.Faults); err != nil {
+		log.Fatalf("Failed to arm faults: %v", err)
+	}
+
+	postgresDB, err := configs.ConnectToDB(&config)
+	if err != nil {
+		panic("Failed to connect to DB")
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrateCommand(postgresDB, os.Args[2:]); err != nil {
+			log.Fatalf("Migrate failed: %v", err)
+		}
+		return
+	}
+	if config.MigrateOnStartup {
+		if err := runMigrateCommand(postgresDB, []string{"up"}); err != nil {
+			log.Fatalf("Failed to migrate DB: %v", err)
+		}
+	}
+
+	messageBroker := initializeBroker(config)
+	defer messageBroker.Close()
+
+	_, messageVerifier, err := signing.NewMessageSigning("", "", config.MessageSigningKeys)
+	if err != nil {
+		log.Fatalf("Failed to load message signing keys: %v", err)
+	}
+	if messageVerifier == nil {
+		log.Println("No message signing keys configured, rollback messages are not verified")
+	}
+
+	inventoryApp := app.New(&config, app.Dependencies{
+		DB:              postgresDB,
+		Broker:          messageBroker,
+		MessageVerifier: messageVerifier,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := inventoryApp.Start(ctx); err != nil {
+		log.Fatalf("Failed to start inventory-service: %v", err)
+	}
+
+	log.Fatal(inventoryApp.Router.Run(":" + config.ServerPort))
+}
+
+func initializeBroker(cfg configs.Config) broker.Broker {
+	messageBroker, err := broker.New(broker.Config{
+		Backend:     cfg.Broker,
+		RabbitMQUrl: cfg.RMQUrl,
+		NatsUrl:     cfg.NatsUrl,
+	})
+	if err != nil {
+		log.Fatalf("Failed to connect to %s broker: %v", cfg.Broker, err)
+	}
+
+	if err := messageBroker.Declare(cfg.RMQRollbackEventInventoryQueue, cfg.RMQQuarantineQueue, cfg.RMQDeadLetterQueue); err != nil {
+		messageBroker.Close()
+		log.Fatalf("Failed to declare queues: %v", err)
+	}
+	return messageBroker
+}
+
+func runMigrateCommand(postgresDB *gorm.DB, args []string) error {
+	migrator, err := migration.NewMigrator(postgresDB)
+	if err != nil {
+		return err
+	}
+	return commonMigration.RunCommand(migrator, args, os.Stdout)
+}
@@ -0,0 +1,22 @@
+package migration
+
+import (
+	commonMigration "common/migration"
+	"embed"
+	"gorm.io/gorm"
+	"io/fs"
+)
+
+// TableName keeps track of the applied inventory-service migrations
+const TableName = "inventory_schema_migrations"
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+func NewMigrator(db *gorm.DB) (*commonMigration.Migrator, error) {
+	files, err := fs.Sub(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	return commonMigration.NewMigrator(db, TableName, files)
+}
@@ -0,0 +1,2 @@
+drop table if exists stock_reservations;
+drop table if exists stocks;
@@ -0,0 +1,20 @@
+-- the inventory tables were never part of docker/schema.sql, there is nothing to adopt
+create table if not exists stocks
+(
+    product_id varchar(512) not null primary key,
+    quantity integer not null check (quantity >= 0),
+    update_date timestamp not null
+);
+
+create table if not exists stock_reservations
+(
+    stock_reservation_id varchar(512) not null primary key,
+    request_id varchar(512) not null,
+    product_id varchar(512) not null,
+    quantity integer not null,
+    status varchar(16) not null,
+    create_date timestamp not null,
+    update_date timestamp not null
+);
+
+create index if not exists stock_reservations_request_id_idx on stock_reservations (request_id);
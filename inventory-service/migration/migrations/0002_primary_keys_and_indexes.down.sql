@@ -0,0 +1,2 @@
+drop index if exists stock_reservations_product_id_idx;
+alter table stock_reservations drop constraint if exists stock_reservations_product_id_fkey;
@@ -0,0 +1,3 @@
+alter table stock_reservations add constraint stock_reservations_product_id_fkey
+    foreign key (product_id) references stocks (product_id);
+create index if not exists stock_reservations_product_id_idx on stock_reservations (product_id);
@@ -0,0 +1,2 @@
+drop index if exists stock_reservations_request_id_product_id_idx;
+drop table if exists reservations;
@@ -0,0 +1,19 @@
+-- one row per request, a release that finds nothing reserved leaves a RELEASED tombstone behind
+create table if not exists reservations
+(
+    request_id varchar(512) not null primary key,
+    status varchar(16) not null,
+    create_date timestamp not null,
+    update_date timestamp not null
+);
+
+insert into reservations (request_id, status, create_date, update_date)
+select request_id,
+       case when bool_or(status = 'RESERVED') then 'RESERVED' else 'RELEASED' end,
+       min(create_date),
+       max(update_date)
+from stock_reservations
+group by request_id
+on conflict (request_id) do nothing;
+
+create unique index if not exists stock_reservations_request_id_product_id_idx on stock_reservations (request_id, product_id);
@@ -0,0 +1,14 @@
+package model
+
+import (
+	"time"
+)
+
+// Reservation is claimed once per request by whichever of reserve and release comes first. A release claiming it
+// leaves a RELEASED tombstone, so a reservation arriving after its rollback does not take the stock for good.
+type Reservation struct {
+	RequestId  string    `gorm:"type:varchar(512);primary_key" sql:"requestId"`
+	Status     string    `gorm:"type:varchar(16);not null" sql:"status"`
+	CreateDate time.Time `gorm:"not null" sql:"createDate"`
+	UpdateDate time.Time `gorm:"not null" sql:"updateDate"`
+}
@@ -0,0 +1,11 @@
+package model
+
+import (
+	"time"
+)
+
+type Stock struct {
+	ProductId  string    `gorm:"type:varchar(512);primary_key" sql:"productId"`
+	Quantity   int       `gorm:"not null" sql:"quantity"`
+	UpdateDate time.Time `gorm:"not null" sql:"updateDate"`
+}
@@ -0,0 +1,20 @@
+package model
+
+import (
+	"time"
+)
+
+const (
+	ReservationStatusReserved = "RESERVED"
+	ReservationStatusReleased = "RELEASED"
+)
+
+type StockReservation struct {
+	StockReservationId string    `gorm:"type:varchar(512);primary_key" sql:"stockReservationId"`
+	RequestId          string    `gorm:"not null" sql:"requestId"`
+	ProductId          string    `gorm:"not null" sql:"productId"`
+	Quantity           int       `gorm:"not null" sql:"quantity"`
+	Status             string    `gorm:"type:varchar(16);not null" sql:"status"`
+	CreateDate         time.Time `gorm:"not null" sql:"createDate"`
+	UpdateDate         time.Time `gorm:"not null" sql:"updateDate"`
+}
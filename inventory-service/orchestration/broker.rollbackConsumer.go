@@ -0,0 +1,58 @@
+package orchestration
+
+import (
+	"common/broker"
+	"common/fault"
+	"common/signing"
+	"context"
+	"inventory-service/configs"
+	"inventory-service/service"
+	"log"
+)
+
+type RollbackConsumer struct {
+	broker           broker.Broker
+	config           *configs.Config
+	inventoryService *service.InventoryService
+	// nil accepts unsigned messages
+	verifier *signing.MessageVerifier
+}
+
+func NewRollbackConsumer(cfg *configs.Config, broker broker.Broker, inventoryService *service.InventoryService, verifier *signing.MessageVerifier) *RollbackConsumer {
+	return &RollbackConsumer{
+		broker:           broker,
+		config:           cfg,
+		inventoryService: inventoryService,
+		verifier:         verifier,
+	}
+}
+
+func (rc *RollbackConsumer) Consume(ctx context.Context) error {
+	queue := rc.config.RMQRollbackEventInventoryQueue
+	return rc.broker.Subscribe(ctx, queue, signing.Guard(rc.broker, rc.verifier, queue, rc.config.RMQQuarantineQueue, func(ctx context.Context, delivery broker.Delivery) {
+		log.Printf("Rollback Consumer: Received message: %s", string(delivery.Body))
+
+		requestId := string(delivery.Body)
+		err := rc.processRollback(requestId)
+		if err != nil {
+			log.Printf("Error occured processing a rollback: %v", err)
+		}
+		// a failed compensation is retried once and then parked in the dead-letter queue to be replayed
+		broker.Settle(ctx, rc.broker, queue, rc.config.RMQDeadLetterQueue, delivery, err)
+	}))
+}
+
+// processRollback releases every reservation still held by the request.
+func (rc *RollbackConsumer) processRollback(requestId string) error {
+	if err := fault.Inject(fault.InventoryRollback); err != nil {
+		return err
+	}
+
+	err := rc.inventoryService.Release(requestId)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("requestId %s successfully rolledback", requestId)
+	return nil
+}
@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"inventory-service/model"
+	"time"
+)
+
+type ReservationRepositoryInterface interface {
+	Claim(tx *gorm.DB, requestId string, status string) (bool, error)
+	Fetch(tx *gorm.DB, requestId string) (*model.Reservation, error)
+	MarkReleased(tx *gorm.DB, requestId string) error
+}
+
+type ReservationRepository struct{}
+
+func NewReservationRepository() *ReservationRepository {
+	return &ReservationRepository{}
+}
+
+// Claim records the request with status, it reports false when the request was claimed already. A concurrent
+// claim waits until the transaction holding the request finishes.
+func (r *ReservationRepository) Claim(tx *gorm.DB, requestId string, status string) (bool, error) {
+	now := time.Now().UTC()
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&model.Reservation{
+		RequestId:  requestId,
+		Status:     status,
+		CreateDate: now,
+		UpdateDate: now,
+	})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *ReservationRepository) Fetch(tx *gorm.DB, requestId string) (*model.Reservation, error) {
+	var reservation model.Reservation
+	if err := tx.Where("request_id = ?", requestId).First(&reservation).Error; err != nil {
+		return nil, err
+	}
+	return &reservation, nil
+}
+
+func (r *ReservationRepository) MarkReleased(tx *gorm.DB, requestId string) error {
+	return tx.Model(&model.Reservation{}).
+		Where("request_id = ? AND status = ?", requestId, model.ReservationStatusReserved).
+		Updates(map[string]interface{}{
+			"status":      model.ReservationStatusReleased,
+			"update_date": time.Now().UTC(),
+		}).Error
+}
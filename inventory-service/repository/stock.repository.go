@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+	"inventory-service/model"
+	"time"
+)
+
+type StockRepositoryInterface interface {
+	Fetch(tx *gorm.DB, productId string) (*model.Stock, bool, error)
+	Decrease(tx *gorm.DB, productId string, quantity int) (bool, error)
+	Increase(tx *gorm.DB, productId string, quantity int) error
+}
+
+type StockRepository struct{}
+
+func NewStockRepository() *StockRepository {
+	return &StockRepository{}
+}
+
+func (r *StockRepository) Fetch(tx *gorm.DB, productId string) (*model.Stock, bool, error) {
+	var stock model.Stock
+	err := tx.Where("product_id = ?", productId).First(&stock).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	return &stock, true, nil
+}
+
+// Decrease takes the quantity out of stock in a single conditional update,
+// it reports false when there is not enough stock left.
+func (r *StockRepository) Decrease(tx *gorm.DB, productId string, quantity int) (bool, error) {
+	result := tx.Model(&model.Stock{}).
+		Where("product_id = ? AND quantity >= ?", productId, quantity).
+		Updates(map[string]interface{}{
+			"quantity":    gorm.Expr("quantity - ?", quantity),
+			"update_date": time.Now().UTC(),
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *StockRepository) Increase(tx *gorm.DB, productId string, quantity int) error {
+	return tx.Model(&model.Stock{}).
+		Where("product_id = ?", productId).
+		Updates(map[string]interface{}{
+			"quantity":    gorm.Expr("quantity + ?", quantity),
+			"update_date": time.Now().UTC(),
+		}).Error
+}
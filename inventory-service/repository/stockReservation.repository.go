@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+	"inventory-service/model"
+	"time"
+)
+
+type StockReservationRepositoryInterface interface {
+	Insert(tx *gorm.DB, reservation *model.StockReservation) error
+	FetchByRequestId(tx *gorm.DB, requestId string) ([]model.StockReservation, error)
+	MarkReleased(tx *gorm.DB, stockReservationId string) (bool, error)
+}
+
+type StockReservationRepository struct{}
+
+func NewStockReservationRepository() *StockReservationRepository {
+	return &StockReservationRepository{}
+}
+
+func (r *StockReservationRepository) Insert(tx *gorm.DB, reservation *model.StockReservation) error {
+	return tx.Create(reservation).Error
+}
+
+func (r *StockReservationRepository) FetchByRequestId(tx *gorm.DB, requestId string) ([]model.StockReservation, error) {
+	var reservations []model.StockReservation
+	err := tx.Where("request_id = ?", requestId).Find(&reservations).Error
+	if err != nil {
+		return nil, err
+	}
+	return reservations, nil
+}
+
+// MarkReleased flips a reservation to released, it reports false when it was released already.
+func (r *StockReservationRepository) MarkReleased(tx *gorm.DB, stockReservationId string) (bool, error) {
+	result := tx.Model(&model.StockReservation{}).
+		Where("stock_reservation_id = ? AND status = ?", stockReservationId, model.ReservationStatusReserved).
+		Updates(map[string]interface{}{
+			"status":      model.ReservationStatusReleased,
+			"update_date": time.Now().UTC(),
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
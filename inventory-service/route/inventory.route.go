@@ -0,0 +1,23 @@
+package route
+
+import (
+	"github.com/gin-gonic/gin"
+	"inventory-service/handler"
+)
+
+type InventoryRouteHandler struct {
+	inventoryHandler handler.InventoryHandler
+}
+
+func NewInventoryRouteHandler(inventoryHandler handler.InventoryHandler) InventoryRouteHandler {
+	return InventoryRouteHandler{
+		inventoryHandler: inventoryHandler,
+	}
+}
+
+func (h *InventoryRouteHandler) InventoryRoute(group *gin.RouterGroup) {
+	router := group.Group("inventory")
+	router.POST("/reserve", h.inventoryHandler.Reserve)
+	router.POST("/release", h.inventoryHandler.Release)
+	router.GET("/stock/:productId", h.inventoryHandler.GetStock)
+}
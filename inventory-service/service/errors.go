@@ -0,0 +1,10 @@
+package service
+
+import "errors"
+
+var (
+	ErrStockNotFound     = errors.New("stock not found")
+	ErrInsufficientStock = errors.New("insufficient stock")
+	// the request was rolled back before its reservation arrived
+	ErrReservationReleased = errors.New("request was released already")
+)
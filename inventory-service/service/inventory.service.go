@@ -0,0 +1,176 @@
+package service
+
+import (
+	"errors"
+	uuid "github.com/satori/go.uuid"
+	"gorm.io/gorm"
+	"inventory-service/dto/request"
+	"inventory-service/dto/response"
+	"inventory-service/model"
+	"inventory-service/repository"
+	"log"
+	"time"
+)
+
+type InventoryServiceInterface interface {
+	Reserve(request request.ReservationRequest) error
+	Release(requestId string) error
+	GetStock(productId string) (response.StockResponse, error)
+}
+
+type InventoryService struct {
+	db                         *gorm.DB
+	stockRepository            repository.StockRepositoryInterface
+	stockReservationRepository repository.StockReservationRepositoryInterface
+	reservationRepository      repository.ReservationRepositoryInterface
+}
+
+func NewInventoryService(
+	db *gorm.DB,
+	stockRepository repository.StockRepositoryInterface,
+	stockReservationRepository repository.StockReservationRepositoryInterface,
+	reservationRepository repository.ReservationRepositoryInterface,
+) *InventoryService {
+	return &InventoryService{
+		db:                         db,
+		stockRepository:            stockRepository,
+		stockReservationRepository: stockReservationRepository,
+		reservationRepository:      reservationRepository,
+	}
+}
+
+// Reserve takes all items of a request out of stock or none of them.
+// A request that was reserved already is not reserved twice, one that was released already is not reserved at all.
+func (is *InventoryService) Reserve(req request.ReservationRequest) error {
+	tx := is.getDbConnection()
+	claimed, err := is.reservationRepository.Claim(tx, req.RequestId, model.ReservationStatusReserved)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if !claimed {
+		existing, err := is.reservationRepository.Fetch(tx, req.RequestId)
+		tx.Rollback()
+		if err != nil {
+			return err
+		}
+		if existing.Status == model.ReservationStatusReleased {
+			log.Printf("requestId %s was released before it was reserved", req.RequestId)
+			return ErrReservationReleased
+		}
+		log.Printf("requestId %s already reserved", req.RequestId)
+		return nil
+	}
+
+	now := time.Now().UTC()
+	for _, item := range mergeItems(req.Items) {
+		reserved, err := is.stockRepository.Decrease(tx, item.ProductId, item.Quantity)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		if !reserved {
+			tx.Rollback()
+			return ErrInsufficientStock
+		}
+
+		reservation := &model.StockReservation{
+			StockReservationId: uuid.NewV4().String(),
+			RequestId:          req.RequestId,
+			ProductId:          item.ProductId,
+			Quantity:           item.Quantity,
+			Status:             model.ReservationStatusReserved,
+			CreateDate:         now,
+			UpdateDate:         now,
+		}
+		if err := is.stockReservationRepository.Insert(tx, reservation); err != nil {
+			tx.Rollback()
+			// reserved before the request was claimed, by a version without the claim
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				log.Printf("requestId %s already reserved", req.RequestId)
+				return nil
+			}
+			return err
+		}
+	}
+
+	return tx.Commit().Error
+}
+
+// mergeItems sums up the quantities of items listing the same product, a request holds one reservation per product.
+func mergeItems(items []request.ReservationItemRequest) []request.ReservationItemRequest {
+	merged := make([]request.ReservationItemRequest, 0, len(items))
+	positions := map[string]int{}
+	for _, item := range items {
+		if position, seen := positions[item.ProductId]; seen {
+			merged[position].Quantity += item.Quantity
+			continue
+		}
+		positions[item.ProductId] = len(merged)
+		merged = append(merged, item)
+	}
+	return merged
+}
+
+// Release puts every still reserved item of a request back into stock. A request that was not reserved yet is
+// claimed as released, so a reservation arriving late is refused instead of holding the stock forever.
+func (is *InventoryService) Release(requestId string) error {
+	tx := is.getDbConnection()
+	// waits for a reservation of the request in flight, its items are released below once it committed
+	if _, err := is.reservationRepository.Claim(tx, requestId, model.ReservationStatusReleased); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := is.reservationRepository.MarkReleased(tx, requestId); err != nil {
+		tx.Rollback()
+		return err
+	}
+	reservations, err := is.stockReservationRepository.FetchByRequestId(tx, requestId)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	for _, reservation := range reservations {
+		released, err := is.stockReservationRepository.MarkReleased(tx, reservation.StockReservationId)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		if !released {
+			continue
+		}
+		if err := is.stockRepository.Increase(tx, reservation.ProductId, reservation.Quantity); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit().Error
+}
+
+func (is *InventoryService) GetStock(productId string) (response.StockResponse, error) {
+	stock, exists, err := is.stockRepository.Fetch(is.db, productId)
+	if err != nil {
+		return response.StockResponse{}, err
+	}
+	if !exists {
+		return response.StockResponse{}, ErrStockNotFound
+	}
+	return response.StockResponse{
+		ProductId:  stock.ProductId,
+		Quantity:   stock.Quantity,
+		UpdateDate: stock.UpdateDate,
+	}, nil
+}
+
+func (is *InventoryService) getDbConnection() *gorm.DB {
+	tx := is.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	return tx
+}
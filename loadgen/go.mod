@@ -0,0 +1,31 @@
+module loadgen
+
+go 1.23.0
+
+require (
+	github.com/satori/go.uuid v1.2.0
+	gorm.io/driver/postgres v1.5.11
+	gorm.io/gorm v1.25.12
+	inventory-service v0.0.0
+	order-service v0.0.0
+	payment-service v0.0.0
+)
+
+require (
+	github.com/jackc/pgpassfile v1.0.0 // indirect
+	github.com/jackc/pgservicefile v0.0.0-20221227161230-091c0ba34f0a // indirect
+	github.com/jackc/pgx/v5 v5.5.5 // indirect
+	github.com/jackc/puddle/v2 v2.2.1 // indirect
+	github.com/jinzhu/inflection v1.0.0 // indirect
+	github.com/jinzhu/now v1.1.5 // indirect
+	golang.org/x/crypto v0.36.0 // indirect
+	golang.org/x/sync v0.12.0 // indirect
+	golang.org/x/text v0.23.0 // indirect
+)
+
+replace (
+	common => ../common
+	inventory-service => ../inventory-service
+	order-service => ../order-service
+	payment-service => ../payment-service
+)
@@ -0,0 +1,117 @@
+package loadtest
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+var percentiles = []float64{50, 90, 95, 99, 100}
+
+type Report struct {
+	Result     Result
+	Violations []Violation
+}
+
+func (r Report) Print(out io.Writer) {
+	samples := r.Result.Samples
+	fmt.Fprintf(out, "%d requests in %s (%.1f req/s)\n\n", len(samples), r.Result.Elapsed.Round(time.Millisecond),
+		float64(len(samples))/r.Result.Elapsed.Seconds())
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KIND\tSTATUS\tCOUNT")
+	for _, row := range statusCounts(samples) {
+		fmt.Fprintf(w, "%s\t%s\t%d\n", row.kind, row.status, row.count)
+	}
+	w.Flush()
+
+	fmt.Fprintln(out)
+	w = tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprint(w, "LATENCY")
+	for _, p := range percentiles {
+		if p == 100 {
+			fmt.Fprint(w, "\tMAX")
+		} else {
+			fmt.Fprintf(w, "\tP%g", p)
+		}
+	}
+	fmt.Fprintln(w)
+	fmt.Fprint(w, "all")
+	latencies := Latencies(samples)
+	for _, p := range percentiles {
+		fmt.Fprintf(w, "\t%s", Percentile(latencies, p).Round(time.Microsecond*100))
+	}
+	fmt.Fprintln(w)
+	w.Flush()
+
+	fmt.Fprintln(out)
+	if len(r.Violations) == 0 {
+		fmt.Fprintln(out, "no invariant violations")
+		return
+	}
+	fmt.Fprintf(out, "%d invariant violations\n", len(r.Violations))
+	w = tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "INVARIANT\tSUBJECT\tDETAIL")
+	for _, violation := range r.Violations {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", violation.Invariant, violation.Subject, violation.Detail)
+	}
+	w.Flush()
+}
+
+type statusCount struct {
+	kind   string
+	status string
+	count  int
+}
+
+func statusCounts(samples []Sample) []statusCount {
+	counts := map[[2]string]int{}
+	for _, sample := range samples {
+		status := fmt.Sprint(sample.Status)
+		if sample.Err != nil {
+			status = "error"
+		}
+		counts[[2]string{sample.Kind, status}]++
+	}
+	rows := make([]statusCount, 0, len(counts))
+	for key, count := range counts {
+		rows = append(rows, statusCount{kind: key[0], status: key[1], count: count})
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].kind != rows[j].kind {
+			return rows[i].kind < rows[j].kind
+		}
+		return rows[i].status < rows[j].status
+	})
+	return rows
+}
+
+// Latencies returns the sorted latencies of the samples that got a response.
+func Latencies(samples []Sample) []time.Duration {
+	latencies := make([]time.Duration, 0, len(samples))
+	for _, sample := range samples {
+		if sample.Err == nil {
+			latencies = append(latencies, sample.Latency)
+		}
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	return latencies
+}
+
+// Percentile uses the nearest rank of sorted latencies, 100 is the maximum.
+func Percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
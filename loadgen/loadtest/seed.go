@@ -0,0 +1,96 @@
+package loadtest
+
+import (
+	"fmt"
+	inventoryModel "inventory-service/model"
+	orderModel "order-service/model"
+	paymentModel "payment-service/model"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	"gorm.io/gorm"
+)
+
+type SeedOptions struct {
+	Products            int
+	Price               float64
+	Stock               int
+	Accounts            int
+	Balance             float64
+	UnderfundedAccounts int
+	UnderfundedBalance  float64
+	Currency            string
+}
+
+// Fixture is the data a run is scoped to, verification only looks at orders and transactions of its accounts.
+type Fixture struct {
+	Currency            string
+	Price               float64
+	Products            []string
+	FundedAccounts      []string
+	UnderfundedAccounts []string
+	StartingBalances    map[string]float64
+}
+
+func (f *Fixture) Accounts() []string {
+	return append(append([]string{}, f.FundedAccounts...), f.UnderfundedAccounts...)
+}
+
+// Seed creates fresh products, stock and accounts for a run, so earlier runs and other data never skew the verification.
+func Seed(orderDB *gorm.DB, paymentDB *gorm.DB, inventoryDB *gorm.DB, options SeedOptions) (*Fixture, error) {
+	now := time.Now().UTC()
+	fixture := &Fixture{
+		Currency:         options.Currency,
+		Price:            options.Price,
+		StartingBalances: map[string]float64{},
+	}
+
+	for i := 0; i < options.Products; i++ {
+		productId := uuid.NewV4().String()
+		product := orderModel.Product{
+			ProductId:  productId,
+			Name:       fmt.Sprintf("loadgen-%d", i),
+			Price:      options.Price,
+			Currency:   options.Currency,
+			CreateDate: now,
+			UpdateDate: now,
+		}
+		if err := orderDB.Create(&product).Error; err != nil {
+			return nil, fmt.Errorf("seed product: %w", err)
+		}
+		stock := inventoryModel.Stock{ProductId: productId, Quantity: options.Stock, UpdateDate: now}
+		if err := inventoryDB.Create(&stock).Error; err != nil {
+			return nil, fmt.Errorf("seed stock: %w", err)
+		}
+		fixture.Products = append(fixture.Products, productId)
+	}
+
+	seedAccount := func(balance float64) (string, error) {
+		account := paymentModel.Account{
+			AccountId:  uuid.NewV4().String(),
+			Amount:     balance,
+			Currency:   options.Currency,
+			UpdateDate: now,
+		}
+		if err := paymentDB.Create(&account).Error; err != nil {
+			return "", fmt.Errorf("seed account: %w", err)
+		}
+		fixture.StartingBalances[account.AccountId] = balance
+		return account.AccountId, nil
+	}
+	for i := 0; i < options.Accounts; i++ {
+		accountId, err := seedAccount(options.Balance)
+		if err != nil {
+			return nil, err
+		}
+		fixture.FundedAccounts = append(fixture.FundedAccounts, accountId)
+	}
+	for i := 0; i < options.UnderfundedAccounts; i++ {
+		accountId, err := seedAccount(options.UnderfundedBalance)
+		if err != nil {
+			return nil, err
+		}
+		fixture.UnderfundedAccounts = append(fixture.UnderfundedAccounts, accountId)
+	}
+	return fixture, nil
+}
@@ -0,0 +1,161 @@
+package loadtest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math/rand"
+	"net/http"
+	"order-service/dto/request"
+	"sync"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// kinds of generated order requests
+const (
+	KindRegular        = "regular"
+	KindDuplicate      = "duplicate"
+	KindUnknownProduct = "unknown-product"
+	KindUnderfunded    = "underfunded"
+)
+
+type TrafficOptions struct {
+	Orders      int
+	Concurrency int
+	MaxQuantity int
+	// shares of the traffic, the rest are regular orders
+	DuplicateRate      float64
+	UnknownProductRate float64
+	UnderfundedRate    float64
+	RandomSeed         int64
+}
+
+// Sample is the outcome of a single request, Status is 0 when the request did not get a response.
+type Sample struct {
+	Kind      string
+	RequestId string
+	Status    int
+	Latency   time.Duration
+	Err       error
+}
+
+type Result struct {
+	Samples []Sample
+	Elapsed time.Duration
+}
+
+type plannedOrder struct {
+	kind    string
+	payload request.OrderRequest
+}
+
+// Run fires the planned orders at the order-service from Concurrency workers.
+// Duplicates replay an earlier payload, so they may race the original while it is still in flight.
+func Run(ctx context.Context, client *http.Client, orderURL string, fixture *Fixture, options TrafficOptions) Result {
+	plan := planOrders(fixture, options)
+	queue := make(chan plannedOrder)
+	samples := make(chan Sample, len(plan))
+
+	started := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < options.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for order := range queue {
+				samples <- send(ctx, client, orderURL, order)
+			}
+		}()
+	}
+	for _, order := range plan {
+		select {
+		case queue <- order:
+		case <-ctx.Done():
+		}
+	}
+	close(queue)
+	wg.Wait()
+	close(samples)
+
+	result := Result{Elapsed: time.Since(started)}
+	for sample := range samples {
+		result.Samples = append(result.Samples, sample)
+	}
+	return result
+}
+
+func planOrders(fixture *Fixture, options TrafficOptions) []plannedOrder {
+	random := rand.New(rand.NewSource(options.RandomSeed))
+	maxQuantity := options.MaxQuantity
+	if maxQuantity < 1 {
+		maxQuantity = 1
+	}
+
+	plan := make([]plannedOrder, 0, options.Orders)
+	for len(plan) < options.Orders {
+		roll := random.Float64()
+		switch {
+		case roll < options.DuplicateRate && len(plan) > 0:
+			original := plan[random.Intn(len(plan))]
+			plan = append(plan, plannedOrder{kind: KindDuplicate, payload: original.payload})
+			continue
+		case roll < options.DuplicateRate+options.UnknownProductRate:
+			plan = append(plan, plannedOrder{
+				kind:    KindUnknownProduct,
+				payload: orderPayload(pick(random, fixture.FundedAccounts), uuid.NewV4().String(), 1),
+			})
+			continue
+		case roll < options.DuplicateRate+options.UnknownProductRate+options.UnderfundedRate && len(fixture.UnderfundedAccounts) > 0:
+			plan = append(plan, plannedOrder{
+				kind:    KindUnderfunded,
+				payload: orderPayload(pick(random, fixture.UnderfundedAccounts), pick(random, fixture.Products), maxQuantity),
+			})
+			continue
+		}
+		plan = append(plan, plannedOrder{
+			kind:    KindRegular,
+			payload: orderPayload(pick(random, fixture.FundedAccounts), pick(random, fixture.Products), 1+random.Intn(maxQuantity)),
+		})
+	}
+	return plan
+}
+
+func orderPayload(accountId string, productId string, quantity int) request.OrderRequest {
+	return request.OrderRequest{
+		Items:     []request.OrderItemRequest{{ProductId: productId, Quantity: quantity}},
+		RequestId: uuid.NewV4().String(),
+		AccountID: accountId,
+	}
+}
+
+func pick(random *rand.Rand, values []string) string {
+	return values[random.Intn(len(values))]
+}
+
+func send(ctx context.Context, client *http.Client, orderURL string, order plannedOrder) Sample {
+	sample := Sample{Kind: order.kind, RequestId: order.payload.RequestId}
+	body, err := json.Marshal(order.payload)
+	if err != nil {
+		sample.Err = err
+		return sample
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, orderURL+"/api/order/create", bytes.NewReader(body))
+	if err != nil {
+		sample.Err = err
+		return sample
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	started := time.Now()
+	resp, err := client.Do(req)
+	sample.Latency = time.Since(started)
+	if err != nil {
+		sample.Err = err
+		return sample
+	}
+	defer resp.Body.Close()
+	sample.Status = resp.StatusCode
+	return sample
+}
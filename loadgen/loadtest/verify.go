@@ -0,0 +1,162 @@
+package loadtest
+
+import (
+	"context"
+	"fmt"
+	"math"
+	orderModel "order-service/model"
+	paymentModel "payment-service/model"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// invariants checked after a run
+const (
+	InvariantSingleCharge      = "single-charge"
+	InvariantCompensation      = "compensation"
+	InvariantOrphanTransaction = "orphan-transaction"
+	InvariantBalance           = "balance"
+	InvariantUnsettled         = "unsettled-order"
+)
+
+// amounts are numeric in postgres but float64 in go, differences below a cent are rounding
+const balanceTolerance = 0.005
+
+type Violation struct {
+	Invariant string
+	Subject   string
+	Detail    string
+}
+
+// Settle waits until no order of the fixture is mid-saga, rollbacks can take up to the orchestration expiry to finish.
+func Settle(ctx context.Context, orderDB *gorm.DB, fixture *Fixture, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	ticker := time.NewTicker(250 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		var pending int64
+		err := orderDB.Model(&orderModel.ProductOrder{}).
+			Where("account_id IN ? AND status NOT IN ?", fixture.Accounts(), []string{orderModel.OrderStatusConfirmed, orderModel.OrderStatusCompensated}).
+			Count(&pending).Error
+		if err != nil {
+			return err
+		}
+		if pending == 0 {
+			return nil
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return fmt.Errorf("%d orders still in progress: %w", pending, ctx.Err())
+		}
+	}
+}
+
+// Verify checks the global invariants of the saga for the orders and transactions of the fixture accounts:
+// a confirmed order is charged exactly once, a compensated one is charged and refunded the same number of times,
+// every transaction belongs to an order and every balance is the starting balance minus the net charges.
+func Verify(orderDB *gorm.DB, paymentDB *gorm.DB, fixture *Fixture) ([]Violation, error) {
+	accounts := fixture.Accounts()
+
+	var orders []orderModel.ProductOrder
+	if err := orderDB.Where("account_id IN ?", accounts).Find(&orders).Error; err != nil {
+		return nil, err
+	}
+	var transactions []paymentModel.Transaction
+	if err := paymentDB.Where("account_id IN ?", accounts).Find(&transactions).Error; err != nil {
+		return nil, err
+	}
+	var balances []paymentModel.Account
+	if err := paymentDB.Where("account_id IN ?", accounts).Find(&balances).Error; err != nil {
+		return nil, err
+	}
+
+	type ledger struct {
+		charges int
+		refunds int
+	}
+	byRequest := map[string]*ledger{}
+	netCharges := map[string]float64{}
+	for _, transaction := range transactions {
+		entry, ok := byRequest[transaction.RequestId]
+		if !ok {
+			entry = &ledger{}
+			byRequest[transaction.RequestId] = entry
+		}
+		switch transaction.Type {
+		case paymentModel.TransactionTypeCharge:
+			entry.charges++
+			netCharges[transaction.AccountId] += transaction.Amount
+		case paymentModel.TransactionTypeRefund:
+			entry.refunds++
+			netCharges[transaction.AccountId] -= transaction.Amount
+		}
+	}
+
+	var violations []Violation
+	ordered := map[string]bool{}
+	for _, order := range orders {
+		ordered[order.RequestId] = true
+		entry := byRequest[order.RequestId]
+		if entry == nil {
+			entry = &ledger{}
+		}
+		switch order.Status {
+		case orderModel.OrderStatusConfirmed:
+			if entry.charges != 1 || entry.refunds != 0 {
+				violations = append(violations, Violation{
+					Invariant: InvariantSingleCharge,
+					Subject:   order.RequestId,
+					Detail:    fmt.Sprintf("confirmed order has %d charges and %d refunds", entry.charges, entry.refunds),
+				})
+			}
+		case orderModel.OrderStatusCompensated:
+			if entry.charges > 1 || entry.charges != entry.refunds {
+				violations = append(violations, Violation{
+					Invariant: InvariantCompensation,
+					Subject:   order.RequestId,
+					Detail:    fmt.Sprintf("compensated order has %d charges and %d refunds", entry.charges, entry.refunds),
+				})
+			}
+		default:
+			violations = append(violations, Violation{
+				Invariant: InvariantUnsettled,
+				Subject:   order.RequestId,
+				Detail:    fmt.Sprintf("order is still %s", order.Status),
+			})
+		}
+	}
+
+	for requestId, entry := range byRequest {
+		if !ordered[requestId] {
+			violations = append(violations, Violation{
+				Invariant: InvariantOrphanTransaction,
+				Subject:   requestId,
+				Detail:    fmt.Sprintf("%d charges and %d refunds without an order", entry.charges, entry.refunds),
+			})
+		}
+	}
+
+	for _, account := range balances {
+		expected := fixture.StartingBalances[account.AccountId] - netCharges[account.AccountId]
+		if math.Abs(account.Amount-expected) > balanceTolerance {
+			violations = append(violations, Violation{
+				Invariant: InvariantBalance,
+				Subject:   account.AccountId,
+				Detail:    fmt.Sprintf("balance is %.2f, expected %.2f", account.Amount, expected),
+			})
+		}
+	}
+
+	sort.Slice(violations, func(i, j int) bool {
+		if violations[i].Invariant != violations[j].Invariant {
+			return violations[i].Invariant < violations[j].Invariant
+		}
+		return violations[i].Subject < violations[j].Subject
+	})
+	return violations, nil
+}
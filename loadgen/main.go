@@ -0,0 +1,103 @@
+// loadgen fires concurrent order traffic at the order-service and verifies the saga invariants once it settled.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"loadgen/loadtest"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const defaultDSN = "host=localhost user=postgres password=postgres dbname=postgres port=5432"
+
+func main() {
+	orderURL := flag.String("order-url", "http://localhost:8080", "order-service base url")
+	dsn := flag.String("dsn", defaultDSN, "postgres dsn used for every service unless overridden")
+	orderDSN := flag.String("order-dsn", "", "postgres dsn of the order-service")
+	paymentDSN := flag.String("payment-dsn", "", "postgres dsn of the payment-service")
+	inventoryDSN := flag.String("inventory-dsn", "", "postgres dsn of the inventory-service")
+
+	orders := flag.Int("orders", 500, "number of order requests")
+	concurrency := flag.Int("concurrency", 20, "concurrent requests")
+	maxQuantity := flag.Int("max-quantity", 3, "maximum quantity of an order line")
+	duplicateRate := flag.Float64("duplicates", 0.1, "share of requests replaying an earlier request id")
+	unknownRate := flag.Float64("unknown-products", 0.05, "share of requests for products that do not exist")
+	underfundedRate := flag.Float64("underfunded", 0.1, "share of requests from accounts that cannot pay")
+	randomSeed := flag.Int64("seed", time.Now().UnixNano(), "random seed of the traffic plan")
+
+	products := flag.Int("products", 10, "products seeded for the run")
+	accounts := flag.Int("accounts", 20, "funded accounts seeded for the run")
+	underfundedAccounts := flag.Int("underfunded-accounts", 5, "underfunded accounts seeded for the run")
+	price := flag.Float64("price", 10, "price of every seeded product")
+	stock := flag.Int("stock", 100000, "stock of every seeded product")
+	balance := flag.Float64("balance", 100000, "starting balance of funded accounts")
+	settle := flag.Duration("settle", time.Minute, "how long to wait for sagas to finish before verifying")
+	timeout := flag.Duration("timeout", 30*time.Second, "timeout of a single request")
+	flag.Parse()
+
+	orderDB := openDB(*orderDSN, *dsn)
+	paymentDB := openDB(*paymentDSN, *dsn)
+	inventoryDB := openDB(*inventoryDSN, *dsn)
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	fixture, err := loadtest.Seed(orderDB, paymentDB, inventoryDB, loadtest.SeedOptions{
+		Products:            *products,
+		Price:               *price,
+		Stock:               *stock,
+		Accounts:            *accounts,
+		Balance:             *balance,
+		UnderfundedAccounts: *underfundedAccounts,
+		UnderfundedBalance:  *price / 2,
+		Currency:            "EUR",
+	})
+	if err != nil {
+		log.Fatalf("Failed to seed: %v", err)
+	}
+	log.Printf("Seeded %d products and %d accounts, running %d orders with seed %d", len(fixture.Products), len(fixture.StartingBalances), *orders, *randomSeed)
+
+	result := loadtest.Run(ctx, &http.Client{Timeout: *timeout}, *orderURL, fixture, loadtest.TrafficOptions{
+		Orders:             *orders,
+		Concurrency:        *concurrency,
+		MaxQuantity:        *maxQuantity,
+		DuplicateRate:      *duplicateRate,
+		UnknownProductRate: *unknownRate,
+		UnderfundedRate:    *underfundedRate,
+		RandomSeed:         *randomSeed,
+	})
+
+	if err := loadtest.Settle(ctx, orderDB, fixture, *settle); err != nil {
+		log.Printf("Orders did not settle, they are reported as violations: %v", err)
+	}
+	violations, err := loadtest.Verify(orderDB, paymentDB, fixture)
+	if err != nil {
+		log.Fatalf("Failed to verify: %v", err)
+	}
+
+	loadtest.Report{Result: result, Violations: violations}.Print(os.Stdout)
+	if len(violations) > 0 {
+		os.Exit(1)
+	}
+}
+
+func openDB(dsn string, fallback string) *gorm.DB {
+	if dsn == "" {
+		dsn = fallback
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to connect to postgres:", err)
+		os.Exit(1)
+	}
+	return db
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"common/broker"
+	"common/statestore"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/streadway/amqp"
+	"io"
+	"orchestration-service/config"
+	"orchestration-service/connection"
+)
+
+var errUsage = errors.New("invalid arguments")
+
+// queueChannel is the part of an amqp channel the queue commands use.
+type queueChannel interface {
+	Confirm(noWait bool) error
+	NotifyPublish(confirm chan amqp.Confirmation) chan amqp.Confirmation
+	QueueInspect(name string) (amqp.Queue, error)
+	Get(queue string, autoAck bool) (amqp.Delivery, bool, error)
+	Publish(exchange string, key string, mandatory bool, immediate bool, msg amqp.Publishing) error
+	Close() error
+}
+
+type ctl struct {
+	config     config.Config
+	printer    *printer
+	store      statestore.StateStore
+	broker     broker.Broker
+	rabbitConn *amqp.Connection
+	// opens the channels of the queue commands instead of rabbitConn, tests pass a fake broker here
+	openChannel func() (queueChannel, error)
+}
+
+func newCtl(configPath string, printer *printer) (*ctl, error) {
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
+	return &ctl{
+		config:  cfg,
+		printer: printer,
+	}, nil
+}
+
+func (c *ctl) run(command string, args []string) error {
+	switch command {
+	case "list":
+		return c.list(args)
+	case "show":
+		return c.show(args)
+	case "rollback":
+		return c.rollback(args)
+	case "complete":
+		return c.complete(args)
+	case "purge":
+		return c.purge(args)
+	case "summary":
+		return c.summary(args)
+	case "peek":
+		return c.peek(args)
+	case "move":
+		return c.move(args)
+	case "replay":
+		return c.replay(args)
+	}
+	return errUsage
+}
+
+// stateStore connects on first use, so queue commands work while the store is down and the other way round.
+func (c *ctl) stateStore() (statestore.StateStore, error) {
+	if c.store == nil {
+		store, err := connection.NewStateStore(c.config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to the %s orchestration store: %w", c.config.OrchestrationStore, err)
+		}
+		c.store = store
+	}
+	return c.store, nil
+}
+
+func (c *ctl) messageBroker() (broker.Broker, error) {
+	if c.broker == nil {
+		messageBroker, err := connection.NewBroker(c.config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to the %s broker: %w", c.config.Broker, err)
+		}
+		c.broker = messageBroker
+	}
+	return c.broker, nil
+}
+
+// channel is used by the queue commands, which need rabbitmq features the broker interface does not offer.
+func (c *ctl) channel() (queueChannel, error) {
+	if c.config.Broker != broker.BackendRabbitMQ {
+		return nil, fmt.Errorf("queue commands need the %s broker, configured is %s", broker.BackendRabbitMQ, c.config.Broker)
+	}
+	if c.openChannel != nil {
+		return c.openChannel()
+	}
+	if c.rabbitConn == nil {
+		conn, ch, err := connection.NewRabbitMQ(c.config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to rabbitmq: %w", err)
+		}
+		ch.Close()
+		c.rabbitConn = conn
+	}
+	return c.rabbitConn.Channel()
+}
+
+func (c *ctl) close() {
+	if c.broker != nil {
+		c.broker.Close()
+	}
+	if c.rabbitConn != nil {
+		c.rabbitConn.Close()
+	}
+}
+
+// parseArgs parses the -n flag of a command that expects exactly positional arguments.
+func parseArgs(name string, args []string, positional int) ([]string, int, error) {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	// the usage of the whole tool is printed instead
+	flags.SetOutput(io.Discard)
+	count := flags.Int("n", 10, "number of messages")
+	// flags may come after the positional arguments, e.g. peek expired -n 5
+	var values []string
+	for len(args) > 0 {
+		if err := flags.Parse(args); err != nil {
+			return nil, 0, errUsage
+		}
+		args = flags.Args()
+		if len(args) > 0 {
+			values = append(values, args[0])
+			args = args[1:]
+		}
+	}
+	if len(values) != positional || *count <= 0 {
+		return nil, 0, errUsage
+	}
+	return values, *count, nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"common/broker"
+	"common/statestore"
+	"errors"
+	"orchestration-service/config"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newTestCtl runs the commands against an in-memory store and broker.
+func newTestCtl(t *testing.T, format string) (*ctl, *bytes.Buffer) {
+	t.Helper()
+	var out bytes.Buffer
+	return &ctl{
+		config: config.Config{
+			Broker:                             broker.BackendMemory,
+			OrchestrationExpirationTimeSeconds: 30,
+			RMQExpiredEventQueue:               "expired-events",
+			RMQRollbackEventOrderQueue:         "rollback-order-events",
+			RMQRollbackEventPaymentQueue:       "rollback-payment-events",
+			RMQRollbackEventInventoryQueue:     "rollback-inventory-events",
+			RMQDeadLetterQueue:                 "dead-letter-events",
+			RMQQuarantineQueue:                 "quarantine-events",
+		},
+		printer: newPrinter(&out, format),
+		store:   statestore.NewMemoryStore(),
+		broker:  broker.NewMemoryBroker(),
+	}, &out
+}
+
+func TestParseArgs(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		positional int
+		values     []string
+		count      int
+		err        error
+	}{
+		{name: "default count", args: []string{"expired"}, positional: 1, values: []string{"expired"}, count: 10},
+		{name: "count before", args: []string{"-n", "5", "expired"}, positional: 1, values: []string{"expired"}, count: 5},
+		{name: "count after", args: []string{"expired", "-n", "5"}, positional: 1, values: []string{"expired"}, count: 5},
+		{name: "count between", args: []string{"expired", "-n=3", "dead-letter"}, positional: 2, values: []string{"expired", "dead-letter"}, count: 3},
+		{name: "no positional", args: []string{"-n", "7"}, positional: 0, count: 7},
+		{name: "missing positional", args: []string{"expired"}, positional: 2, err: errUsage},
+		{name: "extra positional", args: []string{"expired", "quarantine"}, positional: 1, err: errUsage},
+		{name: "zero count", args: []string{"expired", "-n", "0"}, positional: 1, err: errUsage},
+		{name: "negative count", args: []string{"expired", "-n", "-1"}, positional: 1, err: errUsage},
+		{name: "unknown flag", args: []string{"expired", "-x"}, positional: 1, err: errUsage},
+		{name: "invalid count", args: []string{"expired", "-n", "many"}, positional: 1, err: errUsage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, count, err := parseArgs("test", tt.args, tt.positional)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+			if tt.err != nil {
+				return
+			}
+			if !reflect.DeepEqual(values, tt.values) || count != tt.count {
+				t.Fatalf("expected %v with count %d, got %v with count %d", tt.values, tt.count, values, count)
+			}
+		})
+	}
+}
+
+func TestRunRejectsUnknownCommandsAndArguments(t *testing.T) {
+	ctl, _ := newTestCtl(t, outputTable)
+	calls := [][]string{
+		{"unknown"},
+		{"list", "extra"},
+		{"show"},
+		{"rollback"},
+		{"complete", "a", "b"},
+		{"purge"},
+		{"summary", "extra"},
+	}
+	for _, call := range calls {
+		if err := ctl.run(call[0], call[1:]); err != errUsage {
+			t.Fatalf("expected a usage error for %v, got %v", call, err)
+		}
+	}
+}
+
+func TestQueueCommandsNeedRabbitMQ(t *testing.T) {
+	ctl, out := newTestCtl(t, outputTable)
+	calls := [][]string{
+		{"peek", "expired"},
+		{"move", "dead-letter", "expired"},
+		{"replay"},
+	}
+	for _, call := range calls {
+		err := ctl.run(call[0], call[1:])
+		if err == nil || !strings.Contains(err.Error(), "queue commands need the rabbitmq broker") {
+			t.Fatalf("expected %v to be refused on the memory broker, got %v", call, err)
+		}
+	}
+	if out.Len() != 0 {
+		t.Fatalf("expected no output, got %q", out.String())
+	}
+}
@@ -0,0 +1,58 @@
+// orchestrationctl inspects and repairs orchestrations and their queues for on-call work.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+const usage = `usage: orchestrationctl [-config dir] [-o table|json] <command> [args]
+
+orchestrations:
+  list                        list all orchestrations with their expiry
+  show <id>                   show a single orchestration
+  rollback <id>               mark an orchestration ROLLBACK and publish it to the expired queue
+  complete <id>               remove an IN_PROGRESS orchestration as if the saga ended
+  purge <id>                  remove an orchestration whatever its status
+  summary                     count orchestrations per status and messages per queue
+
+queues (expired, rollback-order, rollback-payment, rollback-inventory, dead-letter, quarantine):
+  peek <queue> [-n count]     print messages, copies of them are put back at the end of the queue
+  move <from> <to> [-n count] move messages from one queue to another
+  replay [-n count]           move dead-lettered messages back to the queue they failed on
+`
+
+func main() {
+	configPath := flag.String("config", ".", "directory containing app.env")
+	output := flag.String("o", outputTable, "output format, table or json")
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *output != outputTable && *output != outputJson {
+		fmt.Fprintf(os.Stderr, "unknown output format %s\n", *output)
+		os.Exit(2)
+	}
+
+	ctl, err := newCtl(*configPath, newPrinter(os.Stdout, *output))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer ctl.close()
+
+	if err := ctl.run(flag.Arg(0), flag.Args()[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		if err == errUsage {
+			fmt.Fprint(os.Stderr, usage)
+			os.Exit(2)
+		}
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"common/broker"
+	"common/signing"
+	"common/statestore"
+	"context"
+	"errors"
+	"fmt"
+	"orchestration-service/constants"
+	"sort"
+	"time"
+)
+
+type orchestrationView struct {
+	UUID           string    `json:"uuid"`
+	Status         string    `json:"status"`
+	ExpirationTime int64     `json:"expirationTime"`
+	Version        int64     `json:"version"`
+	ExpiresAt      time.Time `json:"expiresAt"`
+	Expired        bool      `json:"expired"`
+}
+
+type summaryView struct {
+	Statuses map[string]int   `json:"statuses"`
+	Expired  int              `json:"expired"`
+	Queues   map[string]int64 `json:"queues"`
+}
+
+func (c *ctl) list(args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	store, err := c.stateStore()
+	if err != nil {
+		return err
+	}
+	records, err := store.List(context.Background())
+	if err != nil {
+		return err
+	}
+	views := make([]orchestrationView, 0, len(records))
+	for _, record := range records {
+		views = append(views, toView(record))
+	}
+	sort.Slice(views, func(i, j int) bool {
+		return views[i].ExpirationTime < views[j].ExpirationTime
+	})
+	return c.printer.orchestrations(views)
+}
+
+func (c *ctl) show(args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	store, err := c.stateStore()
+	if err != nil {
+		return err
+	}
+	record, err := store.Get(context.Background(), args[0])
+	if err != nil {
+		return err
+	}
+	return c.printer.orchestrations([]orchestrationView{toView(*record)})
+}
+
+// rollback does what the expired orchestration job does, the orchestrator only rolls back records in ROLLBACK status.
+func (c *ctl) rollback(args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	id := args[0]
+	err := c.swap(context.Background(), id, func(current statestore.Record) (*statestore.Record, error) {
+		return &statestore.Record{
+			UUID:           current.UUID,
+			Status:         constants.StatusRollback,
+			ExpirationTime: time.Now().UnixMilli() + c.config.OrchestrationExpirationTimeSeconds*1000,
+		}, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	messageBroker, err := c.messageBroker()
+	if err != nil {
+		return err
+	}
+	// signed like the expired events of the orchestrator, which would quarantine it otherwise
+	msg := broker.Message{Body: []byte(id)}
+	if c.config.MessageSigningKeyId != "" {
+		signing.NewSigner(c.config.MessageSigningKeyId, c.config.MessageSigningSecret).SignMessage(&msg)
+	}
+	err = messageBroker.Publish(context.Background(), c.config.RMQExpiredEventQueue, msg)
+	if err != nil {
+		return err
+	}
+	return c.printer.message("orchestration %s marked %s and published to %s", id, constants.StatusRollback, c.config.RMQExpiredEventQueue)
+}
+
+func (c *ctl) complete(args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	err := c.swap(context.Background(), args[0], func(current statestore.Record) (*statestore.Record, error) {
+		if current.Status != constants.StatusInProgress {
+			return nil, fmt.Errorf("orchestration is %s, only %s can be completed, use purge instead", current.Status, constants.StatusInProgress)
+		}
+		return nil, nil
+	})
+	if err != nil {
+		return err
+	}
+	return c.printer.message("orchestration %s completed", args[0])
+}
+
+func (c *ctl) purge(args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	store, err := c.stateStore()
+	if err != nil {
+		return err
+	}
+	if _, err := store.Get(context.Background(), args[0]); err != nil {
+		return err
+	}
+	if err := store.Delete(context.Background(), args[0]); err != nil {
+		return err
+	}
+	return c.printer.message("orchestration %s purged", args[0])
+}
+
+func (c *ctl) summary(args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	store, err := c.stateStore()
+	if err != nil {
+		return err
+	}
+	records, err := store.List(context.Background())
+	if err != nil {
+		return err
+	}
+	summary := summaryView{
+		Statuses: map[string]int{},
+		Queues:   map[string]int64{},
+	}
+	now := time.Now().UnixMilli()
+	for _, record := range records {
+		summary.Statuses[record.Status]++
+		if record.ExpirationTime < now {
+			summary.Expired++
+		}
+	}
+
+	// queue depths are only known to rabbitmq
+	if c.config.Broker != broker.BackendRabbitMQ {
+		return c.printer.summary(summary)
+	}
+	ch, err := c.channel()
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+	for _, queue := range c.queues() {
+		state, err := ch.QueueInspect(queue.name)
+		if err != nil {
+			return err
+		}
+		summary.Queues[queue.name] = int64(state.Messages)
+	}
+	return c.printer.summary(summary)
+}
+
+// swap replaces a record with the result of update unless a service changed it meanwhile,
+// a nil result removes the record without that check.
+func (c *ctl) swap(ctx context.Context, id string, update func(current statestore.Record) (*statestore.Record, error)) error {
+	store, err := c.stateStore()
+	if err != nil {
+		return err
+	}
+	current, err := store.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	next, err := update(*current)
+	if err != nil {
+		return err
+	}
+	if next == nil {
+		return store.Delete(ctx, id)
+	}
+	if err := store.CompareAndSwap(ctx, *current, *next); errors.Is(err, statestore.ErrConflict) {
+		return errors.New("orchestration changed meanwhile, try again")
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+func toView(record statestore.Record) orchestrationView {
+	expiresAt := record.ExpiresAt().UTC()
+	return orchestrationView{
+		UUID:           record.UUID,
+		Status:         record.Status,
+		ExpirationTime: record.ExpirationTime,
+		Version:        record.Version,
+		ExpiresAt:      expiresAt,
+		Expired:        expiresAt.Before(time.Now()),
+	}
+}
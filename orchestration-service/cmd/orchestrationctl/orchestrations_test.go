@@ -0,0 +1,144 @@
+package main
+
+import (
+	"common/broker"
+	"common/signing"
+	"common/statestore"
+	"context"
+	"encoding/json"
+	"errors"
+	"orchestration-service/constants"
+	"testing"
+	"time"
+)
+
+func createRecord(t *testing.T, ctl *ctl, id string, status string, expiresIn time.Duration) {
+	t.Helper()
+	err := ctl.store.Create(context.Background(), statestore.Record{
+		UUID:           id,
+		Status:         status,
+		ExpirationTime: time.Now().Add(expiresIn).UnixMilli(),
+	})
+	if err != nil {
+		t.Fatalf("create record %s: %v", id, err)
+	}
+}
+
+func TestListPrintsOrchestrationsByExpiry(t *testing.T) {
+	ctl, out := newTestCtl(t, outputJson)
+	createRecord(t, ctl, "later", constants.StatusInProgress, time.Minute)
+	createRecord(t, ctl, "expired", constants.StatusRollback, -time.Minute)
+
+	if err := ctl.run("list", nil); err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	var views []orchestrationView
+	if err := json.Unmarshal(out.Bytes(), &views); err != nil {
+		t.Fatalf("decode %q: %v", out.String(), err)
+	}
+	if len(views) != 2 || views[0].UUID != "expired" || !views[0].Expired || views[1].UUID != "later" || views[1].Expired {
+		t.Fatalf("expected the expired orchestration first, got %+v", views)
+	}
+}
+
+func TestShowReportsUnknownOrchestrations(t *testing.T) {
+	ctl, _ := newTestCtl(t, outputTable)
+	if err := ctl.run("show", []string{"missing"}); !errors.Is(err, statestore.ErrNotFound) {
+		t.Fatalf("expected %v, got %v", statestore.ErrNotFound, err)
+	}
+}
+
+func TestRollbackMarksAndPublishesTheOrchestration(t *testing.T) {
+	ctl, _ := newTestCtl(t, outputTable)
+	ctl.config.MessageSigningKeyId = "ctl-1"
+	ctl.config.MessageSigningSecret = "secret"
+	createRecord(t, ctl, "stuck", constants.StatusInProgress, time.Minute)
+
+	if err := ctl.run("rollback", []string{"stuck"}); err != nil {
+		t.Fatalf("rollback: %v", err)
+	}
+	record, err := ctl.store.Get(context.Background(), "stuck")
+	if err != nil || record.Status != constants.StatusRollback {
+		t.Fatalf("expected the orchestration in %s, got %+v with %v", constants.StatusRollback, record, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	published := make(chan broker.Message, 1)
+	err = ctl.broker.Subscribe(ctx, ctl.config.RMQExpiredEventQueue, func(ctx context.Context, delivery broker.Delivery) {
+		delivery.Ack()
+		published <- delivery.Message
+	})
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	select {
+	case msg := <-published:
+		if string(msg.Body) != "stuck" {
+			t.Fatalf("expected the orchestration id published, got %q", msg.Body)
+		}
+		// the orchestrator quarantines unsigned expired events
+		verifier := signing.NewMessageVerifier(map[string][]byte{"ctl-1": []byte("secret")})
+		if err := verifier.VerifyMessage(msg); err != nil {
+			t.Fatalf("expected a signed message, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected the orchestration published to %s", ctl.config.RMQExpiredEventQueue)
+	}
+}
+
+func TestCompleteOnlyRemovesOrchestrationsInProgress(t *testing.T) {
+	ctl, _ := newTestCtl(t, outputTable)
+	createRecord(t, ctl, "running", constants.StatusInProgress, time.Minute)
+	createRecord(t, ctl, "rolling-back", constants.StatusRollback, time.Minute)
+
+	if err := ctl.run("complete", []string{"rolling-back"}); err == nil {
+		t.Fatalf("expected an orchestration in %s not to be completed", constants.StatusRollback)
+	}
+	if _, err := ctl.store.Get(context.Background(), "rolling-back"); err != nil {
+		t.Fatalf("expected the orchestration kept, got %v", err)
+	}
+	if err := ctl.run("complete", []string{"running"}); err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+	if _, err := ctl.store.Get(context.Background(), "running"); !errors.Is(err, statestore.ErrNotFound) {
+		t.Fatalf("expected the orchestration removed, got %v", err)
+	}
+}
+
+func TestPurgeRemovesOrchestrationsInAnyStatus(t *testing.T) {
+	ctl, _ := newTestCtl(t, outputTable)
+	createRecord(t, ctl, "rolling-back", constants.StatusRollback, time.Minute)
+
+	if err := ctl.run("purge", []string{"rolling-back"}); err != nil {
+		t.Fatalf("purge: %v", err)
+	}
+	if _, err := ctl.store.Get(context.Background(), "rolling-back"); !errors.Is(err, statestore.ErrNotFound) {
+		t.Fatalf("expected the orchestration removed, got %v", err)
+	}
+	if err := ctl.run("purge", []string{"rolling-back"}); !errors.Is(err, statestore.ErrNotFound) {
+		t.Fatalf("expected %v purging it again, got %v", statestore.ErrNotFound, err)
+	}
+}
+
+func TestSummaryCountsStatusesWithoutRabbitMQ(t *testing.T) {
+	ctl, out := newTestCtl(t, outputJson)
+	createRecord(t, ctl, "a", constants.StatusInProgress, time.Minute)
+	createRecord(t, ctl, "b", constants.StatusInProgress, -time.Minute)
+	createRecord(t, ctl, "c", constants.StatusRollback, time.Minute)
+
+	if err := ctl.run("summary", nil); err != nil {
+		t.Fatalf("summary: %v", err)
+	}
+	var summary summaryView
+	if err := json.Unmarshal(out.Bytes(), &summary); err != nil {
+		t.Fatalf("decode %q: %v", out.String(), err)
+	}
+	if summary.Statuses[constants.StatusInProgress] != 2 || summary.Statuses[constants.StatusRollback] != 1 || summary.Expired != 1 {
+		t.Fatalf("expected 2 %s, 1 %s and 1 expired, got %+v", constants.StatusInProgress, constants.StatusRollback, summary)
+	}
+	// queue depths are only known to rabbitmq
+	if len(summary.Queues) != 0 {
+		t.Fatalf("expected no queue depths, got %v", summary.Queues)
+	}
+}
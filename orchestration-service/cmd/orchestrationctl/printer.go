@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	outputTable = "table"
+	outputJson  = "json"
+)
+
+type printer struct {
+	out    io.Writer
+	format string
+}
+
+func newPrinter(out io.Writer, format string) *printer {
+	return &printer{
+		out:    out,
+		format: format,
+	}
+}
+
+func (p *printer) orchestrations(views []orchestrationView) error {
+	if p.format == outputJson {
+		return p.json(views)
+	}
+	writer := tabwriter.NewWriter(p.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "ID\tSTATUS\tVERSION\tEXPIRES AT\tEXPIRY")
+	for _, view := range views {
+		fmt.Fprintf(writer, "%s\t%s\t%d\t%s\t%s\n", view.UUID, view.Status, view.Version, view.ExpiresAt.Format(time.RFC3339), humanExpiry(view.ExpiresAt))
+	}
+	return writer.Flush()
+}
+
+func (p *printer) summary(view summaryView) error {
+	if p.format == outputJson {
+		return p.json(view)
+	}
+	writer := tabwriter.NewWriter(p.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "STATUS\tCOUNT")
+	for _, status := range sortedKeys(view.Statuses) {
+		fmt.Fprintf(writer, "%s\t%d\n", status, view.Statuses[status])
+	}
+	fmt.Fprintf(writer, "(expired)\t%d\n", view.Expired)
+	fmt.Fprintln(writer, "\t")
+	fmt.Fprintln(writer, "QUEUE\tMESSAGES")
+	for _, queue := range sortedKeys(view.Queues) {
+		fmt.Fprintf(writer, "%s\t%d\n", queue, view.Queues[queue])
+	}
+	return writer.Flush()
+}
+
+func (p *printer) messages(views []messageView) error {
+	if p.format == outputJson {
+		if views == nil {
+			views = []messageView{}
+		}
+		return p.json(views)
+	}
+	writer := tabwriter.NewWriter(p.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "QUEUE\tTARGET\tBODY\tREDELIVERED\tHEADERS")
+	for _, view := range views {
+		target := view.Target
+		if target == "" {
+			target = "-"
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%t\t%s\n", view.Queue, target, view.Body, view.Redelivered, formatHeaders(view.Headers))
+	}
+	return writer.Flush()
+}
+
+func (p *printer) message(format string, args ...interface{}) error {
+	text := fmt.Sprintf(format, args...)
+	if p.format == outputJson {
+		return p.json(map[string]string{"message": text})
+	}
+	_, err := fmt.Fprintln(p.out, text)
+	return err
+}
+
+func (p *printer) json(value interface{}) error {
+	encoder := json.NewEncoder(p.out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+// humanExpiry renders the expiration relative to now, e.g. "in 4s" or "expired 2m10s ago".
+func humanExpiry(expiresAt time.Time) string {
+	remaining := time.Until(expiresAt).Round(time.Second)
+	if remaining >= 0 {
+		return "in " + remaining.String()
+	}
+	return "expired " + (-remaining).String() + " ago"
+}
+
+func formatHeaders(headers map[string]interface{}) string {
+	if len(headers) == 0 {
+		return "-"
+	}
+	pairs := make([]string, 0, len(headers))
+	for _, key := range sortedKeys(headers) {
+		pairs = append(pairs, fmt.Sprintf("%s=%v", key, headers[key]))
+	}
+	return strings.Join(pairs, ",")
+}
+
+func sortedKeys[V any](values map[string]V) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/streadway/amqp"
+	"orchestration-service/connection"
+)
+
+type queue struct {
+	alias string
+	name  string
+}
+
+type messageView struct {
+	Queue       string                 `json:"queue"`
+	Target      string                 `json:"target,omitempty"`
+	Body        string                 `json:"body"`
+	Headers     map[string]interface{} `json:"headers,omitempty"`
+	Redelivered bool                   `json:"redelivered"`
+}
+
+func (c *ctl) queues() []queue {
+	return []queue{
+		{alias: "expired", name: c.config.RMQExpiredEventQueue},
+		{alias: "rollback-order", name: c.config.RMQRollbackEventOrderQueue},
+		{alias: "rollback-payment", name: c.config.RMQRollbackEventPaymentQueue},
+		{alias: "rollback-inventory", name: c.config.RMQRollbackEventInventoryQueue},
+		{alias: "dead-letter", name: c.config.RMQDeadLetterQueue},
+		{alias: "quarantine", name: c.config.RMQQuarantineQueue},
+	}
+}
+
+// resolveQueue accepts an alias or a configured queue name, other queues are not touched by the tool.
+func (c *ctl) resolveQueue(nameOrAlias string) (string, error) {
+	for _, queue := range c.queues() {
+		if queue.alias == nameOrAlias || queue.name == nameOrAlias {
+			return queue.name, nil
+		}
+	}
+	return "", fmt.Errorf("unknown queue %s", nameOrAlias)
+}
+
+// peek reads messages and puts copies of them back at the end of their queue. Requeueing the messages themselves
+// would flag them redelivered, which the consumers take for a failed attempt and dead-letter on their next failure.
+func (c *ctl) peek(args []string) error {
+	values, count, err := parseArgs("peek", args, 1)
+	if err != nil {
+		return err
+	}
+	from, err := c.resolveQueue(values[0])
+	if err != nil {
+		return err
+	}
+
+	views, err := c.transfer(from, count, func(amqp.Delivery) string {
+		return from
+	})
+	for i := range views {
+		views[i].Target = ""
+	}
+	return c.printTransferred(views, err)
+}
+
+func (c *ctl) move(args []string) error {
+	values, count, err := parseArgs("move", args, 2)
+	if err != nil {
+		return err
+	}
+	from, err := c.resolveQueue(values[0])
+	if err != nil {
+		return err
+	}
+	to, err := c.resolveQueue(values[1])
+	if err != nil {
+		return err
+	}
+	if from == to {
+		return errors.New("source and target queue are the same")
+	}
+
+	views, err := c.transfer(from, count, func(amqp.Delivery) string {
+		return to
+	})
+	return c.printTransferred(views, err)
+}
+
+// replay moves dead-lettered messages back to the queue they failed on, the expired queue when unknown.
+func (c *ctl) replay(args []string) error {
+	_, count, err := parseArgs("replay", args, 0)
+	if err != nil {
+		return err
+	}
+
+	views, err := c.transfer(c.config.RMQDeadLetterQueue, count, func(msg amqp.Delivery) string {
+		if original, ok := msg.Headers[connection.HeaderOriginalQueue].(string); ok {
+			if target, err := c.resolveQueue(original); err == nil {
+				return target
+			}
+		}
+		return c.config.RMQExpiredEventQueue
+	})
+	return c.printTransferred(views, err)
+}
+
+// transfer republishes up to count messages and acknowledges each one only after the broker confirmed the copy,
+// so a failure midway leaves the rest of the messages where they were.
+func (c *ctl) transfer(from string, count int, target func(amqp.Delivery) string) ([]messageView, error) {
+	ch, err := c.channel()
+	if err != nil {
+		return nil, err
+	}
+	defer ch.Close()
+	if err := ch.Confirm(false); err != nil {
+		return nil, err
+	}
+	confirms := ch.NotifyPublish(make(chan amqp.Confirmation, 1))
+	// only the messages waiting now, copies put back at the end of the same queue are not read again
+	state, err := ch.QueueInspect(from)
+	if err != nil {
+		return nil, err
+	}
+	if state.Messages < count {
+		count = state.Messages
+	}
+
+	var views []messageView
+	for len(views) < count {
+		msg, ok, err := ch.Get(from, false)
+		if err != nil {
+			return views, err
+		}
+		if !ok {
+			break
+		}
+
+		to := target(msg)
+		headers := amqp.Table{}
+		for key, value := range msg.Headers {
+			headers[key] = value
+		}
+		// copies put back by peek keep their headers
+		switch to {
+		case from:
+		case c.config.RMQDeadLetterQueue:
+			headers[connection.HeaderOriginalQueue] = from
+		default:
+			delete(headers, connection.HeaderOriginalQueue)
+		}
+
+		err = ch.Publish("", to, false, false, amqp.Publishing{
+			ContentType:  msg.ContentType,
+			DeliveryMode: msg.DeliveryMode,
+			Headers:      headers,
+			Body:         msg.Body,
+		})
+		if err != nil {
+			msg.Nack(false, true)
+			return views, err
+		}
+		if confirm := <-confirms; !confirm.Ack {
+			msg.Nack(false, true)
+			return views, fmt.Errorf("broker rejected message %s for %s", string(msg.Body), to)
+		}
+		if err := msg.Ack(false); err != nil {
+			return views, err
+		}
+		views = append(views, toMessageView(from, to, msg))
+	}
+	return views, nil
+}
+
+// printTransferred prints the messages handled before a failure as well, nothing when none were.
+func (c *ctl) printTransferred(views []messageView, err error) error {
+	if err != nil && len(views) == 0 {
+		return err
+	}
+	if printErr := c.printer.messages(views); printErr != nil && err == nil {
+		err = printErr
+	}
+	return err
+}
+
+func toMessageView(queue string, target string, msg amqp.Delivery) messageView {
+	return messageView{
+		Queue:       queue,
+		Target:      target,
+		Body:        string(msg.Body),
+		Headers:     msg.Headers,
+		Redelivered: msg.Redelivered,
+	}
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"common/broker"
+	"encoding/json"
+	"orchestration-service/connection"
+	"reflect"
+	"testing"
+
+	"github.com/streadway/amqp"
+)
+
+// fakeRabbit keeps queues in memory and acknowledges deliveries like rabbitmq, a nacked message goes back to the head
+// of its queue flagged redelivered.
+type fakeRabbit struct {
+	queues   map[string][]amqp.Delivery
+	unacked  map[uint64]unackedDelivery
+	nextTag  uint64
+	confirms chan amqp.Confirmation
+	// number of the publish the broker rejects, starting at 1, 0 accepts all
+	rejectPublish int
+	published     int
+}
+
+type unackedDelivery struct {
+	amqp.Delivery
+	queue string
+}
+
+func newFakeRabbit() *fakeRabbit {
+	return &fakeRabbit{
+		queues:  map[string][]amqp.Delivery{},
+		unacked: map[uint64]unackedDelivery{},
+	}
+}
+
+func (f *fakeRabbit) put(queue string, body string, headers amqp.Table) {
+	f.queues[queue] = append(f.queues[queue], amqp.Delivery{
+		ContentType:  "application/json",
+		DeliveryMode: amqp.Persistent,
+		Headers:      headers,
+		Body:         []byte(body),
+	})
+}
+
+func (f *fakeRabbit) bodies(queue string) []string {
+	bodies := []string{}
+	for _, msg := range f.queues[queue] {
+		bodies = append(bodies, string(msg.Body))
+	}
+	return bodies
+}
+
+func (f *fakeRabbit) Confirm(bool) error {
+	return nil
+}
+
+func (f *fakeRabbit) NotifyPublish(confirm chan amqp.Confirmation) chan amqp.Confirmation {
+	f.confirms = confirm
+	return confirm
+}
+
+func (f *fakeRabbit) QueueInspect(name string) (amqp.Queue, error) {
+	return amqp.Queue{Name: name, Messages: len(f.queues[name])}, nil
+}
+
+func (f *fakeRabbit) Get(queue string, autoAck bool) (amqp.Delivery, bool, error) {
+	if len(f.queues[queue]) == 0 {
+		return amqp.Delivery{}, false, nil
+	}
+	msg := f.queues[queue][0]
+	f.queues[queue] = f.queues[queue][1:]
+	f.nextTag++
+	msg.DeliveryTag = f.nextTag
+	msg.Acknowledger = f
+	f.unacked[msg.DeliveryTag] = unackedDelivery{Delivery: msg, queue: queue}
+	return msg, true, nil
+}
+
+func (f *fakeRabbit) Publish(exchange string, key string, mandatory bool, immediate bool, msg amqp.Publishing) error {
+	f.published++
+	accepted := f.published != f.rejectPublish
+	if accepted {
+		f.queues[key] = append(f.queues[key], amqp.Delivery{
+			ContentType:  msg.ContentType,
+			DeliveryMode: msg.DeliveryMode,
+			Headers:      msg.Headers,
+			Body:         msg.Body,
+		})
+	}
+	if f.confirms != nil {
+		f.confirms <- amqp.Confirmation{DeliveryTag: uint64(f.published), Ack: accepted}
+	}
+	return nil
+}
+
+func (f *fakeRabbit) Close() error {
+	return nil
+}
+
+func (f *fakeRabbit) Ack(tag uint64, multiple bool) error {
+	delete(f.unacked, tag)
+	return nil
+}
+
+func (f *fakeRabbit) Nack(tag uint64, multiple bool, requeue bool) error {
+	return f.Reject(tag, requeue)
+}
+
+func (f *fakeRabbit) Reject(tag uint64, requeue bool) error {
+	msg := f.unacked[tag]
+	delete(f.unacked, tag)
+	if requeue {
+		msg.Redelivered = true
+		f.queues[msg.queue] = append([]amqp.Delivery{msg.Delivery}, f.queues[msg.queue]...)
+	}
+	return nil
+}
+
+func TestResolveQueue(t *testing.T) {
+	ctl, _ := newTestCtl(t, outputTable)
+	tests := []struct {
+		nameOrAlias string
+		queue       string
+	}{
+		{"expired", "expired-events"},
+		{"rollback-order", "rollback-order-events"},
+		{"rollback-payment", "rollback-payment-events"},
+		{"rollback-inventory", "rollback-inventory-events"},
+		{"dead-letter", "dead-letter-events"},
+		{"quarantine", "quarantine-events"},
+		// configured names are accepted as well
+		{"rollback-payment-events", "rollback-payment-events"},
+		{"quarantine-events", "quarantine-events"},
+	}
+	for _, tt := range tests {
+		queue, err := ctl.resolveQueue(tt.nameOrAlias)
+		if err != nil || queue != tt.queue {
+			t.Fatalf("expected %s to resolve to %s, got %s with %v", tt.nameOrAlias, tt.queue, queue, err)
+		}
+	}
+
+	// queues outside the saga are never touched
+	for _, unknown := range []string{"", "order-events", "Expired"} {
+		if queue, err := ctl.resolveQueue(unknown); err == nil {
+			t.Fatalf("expected %q to be unknown, got %s", unknown, queue)
+		}
+	}
+}
+
+func TestMoveRefusesTheSameQueue(t *testing.T) {
+	ctl, _ := newTestCtl(t, outputTable)
+	if err := ctl.run("move", []string{"expired", "expired-events"}); err == nil || err.Error() != "source and target queue are the same" {
+		t.Fatalf("expected a move onto the same queue to be refused, got %v", err)
+	}
+	if err := ctl.run("move", []string{"expired", "orders"}); err == nil || err.Error() != "unknown queue orders" {
+		t.Fatalf("expected an unknown target to be refused, got %v", err)
+	}
+}
+
+// newRabbitCtl runs the queue commands against a fake rabbitmq and prints json.
+func newRabbitCtl(t *testing.T) (*ctl, *fakeRabbit, func() []messageView) {
+	t.Helper()
+	ctl, out := newTestCtl(t, outputJson)
+	rabbit := newFakeRabbit()
+	ctl.config.Broker = broker.BackendRabbitMQ
+	ctl.openChannel = func() (queueChannel, error) {
+		return rabbit, nil
+	}
+	printed := func() []messageView {
+		t.Helper()
+		var views []messageView
+		if err := json.Unmarshal(out.Bytes(), &views); err != nil {
+			t.Fatalf("parse output %q: %v", out.String(), err)
+		}
+		out.Reset()
+		return views
+	}
+	return ctl, rabbit, printed
+}
+
+func bodiesOf(views []messageView) []string {
+	bodies := []string{}
+	for _, view := range views {
+		bodies = append(bodies, view.Body)
+	}
+	return bodies
+}
+
+func TestPeekPutsCopiesBackWithoutRedelivery(t *testing.T) {
+	ctl, rabbit, printed := newRabbitCtl(t)
+	rabbit.put("expired-events", "a", amqp.Table{connection.HeaderOriginalQueue: "rollback-order-events"})
+	rabbit.put("expired-events", "b", nil)
+	rabbit.put("expired-events", "c", nil)
+
+	if err := ctl.run("peek", []string{"expired", "-n", "2"}); err != nil {
+		t.Fatalf("peek: %v", err)
+	}
+	views := printed()
+	if !reflect.DeepEqual(bodiesOf(views), []string{"a", "b"}) {
+		t.Fatalf("expected a and b to be peeked, got %+v", views)
+	}
+	if views[0].Queue != "expired-events" || views[0].Target != "" || views[0].Redelivered {
+		t.Fatalf("expected a message of the expired queue without target, got %+v", views[0])
+	}
+	// the copies go to the end of the queue, nothing is left unacknowledged or flagged redelivered
+	if !reflect.DeepEqual(rabbit.bodies("expired-events"), []string{"c", "a", "b"}) || len(rabbit.unacked) != 0 {
+		t.Fatalf("expected the copies at the end of the queue, got %v with %d unacknowledged", rabbit.bodies("expired-events"), len(rabbit.unacked))
+	}
+	for _, msg := range rabbit.queues["expired-events"] {
+		if msg.Redelivered {
+			t.Fatalf("expected %s not to be flagged redelivered", msg.Body)
+		}
+	}
+	if header := rabbit.queues["expired-events"][1].Headers[connection.HeaderOriginalQueue]; header != "rollback-order-events" {
+		t.Fatalf("expected the copy to keep its headers, got %v", header)
+	}
+
+	// a count beyond the queue reads every message once instead of the copies again
+	if err := ctl.run("peek", []string{"expired"}); err != nil {
+		t.Fatalf("peek: %v", err)
+	}
+	if bodies := bodiesOf(printed()); !reflect.DeepEqual(bodies, []string{"c", "a", "b"}) {
+		t.Fatalf("expected every message to be peeked once, got %v", bodies)
+	}
+	if bodies := rabbit.bodies("expired-events"); !reflect.DeepEqual(bodies, []string{"c", "a", "b"}) {
+		t.Fatalf("expected the queue to be unchanged, got %v", bodies)
+	}
+}
+
+func TestMoveTransfersMessagesBetweenQueues(t *testing.T) {
+	ctl, rabbit, printed := newRabbitCtl(t)
+	rabbit.put("quarantine-events", "a", amqp.Table{connection.HeaderOriginalQueue: "rollback-payment-events"})
+	rabbit.put("quarantine-events", "b", nil)
+	rabbit.put("quarantine-events", "c", nil)
+
+	if err := ctl.run("move", []string{"quarantine", "dead-letter", "-n", "2"}); err != nil {
+		t.Fatalf("move: %v", err)
+	}
+	views := printed()
+	if !reflect.DeepEqual(bodiesOf(views), []string{"a", "b"}) || views[0].Target != "dead-letter-events" {
+		t.Fatalf("expected a and b to be moved to the dead letter queue, got %+v", views)
+	}
+	if bodies := rabbit.bodies("quarantine-events"); !reflect.DeepEqual(bodies, []string{"c"}) {
+		t.Fatalf("expected c to stay quarantined, got %v", bodies)
+	}
+	// dead-lettered messages remember where they came from so they can be replayed
+	for _, msg := range rabbit.queues["dead-letter-events"] {
+		if header := msg.Headers[connection.HeaderOriginalQueue]; header != "quarantine-events" {
+			t.Fatalf("expected %s to come from the quarantine, got %v", msg.Body, header)
+		}
+	}
+
+	if err := ctl.run("move", []string{"dead-letter", "rollback-order"}); err != nil {
+		t.Fatalf("move: %v", err)
+	}
+	printed()
+	for _, msg := range rabbit.queues["rollback-order-events"] {
+		if _, ok := msg.Headers[connection.HeaderOriginalQueue]; ok {
+			t.Fatalf("expected %s to lose its original queue outside the dead letter queue", msg.Body)
+		}
+	}
+	if bodies := rabbit.bodies("rollback-order-events"); !reflect.DeepEqual(bodies, []string{"a", "b"}) {
+		t.Fatalf("expected a and b on the rollback queue, got %v", bodies)
+	}
+}
+
+func TestReplayReturnsDeadLettersToTheirQueue(t *testing.T) {
+	ctl, rabbit, printed := newRabbitCtl(t)
+	rabbit.put("dead-letter-events", "payment", amqp.Table{connection.HeaderOriginalQueue: "rollback-payment-events"})
+	rabbit.put("dead-letter-events", "unknown", amqp.Table{connection.HeaderOriginalQueue: "order-events"})
+	rabbit.put("dead-letter-events", "none", nil)
+
+	if err := ctl.run("replay", nil); err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	targets := map[string]string{}
+	for _, view := range printed() {
+		targets[view.Body] = view.Target
+	}
+	expected := map[string]string{
+		"payment": "rollback-payment-events",
+		// queues the tool does not manage and missing headers fall back to the expired queue
+		"unknown": "expired-events",
+		"none":    "expired-events",
+	}
+	if !reflect.DeepEqual(targets, expected) {
+		t.Fatalf("expected targets %v, got %v", expected, targets)
+	}
+	if len(rabbit.queues["dead-letter-events"]) != 0 {
+		t.Fatalf("expected the dead letter queue to be drained, got %v", rabbit.bodies("dead-letter-events"))
+	}
+	if msg := rabbit.queues["rollback-payment-events"][0]; msg.Headers[connection.HeaderOriginalQueue] != nil {
+		t.Fatalf("expected the replayed message to lose its original queue, got %v", msg.Headers)
+	}
+}
+
+// a copy the broker does not confirm leaves its message and the rest in the source queue, the ones before are moved.
+func TestTransferStopsAtAnUnconfirmedCopy(t *testing.T) {
+	ctl, rabbit, printed := newRabbitCtl(t)
+	rabbit.rejectPublish = 2
+	rabbit.put("dead-letter-events", "a", nil)
+	rabbit.put("dead-letter-events", "b", nil)
+	rabbit.put("dead-letter-events", "c", nil)
+
+	err := ctl.run("move", []string{"dead-letter", "expired"})
+	if err == nil || err.Error() != "broker rejected message b for expired-events" {
+		t.Fatalf("expected the rejected copy to be reported, got %v", err)
+	}
+	if bodies := bodiesOf(printed()); !reflect.DeepEqual(bodies, []string{"a"}) {
+		t.Fatalf("expected only a to be reported moved, got %v", bodies)
+	}
+	if bodies := rabbit.bodies("expired-events"); !reflect.DeepEqual(bodies, []string{"a"}) {
+		t.Fatalf("expected only a on the expired queue, got %v", bodies)
+	}
+	if bodies := rabbit.bodies("dead-letter-events"); !reflect.DeepEqual(bodies, []string{"b", "c"}) {
+		t.Fatalf("expected b and c to stay dead-lettered in order, got %v", bodies)
+	}
+	if len(rabbit.unacked) != 0 {
+		t.Fatalf("expected no unacknowledged messages, got %d", len(rabbit.unacked))
+	}
+}
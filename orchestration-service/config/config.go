@@ -5,10 +5,21 @@ import (
 )
 
 type Config struct {
+	// database config, only used when the orchestrations are stored in postgres
+	DBHost         string `mapstructure:"POSTGRES_HOST"`
+	DBUsername     string `mapstructure:"POSTGRES_USER"`
+	DBUserPassword string `mapstructure:"POSTGRES_PASSWORD"`
+	DBName         string `mapstructure:"POSTGRES_NAME"`
+	DBPort         string `mapstructure:"POSTGRES_PORT"`
+
 	RedisHost string `mapstructure:"REDIS_HOST"`
 	RedisPort string `mapstructure:"REDIS_PORT"`
 	RedisDB   int    `mapstructure:"REDIS_DB"`
 
+	// broker config, rabbitmq, nats or memory, the RMQ_ queue names are used on every broker
+	Broker  string `mapstructure:"BROKER"`
+	NatsUrl string `mapstructure:"NATS_URL"`
+
 	// this queue is consumed by orchestration service, for expired events
 	RMQExpiredEventQueue string `mapstructure:"RMQ_EXPIRED_EVENT_QUEUE"`
 	RMQUrl               string `mapstructure:"RMQ_URL"`
@@ -16,15 +27,32 @@ type Config struct {
 	// this queue is consumed by related service, for example order-service
 	RMQRollbackEventPaymentQueue string `mapstructure:"RMQ_ROLLBACK_PAYMENT_EVENT_QUEUE"`
 	RMQRollbackEventOrderQueue   string `mapstructure:"RMQ_ROLLBACK_ORDER_EVENT_QUEUE"`
+	// consumed by inventory-service to release stock reservations
+	RMQRollbackEventInventoryQueue string `mapstructure:"RMQ_ROLLBACK_INVENTORY_EVENT_QUEUE"`
+	// expired events failing a second time are parked here, orchestrationctl can replay them
+	RMQDeadLetterQueue string `mapstructure:"RMQ_DEAD_LETTER_QUEUE"`
 
 	OrchestrationExpirationTimeSeconds int64  `mapstructure:"ORCHESTRATION_EXPIRATION_TIME_SECONDS"`
 	OrchestrationMapName               string `mapstructure:"ORCHESTRATION_MAP_NAME"`
+	// redis, postgres or memory, has to match the store of the order-service
+	OrchestrationStore string `mapstructure:"ORCHESTRATION_STORE"`
 
 	RollbackMinThreads              int `mapstructure:"ROLLBACK_MIN_THREADS"`
 	RollbackMaxThreads              int `mapstructure:"ROLLBACK_MAX_THREADS"`
 	RollbackMaxThreadsKeepAliveTime int `mapstructure:"ROLLBACK_MAX_THREADS_KEEP_ALIVE_TIME"`
 
 	StaleJobSchedulePeriodMilliseconds int64 `mapstructure:"STALE_JOB_SCHEDULE_PERIOD"`
+
+	// key the expired and rollback events are signed with, the consuming services have to accept it
+	MessageSigningKeyId  string `mapstructure:"MESSAGE_SIGNING_KEY_ID"`
+	MessageSigningSecret string `mapstructure:"MESSAGE_SIGNING_SECRET"`
+	// expired events have to be signed with one of these keyId:secret pairs separated by commas,
+	// unsigned and forged ones are moved to the quarantine queue
+	MessageSigningKeys string `mapstructure:"MESSAGE_SIGNING_KEYS"`
+	RMQQuarantineQueue string `mapstructure:"RMQ_QUARANTINE_QUEUE"`
+
+	// faults armed at startup, point=kind[:arg][*count] separated by commas, only honoured by -tags faults builds
+	Faults string `mapstructure:"FAULTS"`
 }
 
 func LoadConfig(path string) (config Config, err error) {
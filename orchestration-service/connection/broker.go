@@ -0,0 +1,23 @@
+package connection
+
+import (
+	"common/broker"
+	"orchestration-service/config"
+)
+
+// NewBroker connects to the broker selected by BROKER and declares all orchestration queues on it.
+func NewBroker(cfg config.Config) (broker.Broker, error) {
+	messageBroker, err := broker.New(broker.Config{
+		Backend:     cfg.Broker,
+		RabbitMQUrl: cfg.RMQUrl,
+		NatsUrl:     cfg.NatsUrl,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := messageBroker.Declare(Queues(cfg)...); err != nil {
+		messageBroker.Close()
+		return nil, err
+	}
+	return messageBroker, nil
+}
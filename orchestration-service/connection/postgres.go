@@ -0,0 +1,15 @@
+package connection
+
+import (
+	"fmt"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"orchestration-service/config"
+)
+
+// NewPostgres connects to the database holding the orchestrations table when ORCHESTRATION_STORE=postgres.
+func NewPostgres(cfg config.Config) (*gorm.DB, error) {
+	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s",
+		cfg.DBHost, cfg.DBUsername, cfg.DBUserPassword, cfg.DBName, cfg.DBPort)
+	return gorm.Open(postgres.Open(dsn), &gorm.Config{})
+}
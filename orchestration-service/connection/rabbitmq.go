@@ -0,0 +1,51 @@
+package connection
+
+import (
+	"common/signing"
+	"github.com/streadway/amqp"
+	"orchestration-service/config"
+)
+
+// HeaderOriginalQueue is set on dead-lettered and quarantined messages, so they can be replayed to where they came from
+const HeaderOriginalQueue = signing.HeaderOriginalQueue
+
+// Queues returns every queue the orchestrator produces to or consumes from.
+func Queues(cfg config.Config) []string {
+	return []string{
+		cfg.RMQExpiredEventQueue,
+		cfg.RMQRollbackEventOrderQueue,
+		cfg.RMQRollbackEventPaymentQueue,
+		cfg.RMQRollbackEventInventoryQueue,
+		cfg.RMQDeadLetterQueue,
+		cfg.RMQQuarantineQueue,
+	}
+}
+
+// NewRabbitMQ dials rabbitmq directly for the orchestrationctl queue commands and declares all orchestration queues.
+func NewRabbitMQ(cfg config.Config) (*amqp.Connection, *amqp.Channel, error) {
+	conn, err := amqp.Dial(cfg.RMQUrl)
+	if err != nil {
+		return nil, nil, err
+	}
+	ch, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	for _, queue := range Queues(cfg) {
+		_, err = ch.QueueDeclare(
+			queue,
+			true,
+			false,
+			false,
+			false,
+			nil,
+		)
+		if err != nil {
+			ch.Close()
+			conn.Close()
+			return nil, nil, err
+		}
+	}
+	return conn, ch, nil
+}
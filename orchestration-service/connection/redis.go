@@ -0,0 +1,24 @@
+package connection
+
+import (
+	"context"
+	"github.com/go-redis/redis/v8"
+	"orchestration-service/config"
+	"time"
+)
+
+// NewRedisClient connects to the redis holding the orchestration records and pings it.
+func NewRedisClient(cfg config.Config) (*redis.Client, error) {
+	addr := cfg.RedisHost + ":" + cfg.RedisPort
+	client := redis.NewClient(&redis.Options{
+		Addr: addr,
+		DB:   cfg.RedisDB,
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, err
+	}
+	return client, nil
+}
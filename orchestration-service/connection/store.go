@@ -0,0 +1,29 @@
+package connection
+
+import (
+	"common/statestore"
+	"fmt"
+	"orchestration-service/config"
+)
+
+// NewStateStore connects only to the backend selected by ORCHESTRATION_STORE,
+// the postgres store runs without redis.
+func NewStateStore(cfg config.Config) (statestore.StateStore, error) {
+	switch cfg.OrchestrationStore {
+	case statestore.BackendRedis:
+		client, err := NewRedisClient(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return statestore.NewRedisStore(client, cfg.OrchestrationMapName), nil
+	case statestore.BackendPostgres:
+		db, err := NewPostgres(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return statestore.NewPostgresStore(db), nil
+	case statestore.BackendMemory:
+		return statestore.NewMemoryStore(), nil
+	}
+	return nil, fmt.Errorf("unknown orchestration store %s", cfg.OrchestrationStore)
+}
@@ -1,7 +0,0 @@
-package dto
-
-type OrchestrationModel struct {
-	UUID           string `json:"uuid"`
-	Status         string `json:"status"`
-	ExpirationTime int64  `json:"expirationTime"`
-}
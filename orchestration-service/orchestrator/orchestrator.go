@@ -0,0 +1,177 @@
+package orchestrator
+
+import (
+	"common/broker"
+	"common/fault"
+	"common/signing"
+	"common/statestore"
+	"context"
+	"errors"
+	"log"
+	"orchestration-service/config"
+	"orchestration-service/constants"
+	"sync"
+	"time"
+)
+
+// Orchestrator expires stale sagas and fans rollbacks out to the services.
+type Orchestrator struct {
+	store  statestore.StateStore
+	broker broker.Broker
+	config config.Config
+	// signs the published events and verifies the expired events, nil turns either off
+	signer   *signing.Signer
+	verifier *signing.MessageVerifier
+}
+
+func New(store statestore.StateStore, broker broker.Broker, cfg config.Config, signer *signing.Signer, verifier *signing.MessageVerifier) *Orchestrator {
+	return &Orchestrator{
+		store:    store,
+		broker:   broker,
+		config:   cfg,
+		signer:   signer,
+		verifier: verifier,
+	}
+}
+
+// Run starts the expired orchestration job and the rollback consumer and blocks until ctx is done.
+func (o *Orchestrator) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+
+	// Start expired orchestration job.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		o.expiredOrchestrationJob(ctx)
+	}()
+
+	// Start rollback consumer.
+	wg.Add(1)
+	go o.rollbackConsumer(ctx, &wg)
+
+	wg.Wait()
+}
+
+// periodically checks for expired orchestrations in the state store.
+func (o *Orchestrator) expiredOrchestrationJob(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(o.config.StaleJobSchedulePeriodMilliseconds) * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			o.processExpiredOrchestrations(ctx)
+		case <-ctx.Done():
+			log.Println("Expired orchestration job shutting down")
+			return
+		}
+	}
+}
+
+// scans the state store for expired records and updates them.
+func (o *Orchestrator) processExpiredOrchestrations(ctx context.Context) {
+	records, err := o.store.ListExpired(ctx, time.Now())
+	if err != nil {
+		log.Println("Error fetching expired records:", err)
+		return
+	}
+	for _, entity := range records {
+		id := entity.UUID
+		newEntity := statestore.Record{
+			Status:         constants.StatusRollback,
+			ExpirationTime: time.Now().UnixMilli() + o.config.OrchestrationExpirationTimeSeconds*1000,
+		}
+		if err := o.store.CompareAndSwap(ctx, entity, newEntity); err != nil {
+			log.Println("Failed to update orchestration", id, ":", err)
+		} else {
+			log.Println("Found expired orchestration", id, "set to ROLLBACK")
+			if err := o.publishEvent(id, o.config.RMQExpiredEventQueue); err != nil {
+				log.Println("Failed to publish rollback message for", id, ":", err)
+			}
+		}
+	}
+}
+
+func (o *Orchestrator) publishEvent(orchestrationId string, queue string) error {
+	msg := broker.Message{Body: []byte(orchestrationId)}
+	o.signer.SignMessage(&msg)
+	return o.broker.Publish(context.Background(), queue, msg)
+}
+
+// orchestrator/application will publish on this queue once it will need to rollback
+// performs the rollback steps for a given orchestration ID.
+// It updates the record to a rollback-in-progress state, publishes the rollback event to the broker (later orchestrator/application will took over and rollback on it's side)
+// and finally removes the record from the state store.
+func (o *Orchestrator) processRollback(ctx context.Context, orchestrationId string) error {
+	entity, err := o.store.Get(ctx, orchestrationId)
+	if errors.Is(err, statestore.ErrNotFound) {
+		log.Println("Orchestration", orchestrationId, "not found")
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if entity.Status != constants.StatusRollback {
+		log.Println("Orchestration", orchestrationId, "status is not ROLLBACK, skipping")
+		return nil
+	}
+	newEntity := statestore.Record{
+		Status:         constants.StatusInProgress,
+		ExpirationTime: time.Now().UnixMilli() + o.config.OrchestrationExpirationTimeSeconds*1000,
+	}
+	if err := o.store.CompareAndSwap(ctx, *entity, newEntity); err != nil {
+		log.Println("Failed to update orchestration to IN_PROGRESS for", orchestrationId, ":", err)
+		return err
+	}
+	log.Println("Processing rollback for orchestration", orchestrationId)
+
+	// we have to publish an event on a queue for each consumer, as rmq does not support other elegant solution
+	if err := o.publishEvent(orchestrationId, o.config.RMQRollbackEventPaymentQueue); err != nil {
+		log.Println("Failed to publish payment rollback event for", orchestrationId, ":", err)
+		return err
+	}
+	if err := o.publishEvent(orchestrationId, o.config.RMQRollbackEventOrderQueue); err != nil {
+		log.Println("Failed to publish order rollback event for", orchestrationId, ":", err)
+		return err
+	}
+	if err := o.publishEvent(orchestrationId, o.config.RMQRollbackEventInventoryQueue); err != nil {
+		log.Println("Failed to publish inventory rollback event for", orchestrationId, ":", err)
+		return err
+	}
+	log.Println("Published rollback event for orchestration", orchestrationId)
+
+	if err := fault.Inject(fault.OrchestratorRollbackDelete); err != nil {
+		return err
+	}
+
+	// Remove the orchestration record after successful event publication.
+	if err := o.store.Delete(ctx, orchestrationId); err != nil {
+		return err
+	}
+	log.Println("Rollback processed for orchestration", orchestrationId)
+	return nil
+}
+
+// listens to the expired queue and processes rollback messages.
+func (o *Orchestrator) rollbackConsumer(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	queue := o.config.RMQExpiredEventQueue
+	err := o.broker.Subscribe(ctx, queue, signing.Guard(o.broker, o.verifier, queue, o.config.RMQQuarantineQueue, func(ctx context.Context, delivery broker.Delivery) {
+		go func(d broker.Delivery) {
+			err := o.processRollback(ctx, string(d.Body))
+			if err != nil {
+				log.Println("Error processing rollback for", string(d.Body), ":", err)
+			}
+			// a rollback failing on redelivery as well is parked, so it does not spin forever
+			broker.Settle(ctx, o.broker, queue, o.config.RMQDeadLetterQueue, d, err)
+		}(delivery)
+	}))
+	if err != nil {
+		log.Println("Failed to register a consumer:", err)
+		return
+	}
+
+	<-ctx.Done()
+	log.Println("Rollback consumer shutting down")
+}
@@ -0,0 +1,167 @@
+package app
+
+import (
+	"common/apispec"
+	"common/auth"
+	"common/broker"
+	"common/fault"
+	"common/signing"
+	"common/statestore"
+	"context"
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"google.golang.org/grpc"
+	"gorm.io/gorm"
+	"log"
+	"order-service/client"
+	"order-service/configs"
+	"order-service/event"
+	"order-service/handler"
+	"order-service/orchestration"
+	"order-service/ratelimit"
+	"order-service/repository"
+	"order-service/route"
+	"order-service/service"
+	"strings"
+	"time"
+)
+
+// Dependencies are the connections the order-service runs on, main dials the real ones
+// and the end-to-end tests pass in-process stand-ins.
+type Dependencies struct {
+	DB     *gorm.DB
+	Redis  *redis.Client
+	Broker broker.Broker
+	Store  statestore.StateStore
+	// verifies the tokens of customers, nil disables auth
+	Verifier *auth.Verifier
+	// signs the published expired events and verifies the consumed rollback messages, nil turns either off
+	MessageSigner   *signing.Signer
+	MessageVerifier *signing.MessageVerifier
+	// OpenAPI document checking the traffic and served at /openapi.json, nil serves neither
+	Spec *apispec.Spec
+	// connection to the gRPC API of the payment-service, nil calls its REST API instead
+	PaymentConn grpc.ClientConnInterface
+}
+
+// App is the wired order-service, Start runs its background consumers and Router serves its API.
+type App struct {
+	Router                *gin.Engine
+	config                *configs.Config
+	orderService          *service.OrderService
+	reconciliationService *service.ReconciliationService
+	rollbackConsumer      *orchestration.RollbackConsumer
+	rateLimiter           *ratelimit.Limiter
+}
+
+func New(cfg *configs.Config, deps Dependencies) *App {
+	var signer *signing.Signer
+	if cfg.ServiceSigningKeyId != "" {
+		signer = signing.NewSigner(cfg.ServiceSigningKeyId, cfg.ServiceSigningSecret)
+	}
+	var paymentClient client.PaymentClientInterface = client.NewPaymentClient(cfg.PaymentClientBaseUrl, signer)
+	if deps.PaymentConn != nil {
+		paymentClient = client.NewPaymentGrpcClient(deps.PaymentConn, signer, time.Duration(cfg.PaymentGrpcTimeoutSeconds)*time.Second)
+	}
+	inventoryClient := client.NewInventoryClient(cfg.InventoryClientBaseUrl)
+
+	producer := orchestration.NewProducer(cfg, deps.Broker, deps.MessageSigner)
+	orchestrationManager := orchestration.NewOrchestrationManager(deps.Store, producer, cfg)
+
+	orderRepository := repository.NewOrderRepository()
+	productRepository := repository.NewProductRepository()
+	idempotencyRepository := repository.NewIdempotencyRepository()
+
+	redisService := service.NewRedisService(deps.Redis, time.Duration(cfg.IdempotencyKeyTTLSeconds)*time.Second)
+	orderEvents := event.NewRedisPublisher(deps.Redis, cfg)
+	orderService := service.NewOrderService(cfg, deps.DB, orderRepository, redisService, paymentClient, inventoryClient, productRepository, orchestrationManager, orderEvents, idempotencyRepository)
+
+	reconciliationService := service.NewReconciliationService(cfg, deps.DB, orderRepository, repository.NewReconciliationRepository(), paymentClient, orchestrationManager)
+
+	rateLimiter := ratelimit.NewLimiter(deps.Redis, "order-rate-limit:", rateLimitsOf(cfg))
+
+	validate := func(ctx *gin.Context) { ctx.Next() }
+	if deps.Spec != nil {
+		validate = deps.Spec.Validate()
+	}
+	orderController := handler.NewOrderHandler(deps.DB, orderService, orderEvents, cfg)
+	authenticate := auth.Authenticate(deps.Verifier)
+	orderRouteController := route.NewOrderRouteHandler(orderController, authenticate, handler.RateLimit(rateLimiter), handler.RateLimitAccount(rateLimiter), validate)
+	reconciliationRouteController := route.NewReconciliationRouteHandler(handler.NewReconciliationHandler(reconciliationService), authenticate, auth.RequireRole(auth.RoleAdmin), validate)
+
+	server := gin.Default()
+	// the client ip keys the per ip rate limit, so X-Forwarded-For is only believed from the configured proxies
+	if err := server.SetTrustedProxies(trustedProxiesOf(cfg)); err != nil {
+		log.Printf("Failed to set trusted proxies, trusting none: %v", err)
+		_ = server.SetTrustedProxies(nil)
+	}
+	corsConfig := cors.DefaultConfig()
+	corsConfig.AllowOrigins = []string{cfg.ClientOrigin}
+	corsConfig.AllowCredentials = true
+	server.Use(cors.New(corsConfig))
+	if fault.Enabled {
+		server.Any("/api/admin/faults", gin.WrapH(fault.Handler()))
+	}
+
+	if deps.Spec != nil {
+		deps.Spec.Serve(server)
+	}
+
+	router := server.Group("/api")
+	orderRouteController.OrderRoute(router)
+	reconciliationRouteController.ReconciliationRoute(router)
+
+	return &App{
+		Router:                server,
+		config:                cfg,
+		orderService:          orderService,
+		reconciliationService: reconciliationService,
+		rollbackConsumer:      orchestration.NewRollbackConsumer(deps.DB, cfg, deps.Broker, orderRepository, orderEvents, deps.MessageVerifier),
+		rateLimiter:           rateLimiter,
+	}
+}
+
+// ReloadRateLimits applies the rate limits of cfg to the following orders, the rest of cfg needs a restart.
+func (a *App) ReloadRateLimits(cfg *configs.Config) {
+	a.rateLimiter.SetLimits(rateLimitsOf(cfg))
+}
+
+func rateLimitsOf(cfg *configs.Config) ratelimit.Limits {
+	return ratelimit.Limits{
+		Enabled:    cfg.RateLimitEnabled,
+		Window:     time.Duration(cfg.RateLimitWindowSeconds) * time.Second,
+		PerAccount: cfg.RateLimitPerAccount,
+		PerIP:      cfg.RateLimitPerIP,
+		Global:     cfg.RateLimitGlobal,
+	}
+}
+
+func trustedProxiesOf(cfg *configs.Config) []string {
+	var proxies []string
+	for _, proxy := range strings.Split(cfg.TrustedProxies, ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
+
+// Start runs the rollback consumer, the reconciliation schedule and, in async mode, the order workers until ctx is done.
+func (a *App) Start(ctx context.Context) error {
+	if err := a.rollbackConsumer.Consume(ctx); err != nil {
+		return err
+	}
+	log.Println("Rollback consumer started")
+
+	if a.config.OrderProcessingMode == service.ProcessingModeAsync {
+		a.orderService.StartWorkers(ctx)
+		log.Printf("Order workers started: %d", a.config.OrderWorkerCount)
+	}
+
+	if a.config.ReconciliationEnabled {
+		a.reconciliationService.StartScheduler(ctx)
+		log.Printf("Reconciliation scheduled every %d seconds", a.config.ReconciliationPeriodSeconds)
+	}
+	return nil
+}
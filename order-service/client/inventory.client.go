@@ -0,0 +1,50 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+type InventoryClientInterface interface {
+	Reserve(payload ReservationRequest) (int, error)
+}
+
+type InventoryClient struct {
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+func NewInventoryClient(baseURL string) *InventoryClient {
+	return &InventoryClient{
+		BaseURL: baseURL,
+		HTTPClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+}
+
+func (ic *InventoryClient) Reserve(payload ReservationRequest) (int, error) {
+	url := fmt.Sprintf("%s/api/inventory/reserve", ic.BaseURL)
+
+	bodyBytes, err := json.Marshal(payload)
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(bodyBytes))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := ic.HTTPClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,11 @@
+package client
+
+type ReservationRequest struct {
+	RequestId string                   `json:"requestId"`
+	Items     []ReservationItemRequest `json:"items"`
+}
+
+type ReservationItemRequest struct {
+	ProductId string `json:"productId"`
+	Quantity  int    `json:"quantity"`
+}
@@ -1,50 +1,149 @@
 package client
 
 import (
-	"bytes"
-	"encoding/json"
+	"common/auth"
+	"common/fault"
+	"common/signing"
+	"context"
 	"fmt"
+	"io"
 	"net/http"
+	"order-service/client/paymentapi"
 	"time"
 )
 
 type PaymentClientInterface interface {
-	Process(payload PaymentRequest) (int, error)
+	Process(claims *auth.Claims, payload paymentapi.PaymentRequest) (int, error)
+	GetAccount(claims *auth.Claims, accountId string) (*paymentapi.Account, int, error)
+	ListTransactions(from time.Time, to time.Time, cursor string) (paymentapi.TransactionPage, error)
+	GetTransactionsByRequestId(requestId string) ([]paymentapi.Transaction, error)
 }
 
+// PaymentClient calls the payment-service through the client generated from its spec.
 type PaymentClient struct {
-	BaseURL    string
-	HTTPClient *http.Client
+	api *paymentapi.ClientWithResponses
+	// signs the calls to internal payment routes, nil sends them unsigned
+	signer *signing.Signer
 }
 
-func NewPaymentClient(baseURL string) *PaymentClient {
+func NewPaymentClient(baseURL string, signer *signing.Signer) *PaymentClient {
 	return &PaymentClient{
-		BaseURL: baseURL,
-		HTTPClient: &http.Client{
-			Timeout: 10 * time.Second,
+		api: &paymentapi.ClientWithResponses{
+			ClientInterface: &paymentapi.Client{
+				Server: baseURL + "/",
+				Client: &http.Client{
+					Timeout: 10 * time.Second,
+				},
+			},
 		},
+		signer: signer,
 	}
 }
 
-func (wc *PaymentClient) Process(payload PaymentRequest) (int, error) {
-	url := fmt.Sprintf("%s/api/payment/process", wc.BaseURL)
+// Process charges the account on behalf of the caller of claims, nil claims send the request anonymously.
+func (wc *PaymentClient) Process(claims *auth.Claims, payload paymentapi.PaymentRequest) (int, error) {
+	return withProcessFault(func() (int, error) {
+		resp, err := wc.api.ProcessPayment(context.Background(), payload, forward(claims), wc.sign)
+		if err != nil {
+			return 0, err
+		}
+		resp.Body.Close()
+		return resp.StatusCode, nil
+	})
+}
 
-	bodyBytes, err := json.Marshal(payload)
+// GetAccount fetches an account as the caller of claims sees it, the account is only set with a 200 status.
+func (wc *PaymentClient) GetAccount(claims *auth.Claims, accountId string) (*paymentapi.Account, int, error) {
+	resp, err := wc.api.GetAccountWithResponse(context.Background(), accountId, forward(claims))
 	if err != nil {
-		return 0, err
+		return nil, 0, err
 	}
+	return resp.JSON200, resp.StatusCode(), nil
+}
 
-	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(bodyBytes))
+// ListTransactions fetches a page of the transactions of all accounts created in [from, to).
+func (wc *PaymentClient) ListTransactions(from time.Time, to time.Time, cursor string) (paymentapi.TransactionPage, error) {
+	from, to = from.UTC(), to.UTC()
+	limit := 100
+	params := &paymentapi.ListTransactionsParams{From: &from, To: &to, Limit: &limit}
+	if cursor != "" {
+		params.Cursor = &cursor
+	}
+
+	resp, err := wc.api.ListTransactionsWithResponse(context.Background(), params, wc.sign)
 	if err != nil {
-		return 0, err
+		return paymentapi.TransactionPage{}, err
+	}
+	if resp.JSON200 == nil {
+		return paymentapi.TransactionPage{}, fmt.Errorf("listing transactions failed with status %d", resp.StatusCode())
 	}
-	req.Header.Set("Content-Type", "application/json")
+	return *resp.JSON200, nil
+}
 
-	resp, err := wc.HTTPClient.Do(req)
+// GetTransactionsByRequestId fetches the charge and refunds of a request, none when the request was never charged.
+func (wc *PaymentClient) GetTransactionsByRequestId(requestId string) ([]paymentapi.Transaction, error) {
+	resp, err := wc.api.GetTransactionsByRequestIdWithResponse(context.Background(), requestId, wc.sign)
 	if err != nil {
-		return 0, err
+		return nil, err
+	}
+	if resp.StatusCode() == http.StatusNotFound {
+		return nil, nil
 	}
-	defer resp.Body.Close()
+	if resp.JSON200 == nil {
+		return nil, fmt.Errorf("fetching transactions failed with status %d", resp.StatusCode())
+	}
+	return *resp.JSON200, nil
+}
 
-	return resp.StatusCode, nil
+// sign is a request editor signing the request with its body.
+func (wc *PaymentClient) sign(ctx context.Context, req *http.Request) error {
+	if wc.signer == nil {
+		return nil
+	}
+	var body []byte
+	if req.GetBody != nil {
+		reader, err := req.GetBody()
+		if err != nil {
+			return err
+		}
+		defer reader.Close()
+		if body, err = io.ReadAll(reader); err != nil {
+			return err
+		}
+	}
+	return wc.signer.Sign(req, body)
+}
+
+// forward is a request editor passing the token of the caller on.
+func forward(claims *auth.Claims) paymentapi.RequestEditorFn {
+	return func(ctx context.Context, req *http.Request) error {
+		auth.Forward(req, claims)
+		return nil
+	}
+}
+
+// withProcessFault charges through process unless a fault armed for payment processing answers first.
+// A lost response lets the payment go through and fails the call afterwards, the caller never hears about it.
+func withProcessFault(process func() (int, error)) (int, error) {
+	injected := fault.Hit(fault.PaymentClientProcess)
+	if injected != nil {
+		switch injected.Kind {
+		case fault.KindStatus:
+			return injected.StatusCode(), nil
+		case fault.KindLostResponse:
+		default:
+			if err := injected.Err(); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	statusCode, err := process()
+	if err != nil {
+		return 0, err
+	}
+	if injected != nil && injected.Kind == fault.KindLostResponse {
+		return 0, injected.Err()
+	}
+	return statusCode, nil
 }
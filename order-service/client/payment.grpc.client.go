@@ -0,0 +1,193 @@
+package client
+
+import (
+	"common/auth"
+	"common/paymentpb"
+	"common/signing"
+	"context"
+	"fmt"
+	"net/http"
+	"order-service/client/paymentapi"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// transports the payment-service is called over
+const (
+	TransportHTTP = "http"
+	TransportGRPC = "grpc"
+)
+
+// statuses the order-service acts on for the codes the gRPC API answers with, so the saga does not depend on the
+// transport. Codes missing here leave the outcome of a call open, like a failed http request.
+var httpStatuses = map[codes.Code]int{
+	codes.OK:                 http.StatusOK,
+	codes.InvalidArgument:    http.StatusBadRequest,
+	codes.Unauthenticated:    http.StatusUnauthorized,
+	codes.FailedPrecondition: http.StatusPaymentRequired,
+	codes.PermissionDenied:   http.StatusForbidden,
+	codes.NotFound:           http.StatusNotFound,
+	codes.AlreadyExists:      http.StatusConflict,
+	codes.Aborted:            http.StatusServiceUnavailable,
+	codes.Internal:           http.StatusInternalServerError,
+}
+
+// PaymentGrpcClient calls the payment-service over its gRPC API. Every call gets a deadline and carries the token
+// of the caller and the order request id in its metadata.
+type PaymentGrpcClient struct {
+	api     paymentpb.PaymentServiceClient
+	timeout time.Duration
+}
+
+// NewPaymentGrpcClient calls the payment-service on conn, signer signs every call and nil sends them unsigned.
+func NewPaymentGrpcClient(conn grpc.ClientConnInterface, signer *signing.Signer, timeout time.Duration) *PaymentGrpcClient {
+	if signer != nil {
+		conn = signedConn{ClientConnInterface: conn, signer: signer}
+	}
+	return &PaymentGrpcClient{
+		api:     paymentpb.NewPaymentServiceClient(conn),
+		timeout: timeout,
+	}
+}
+
+// Process charges the account on behalf of the caller of claims, nil claims send the call anonymously.
+func (gc *PaymentGrpcClient) Process(claims *auth.Claims, payload paymentapi.PaymentRequest) (int, error) {
+	return withProcessFault(func() (int, error) {
+		ctx, cancel := gc.callContext(claims, payload.RequestId)
+		defer cancel()
+		paymentRequest := &paymentpb.ProcessPaymentRequest{
+			RequestId: payload.RequestId,
+			Uuid:      payload.Uuid,
+			OrderId:   payload.OrderId,
+			AccountId: payload.AccountId,
+			Amount:    payload.Amount,
+			Currency:  payload.Currency,
+		}
+		_, err := gc.api.ProcessPayment(ctx, paymentRequest)
+		return httpStatusOf(err)
+	})
+}
+
+// GetAccount fetches an account as the caller of claims sees it, the account is only set with a 200 status.
+func (gc *PaymentGrpcClient) GetAccount(claims *auth.Claims, accountId string) (*paymentapi.Account, int, error) {
+	ctx, cancel := gc.callContext(claims, "")
+	defer cancel()
+	account, err := gc.api.GetAccount(ctx, &paymentpb.GetAccountRequest{AccountId: accountId})
+	statusCode, err := httpStatusOf(err)
+	if statusCode != http.StatusOK {
+		return nil, statusCode, err
+	}
+	return &paymentapi.Account{
+		AccountId:  account.GetAccountId(),
+		CustomerId: account.GetCustomerId(),
+		Amount:     account.GetAmount(),
+		Currency:   account.GetCurrency(),
+		UpdateDate: account.GetUpdateDate().AsTime(),
+	}, statusCode, nil
+}
+
+// ListTransactions fetches a page of the transactions of all accounts created in [from, to).
+func (gc *PaymentGrpcClient) ListTransactions(from time.Time, to time.Time, cursor string) (paymentapi.TransactionPage, error) {
+	ctx, cancel := gc.callContext(nil, "")
+	defer cancel()
+	page, err := gc.api.ListTransactions(ctx, &paymentpb.ListTransactionsRequest{
+		From:   timestamppb.New(from),
+		To:     timestamppb.New(to),
+		Cursor: cursor,
+		Limit:  100,
+	})
+	if err != nil {
+		return paymentapi.TransactionPage{}, fmt.Errorf("listing transactions failed: %w", err)
+	}
+
+	transactions := paymentapi.TransactionPage{Items: []paymentapi.Transaction{}, NextCursor: page.GetNextCursor()}
+	for _, transaction := range page.GetItems() {
+		transactions.Items = append(transactions.Items, toTransaction(transaction))
+	}
+	return transactions, nil
+}
+
+// GetTransactionsByRequestId fetches the charge and refunds of a request, none when the request was never charged.
+func (gc *PaymentGrpcClient) GetTransactionsByRequestId(requestId string) ([]paymentapi.Transaction, error) {
+	ctx, cancel := gc.callContext(nil, requestId)
+	defer cancel()
+	payment, err := gc.api.GetPaymentByRequest(ctx, &paymentpb.GetPaymentByRequestRequest{RequestId: requestId})
+	if status.Code(err) == codes.NotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("fetching transactions failed: %w", err)
+	}
+
+	transactions := []paymentapi.Transaction{}
+	for _, transaction := range payment.GetTransactions() {
+		transactions = append(transactions, toTransaction(transaction))
+	}
+	return transactions, nil
+}
+
+// callContext bounds a call by the timeout and passes the token of claims and the request id on.
+func (gc *PaymentGrpcClient) callContext(claims *auth.Claims, requestId string) (context.Context, context.CancelFunc) {
+	ctx := context.Background()
+	if claims != nil {
+		ctx = metadata.AppendToOutgoingContext(ctx, paymentpb.MetadataAuthorization, "Bearer "+claims.Token)
+	}
+	if requestId != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, paymentpb.MetadataRequestId, requestId)
+	}
+	return context.WithTimeout(ctx, gc.timeout)
+}
+
+// httpStatusOf translates the outcome of a call, errors are only returned when it is open, e.g. past the deadline.
+func httpStatusOf(err error) (int, error) {
+	if err == nil {
+		return http.StatusOK, nil
+	}
+	callStatus, ok := status.FromError(err)
+	if !ok {
+		return 0, err
+	}
+	if statusCode, known := httpStatuses[callStatus.Code()]; known {
+		return statusCode, nil
+	}
+	return 0, err
+}
+
+func toTransaction(transaction *paymentpb.Transaction) paymentapi.Transaction {
+	transactionType := paymentapi.TransactionTypeCharge
+	if transaction.GetType() == paymentpb.TransactionType_TRANSACTION_TYPE_REFUND {
+		transactionType = paymentapi.TransactionTypeRefund
+	}
+	return paymentapi.Transaction{
+		TransactionId:    transaction.GetTransactionId(),
+		RequestId:        transaction.GetRequestId(),
+		AccountId:        transaction.GetAccountId(),
+		OrderId:          transaction.GetOrderId(),
+		Type:             transactionType,
+		Amount:           transaction.GetAmount(),
+		Currency:         transaction.GetCurrency(),
+		OriginalAmount:   transaction.GetOriginalAmount(),
+		OriginalCurrency: transaction.GetOriginalCurrency(),
+		FxRate:           transaction.GetFxRate(),
+		CreateDate:       transaction.GetCreateDate().AsTime(),
+	}
+}
+
+// signedConn signs every call made on the connection it wraps.
+type signedConn struct {
+	grpc.ClientConnInterface
+	signer *signing.Signer
+}
+
+func (c signedConn) Invoke(ctx context.Context, method string, args any, reply any, opts ...grpc.CallOption) error {
+	ctx, err := c.signer.SignCall(ctx, method, args)
+	if err != nil {
+		return err
+	}
+	return c.ClientConnInterface.Invoke(ctx, method, args, reply, opts...)
+}
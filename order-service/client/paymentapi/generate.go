@@ -0,0 +1,5 @@
+package paymentapi
+
+// The client of the payment-service is generated from its OpenAPI spec, so the calls of the order-service can not
+// drift from what the payment-service serves. Run go generate after changing payment-service/openapi/openapi.yaml.
+//go:generate go run github.com/oapi-codegen/oapi-codegen/v2/cmd/oapi-codegen@v2.4.1 -config config.yaml ../../../payment-service/openapi/openapi.yaml
@@ -1,6 +1,11 @@
 package configs
 
-import "github.com/spf13/viper"
+import (
+	"log"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+)
 
 type Config struct {
 	// database config
@@ -10,12 +15,34 @@ type Config struct {
 	DBName         string `mapstructure:"POSTGRES_NAME"`
 	DBPort         string `mapstructure:"POSTGRES_PORT"`
 
+	// applies pending migrations before the server starts, otherwise run the migrate subcommand
+	MigrateOnStartup bool `mapstructure:"MIGRATE_ON_STARTUP"`
+
 	// app config
 	ServerPort   string `mapstructure:"SERVER_PORT"`
 	ClientOrigin string `mapstructure:"CLIENT_ORIGIN"`
+	// ips or cidrs of the proxies trusted with X-Forwarded-For separated by commas, without any the client ip
+	// is the remote address
+	TrustedProxies string `mapstructure:"TRUSTED_PROXIES"`
+
+	// order processing config, in async mode orders are accepted right away and processed by workers
+	OrderProcessingMode  string `mapstructure:"ORDER_PROCESSING_MODE"`
+	OrderWorkerCount     int    `mapstructure:"ORDER_WORKER_COUNT"`
+	OrderWorkerQueueSize int    `mapstructure:"ORDER_WORKER_QUEUE_SIZE"`
+
+	// payment service config, the http transport calls the REST API at the base url, grpc the gRPC API at the address
+	// with a deadline per call
+	PaymentClientTransport    string `mapstructure:"PAYMENT_CLIENT_TRANSPORT"`
+	PaymentClientBaseUrl      string `mapstructure:"PAYMENT_CLIENT_BASE_URL"`
+	PaymentGrpcAddress        string `mapstructure:"PAYMENT_GRPC_ADDRESS"`
+	PaymentGrpcTimeoutSeconds int64  `mapstructure:"PAYMENT_GRPC_TIMEOUT_SECONDS"`
 
-	// payment service config
-	PaymentClientBaseUrl string `mapstructure:"PAYMENT_CLIENT_BASE_URL"`
+	// inventory service config
+	InventoryClientBaseUrl string `mapstructure:"INVENTORY_CLIENT_BASE_URL"`
+
+	// broker config, rabbitmq, nats or memory, the RMQ_ queue names are used on every broker
+	Broker  string `mapstructure:"BROKER"`
+	NatsUrl string `mapstructure:"NATS_URL"`
 
 	// rmq config
 	RMQUrl         string `mapstructure:"RMQ_URL"`
@@ -26,14 +53,64 @@ type Config struct {
 	RedisHost string `mapstructure:"REDIS_HOST"`
 	RedisPort string `mapstructure:"REDIS_PORT"`
 	RedisDb   string `mapstructure:"REDIS_DB"`
+	// how long finished idempotency records stay cached, postgres keeps them regardless
+	IdempotencyKeyTTLSeconds int64 `mapstructure:"IDEMPOTENCY_KEY_TTL_SECONDS"`
+	// pub/sub channel prefix for order status transitions
+	OrderEventsChannel string `mapstructure:"ORDER_EVENTS_CHANNEL"`
 
 	// orchestration
 	OrchestrationExpirationTimeSeconds int64  `mapstructure:"ORCHESTRATION_EXPIRATION_TIME_SECONDS"`
 	OrchestrationMapName               string `mapstructure:"ORCHESTRATION_MAP_NAME"`
+	// redis, postgres or memory, has to match the store of the orchestrator
+	OrchestrationStore string `mapstructure:"ORCHESTRATION_STORE"`
 	// produce when rollback starts
 	RMQExpiredEventQueue string `mapstructure:"RMQ_EXPIRED_EVENT_QUEUE"`
 	// consume and execute rollback of an order
 	RMQRollbackEventOrderQueue string `mapstructure:"RMQ_ROLLBACK_ORDER_EVENT_QUEUE"`
+
+	// reconciliation of orders against payment transactions, records younger than the grace period are left to the saga
+	ReconciliationEnabled        bool  `mapstructure:"RECONCILIATION_ENABLED"`
+	ReconciliationPeriodSeconds  int64 `mapstructure:"RECONCILIATION_PERIOD_SECONDS"`
+	ReconciliationWindowSeconds  int64 `mapstructure:"RECONCILIATION_WINDOW_SECONDS"`
+	ReconciliationGraceSeconds   int64 `mapstructure:"RECONCILIATION_GRACE_SECONDS"`
+	ReconciliationAutoCompensate bool  `mapstructure:"RECONCILIATION_AUTO_COMPENSATE"`
+
+	// jwt auth of customers, HS256 verifies with the secret in the key file, RS256 with the PEM public key in it
+	// or the keys of the JWKS file, issuer and audience are checked when set
+	AuthEnabled   bool   `mapstructure:"AUTH_ENABLED"`
+	AuthAlgorithm string `mapstructure:"AUTH_ALGORITHM"`
+	AuthKeyFile   string `mapstructure:"AUTH_KEY_FILE"`
+	AuthJWKSFile  string `mapstructure:"AUTH_JWKS_FILE"`
+	AuthIssuer    string `mapstructure:"AUTH_ISSUER"`
+	AuthAudience  string `mapstructure:"AUTH_AUDIENCE"`
+
+	// key the calls to the payment-service are signed with, it has to be among the SERVICE_SIGNING_KEYS of the payment-service
+	ServiceSigningKeyId  string `mapstructure:"SERVICE_SIGNING_KEY_ID"`
+	ServiceSigningSecret string `mapstructure:"SERVICE_SIGNING_SECRET"`
+
+	// key the expired events are signed with, the orchestrator has to accept it
+	MessageSigningKeyId  string `mapstructure:"MESSAGE_SIGNING_KEY_ID"`
+	MessageSigningSecret string `mapstructure:"MESSAGE_SIGNING_SECRET"`
+	// rollback messages have to be signed with one of these keyId:secret pairs separated by commas,
+	// unsigned and forged ones are moved to the quarantine queue
+	MessageSigningKeys string `mapstructure:"MESSAGE_SIGNING_KEYS"`
+	RMQQuarantineQueue string `mapstructure:"RMQ_QUARANTINE_QUEUE"`
+	// rollbacks failing twice are parked here to be replayed with orchestrationctl
+	RMQDeadLetterQueue string `mapstructure:"RMQ_DEAD_LETTER_QUEUE"`
+
+	// rate limits of order creation within a sliding window, a limit of zero leaves its scope unlimited,
+	// changes to app.env are applied without a restart
+	RateLimitEnabled       bool  `mapstructure:"RATE_LIMIT_ENABLED"`
+	RateLimitWindowSeconds int64 `mapstructure:"RATE_LIMIT_WINDOW_SECONDS"`
+	RateLimitPerAccount    int   `mapstructure:"RATE_LIMIT_PER_ACCOUNT"`
+	RateLimitPerIP         int   `mapstructure:"RATE_LIMIT_PER_IP"`
+	RateLimitGlobal        int   `mapstructure:"RATE_LIMIT_GLOBAL"`
+
+	// responses checked against the OpenAPI spec: off, log or strict, which answers 500 instead of a broken response
+	OpenAPIResponseValidation string `mapstructure:"OPENAPI_RESPONSE_VALIDATION"`
+
+	// faults armed at startup, point=kind[:arg][*count] separated by commas, only honoured by -tags faults builds
+	Faults string `mapstructure:"FAULTS"`
 }
 
 func LoadConfig(path string) (config Config, err error) {
@@ -51,3 +128,16 @@ func LoadConfig(path string) (config Config, err error) {
 	err = viper.Unmarshal(&config)
 	return
 }
+
+// WatchConfig calls onChange with the config reloaded after app.env changed, a config that fails to load is skipped.
+func WatchConfig(onChange func(config Config)) {
+	viper.OnConfigChange(func(event fsnotify.Event) {
+		var config Config
+		if err := viper.Unmarshal(&config); err != nil {
+			log.Printf("Failed to reload config from %s: %v", event.Name, err)
+			return
+		}
+		onChange(config)
+	})
+	viper.WatchConfig()
+}
@@ -0,0 +1,13 @@
+package request
+
+import "time"
+
+type OrderListRequest struct {
+	AccountId string     `form:"accountId" validate:"omitempty,uuid"`
+	ProductId string     `form:"productId" validate:"omitempty,uuid"`
+	Status    string     `form:"status" validate:"omitempty,oneof=PENDING PAYMENT_ACCEPTED CONFIRMED ROLLING_BACK COMPENSATED"`
+	From      *time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To        *time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	Cursor    string     `form:"cursor" validate:"max=512"`
+	Limit     int        `form:"limit" validate:"gte=0"`
+}
@@ -1,7 +1,12 @@
 package request
 
 type OrderRequest struct {
-	ProductId string `json:"productId"  binding:"required"`
-	RequestId string `json:"requestId" binding:"required"`
-	AccountID string `json:"accountId" binding:"required"`
+	Items     []OrderItemRequest `json:"items" validate:"required,min=1,max=50,dive"`
+	RequestId string             `json:"requestId" validate:"required,uuid"`
+	AccountID string             `json:"accountId" validate:"required,uuid"`
+}
+
+type OrderItemRequest struct {
+	ProductId string `json:"productId" validate:"required,uuid"`
+	Quantity  int    `json:"quantity" validate:"gt=0,lte=1000"`
 }
@@ -0,0 +1,9 @@
+package request
+
+type FindingListRequest struct {
+	Status    string `form:"status" validate:"omitempty,oneof=OPEN RESOLVED"`
+	Kind      string `form:"kind" validate:"omitempty,oneof=ORPHANED_CHARGE MISSING_CHARGE REFUNDED_CONFIRMED_ORDER AMOUNT_MISMATCH"`
+	RequestId string `form:"requestId" validate:"omitempty,uuid"`
+	Cursor    string `form:"cursor" validate:"max=512"`
+	Limit     int    `form:"limit" validate:"gte=0"`
+}
@@ -4,7 +4,30 @@ import "time"
 
 type OrderResponse struct {
 	ProductOrderId string
+	RequestId      string
+	Status         string
 	CreateDate     time.Time
-	ProductId      string
 	AccountId      string
+	TotalAmount    float64
+	Currency       string
+	Items          []OrderItemResponse
+}
+
+type OrderItemResponse struct {
+	ProductId string
+	Quantity  int
+	UnitPrice float64
+	LineTotal float64
+}
+
+type OrderStatusResponse struct {
+	ProductOrderId string
+	RequestId      string
+	Status         string
+	UpdateDate     time.Time
+}
+
+type OrderPageResponse struct {
+	Items      []OrderResponse
+	NextCursor string
 }
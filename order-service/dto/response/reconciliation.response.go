@@ -0,0 +1,36 @@
+package response
+
+import "time"
+
+type FindingResponse struct {
+	FindingId             string
+	RequestId             string
+	Kind                  string
+	Status                string
+	OrderStatus           string
+	OrderAmount           float64
+	ChargedAmount         float64
+	Currency              string
+	Detail                string
+	CompensationTriggered bool
+	FirstSeenDate         time.Time
+	LastSeenDate          time.Time
+	ResolvedDate          *time.Time
+}
+
+type FindingPageResponse struct {
+	Items      []FindingResponse
+	NextCursor string
+}
+
+// ReconciliationRunResponse summarizes a single reconciliation run.
+type ReconciliationRunResponse struct {
+	From          time.Time
+	To            time.Time
+	Orders        int
+	Transactions  int
+	Checked       int
+	Findings      []FindingResponse
+	Compensations int
+	Resolved      int
+}
@@ -0,0 +1,28 @@
+package event
+
+import (
+	"order-service/model"
+	"time"
+)
+
+// OrderEvent is published on every status transition of an order.
+type OrderEvent struct {
+	ProductOrderId string    `json:"productOrderId"`
+	RequestId      string    `json:"requestId"`
+	Status         string    `json:"status"`
+	Timestamp      time.Time `json:"timestamp"`
+}
+
+func NewOrderEvent(order model.ProductOrder) OrderEvent {
+	return OrderEvent{
+		ProductOrderId: order.ProductOrderId,
+		RequestId:      order.RequestId,
+		Status:         order.Status,
+		Timestamp:      time.Now().UTC(),
+	}
+}
+
+// IsTerminal reports whether no further transitions follow the event.
+func (e OrderEvent) IsTerminal() bool {
+	return e.Status == model.OrderStatusConfirmed || e.Status == model.OrderStatusCompensated
+}
@@ -0,0 +1,91 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"order-service/configs"
+	"order-service/model"
+
+	"github.com/go-redis/redis/v8"
+)
+
+type PublisherInterface interface {
+	Publish(order model.ProductOrder)
+	Subscribe(ctx context.Context, productOrderId string) (*Subscription, error)
+}
+
+// RedisPublisher fans order events out over redis pub/sub, so any replica can stream them to clients.
+type RedisPublisher struct {
+	redisClient *redis.Client
+	config      *configs.Config
+}
+
+type Subscription struct {
+	pubSub *redis.PubSub
+	events chan OrderEvent
+}
+
+func NewRedisPublisher(redisClient *redis.Client, config *configs.Config) *RedisPublisher {
+	return &RedisPublisher{
+		redisClient: redisClient,
+		config:      config,
+	}
+}
+
+// Publish is best effort, a lost event only delays clients until they poll the order status.
+func (p *RedisPublisher) Publish(order model.ProductOrder) {
+	data, err := json.Marshal(NewOrderEvent(order))
+	if err != nil {
+		log.Printf("Failed to marshal event of order %s: %v", order.ProductOrderId, err)
+		return
+	}
+	err = p.redisClient.Publish(context.Background(), p.channel(order.ProductOrderId), data).Err()
+	if err != nil {
+		log.Printf("Failed to publish event of order %s: %v", order.ProductOrderId, err)
+	}
+}
+
+// Subscribe returns once the subscription is confirmed by redis, so no event published afterwards is missed.
+func (p *RedisPublisher) Subscribe(ctx context.Context, productOrderId string) (*Subscription, error) {
+	pubSub := p.redisClient.Subscribe(ctx, p.channel(productOrderId))
+	if _, err := pubSub.Receive(ctx); err != nil {
+		pubSub.Close()
+		return nil, err
+	}
+
+	subscription := &Subscription{
+		pubSub: pubSub,
+		events: make(chan OrderEvent),
+	}
+	go subscription.forward(ctx)
+	return subscription, nil
+}
+
+func (p *RedisPublisher) channel(productOrderId string) string {
+	return p.config.OrderEventsChannel + ":" + productOrderId
+}
+
+func (s *Subscription) Events() <-chan OrderEvent {
+	return s.events
+}
+
+func (s *Subscription) Close() error {
+	return s.pubSub.Close()
+}
+
+func (s *Subscription) forward(ctx context.Context) {
+	defer close(s.events)
+	for msg := range s.pubSub.Channel() {
+		var orderEvent OrderEvent
+		if err := json.Unmarshal([]byte(msg.Payload), &orderEvent); err != nil {
+			log.Printf("Failed to unmarshal order event: %v", err)
+			continue
+		}
+		select {
+		case s.events <- orderEvent:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
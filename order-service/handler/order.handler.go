@@ -1,65 +1,219 @@
 package handler
 
 import (
+	"common/auth"
+	"common/pagination"
+	"common/validation"
+	"errors"
+	"io"
 	"net/http"
 	"order-service/configs"
 	"order-service/dto/request"
+	"order-service/dto/response"
+	"order-service/event"
+	"order-service/model"
 	"order-service/service"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
+// keeps proxies from closing idle event streams
+const sseHeartbeatInterval = 15 * time.Second
+
 type OrderHandler struct {
 	postgresDB   *gorm.DB
 	orderService *service.OrderService
+	orderEvents  *event.RedisPublisher
 	config       *configs.Config
 }
 
 type OrderHandlerInterface interface {
 	MakeOrder(ctx *gin.Context)
+	GetOrder(ctx *gin.Context)
+	GetOrderStatus(ctx *gin.Context)
+	StreamOrderEvents(ctx *gin.Context)
+	GetOrderByRequestId(ctx *gin.Context)
+	ListOrders(ctx *gin.Context)
 }
 
-func NewOrderHandler(postgresDB *gorm.DB, orderService *service.OrderService, config *configs.Config) OrderHandler {
+func NewOrderHandler(postgresDB *gorm.DB, orderService *service.OrderService, orderEvents *event.RedisPublisher, config *configs.Config) OrderHandler {
 	return OrderHandler{
 		postgresDB:   postgresDB,
 		orderService: orderService,
+		orderEvents:  orderEvents,
 		config:       config,
 	}
 }
 
 func (orderHandler OrderHandler) MakeOrder(ctx *gin.Context) {
 	var orderRequest request.OrderRequest
-	if err := ctx.ShouldBindJSON(&orderRequest); err != nil {
+	if !validation.BindJSON(ctx, &orderRequest) {
+		return
+	}
+
+	response, err := orderHandler.orderService.Create(auth.ClaimsOf(ctx), orderRequest)
+	if errors.Is(err, service.ErrAccountNotOwned) {
+		ctx.JSON(http.StatusForbidden, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	if errors.Is(err, service.ErrAccountNotFound) || errors.Is(err, service.ErrProductNotFound) || errors.Is(err, service.ErrMixedCurrencies) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	if errors.Is(err, service.ErrOutOfStock) {
+		ctx.JSON(http.StatusConflict, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	if errors.Is(err, service.ErrPaymentDeclined) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	if errors.Is(err, service.ErrTooManyOrders) {
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	if errors.Is(err, service.ErrRequestInProgress) {
+		ctx.JSON(http.StatusConflict, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	if errors.Is(err, service.ErrIdempotencyKeyMismatch) {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+
+	if response.ProductOrderId == "" {
 		ctx.JSON(http.StatusBadRequest, gin.H{})
 		return
 	}
 
-	if orderRequest.RequestId == "" {
-		ctx.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": "wrong orderRequest params, missing request id"})
+	// accepted in async mode, the saga goes on in the background
+	if response.Status == model.OrderStatusPending {
+		ctx.JSON(http.StatusAccepted, response)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+func (orderHandler OrderHandler) GetOrder(ctx *gin.Context) {
+	response, err := orderHandler.orderService.Get(auth.ClaimsOf(ctx), ctx.Param("id"))
+	orderHandler.renderOrder(ctx, response, err)
+}
+
+func (orderHandler OrderHandler) GetOrderStatus(ctx *gin.Context) {
+	status, err := orderHandler.orderService.GetStatus(auth.ClaimsOf(ctx), ctx.Param("id"))
+	if errors.Is(err, service.ErrOrderNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, status)
+}
+
+// StreamOrderEvents sends the current status of an order followed by every transition as server-sent events.
+// The stream ends once the order reaches a terminal status or the client disconnects.
+func (orderHandler OrderHandler) StreamOrderEvents(ctx *gin.Context) {
+	requestCtx := ctx.Request.Context()
+	// subscribe before reading the status, otherwise a transition in between would be lost
+	subscription, err := orderHandler.orderEvents.Subscribe(requestCtx, ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{})
 		return
 	}
+	defer subscription.Close()
 
-	if orderRequest.ProductId == "" {
-		ctx.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": "wrong orderRequest params, missing product"})
+	status, err := orderHandler.orderService.GetStatus(auth.ClaimsOf(ctx), ctx.Param("id"))
+	if errors.Is(err, service.ErrOrderNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{})
 		return
 	}
 
-	if orderRequest.AccountID == "" {
-		ctx.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": "wrong orderRequest params, missing account"})
+	current := event.OrderEvent{
+		ProductOrderId: status.ProductOrderId,
+		RequestId:      status.RequestId,
+		Status:         status.Status,
+		Timestamp:      status.UpdateDate,
+	}
+	ctx.SSEvent("status", current)
+	ctx.Writer.Flush()
+	if current.IsTerminal() {
 		return
 	}
 
-	response, err := orderHandler.orderService.Create(orderRequest)
+	heartbeat := time.NewTicker(sseHeartbeatInterval)
+	defer heartbeat.Stop()
+	ctx.Stream(func(w io.Writer) bool {
+		select {
+		case orderEvent, ok := <-subscription.Events():
+			if !ok {
+				return false
+			}
+			ctx.SSEvent("status", orderEvent)
+			return !orderEvent.IsTerminal()
+		case <-heartbeat.C:
+			ctx.SSEvent("heartbeat", time.Now().UTC())
+			return true
+		case <-requestCtx.Done():
+			return false
+		}
+	})
+}
+
+func (orderHandler OrderHandler) GetOrderByRequestId(ctx *gin.Context) {
+	response, err := orderHandler.orderService.GetByRequestId(auth.ClaimsOf(ctx), ctx.Param("requestId"))
+	orderHandler.renderOrder(ctx, response, err)
+}
+
+func (orderHandler OrderHandler) ListOrders(ctx *gin.Context) {
+	var listRequest request.OrderListRequest
+	if !validation.BindQuery(ctx, &listRequest) {
+		return
+	}
+
+	page, err := orderHandler.orderService.List(auth.ClaimsOf(ctx), listRequest)
+	if errors.Is(err, service.ErrAccountNotOwned) {
+		ctx.JSON(http.StatusForbidden, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	if errors.Is(err, service.ErrAccountNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	if errors.Is(err, pagination.ErrInvalidCursor) || errors.Is(err, service.ErrAccountIdRequired) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{})
 		return
 	}
 
-	if response.ProductOrderId == "" {
-		ctx.JSON(http.StatusBadRequest, gin.H{})
+	ctx.JSON(http.StatusOK, page)
+}
+
+func (orderHandler OrderHandler) renderOrder(ctx *gin.Context, orderResponse response.OrderResponse, err error) {
+	if errors.Is(err, service.ErrOrderNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{})
 		return
 	}
 
-	ctx.JSON(http.StatusOK, response)
+	ctx.JSON(http.StatusOK, orderResponse)
 }
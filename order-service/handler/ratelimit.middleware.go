@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"bytes"
+	"common/auth"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"order-service/ratelimit"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const rateLimitKey = "ratelimit.result"
+
+// RateLimit counts order requests per client ip and globally and answers 429 once either is at its limit. It runs
+// before auth, so it only counts what an anonymous caller can not forge. While redis is unreachable requests are let
+// through rather than turning the limiter into an outage.
+func RateLimit(limiter ratelimit.LimiterInterface) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		limits := limiter.Limits()
+		if !limits.Enabled {
+			ctx.Next()
+			return
+		}
+		allow(ctx, limiter, []ratelimit.Counter{
+			{Scope: ratelimit.ScopeGlobal, Key: "orders", Limit: limits.Global},
+			{Scope: ratelimit.ScopeIP, Key: ctx.ClientIP(), Limit: limits.PerIP},
+		})
+	}
+}
+
+// RateLimitAccount counts order requests per account and answers 429 once it is at its limit. It runs after
+// Authenticate and keys the count on the verified subject, so nobody can use up the quota of another customer.
+// While auth is disabled the account is read from the body, which is put back for the handler.
+func RateLimitAccount(limiter ratelimit.LimiterInterface) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		limits := limiter.Limits()
+		if !limits.Enabled {
+			ctx.Next()
+			return
+		}
+
+		key := ""
+		if claims := auth.ClaimsOf(ctx); claims != nil {
+			key = "customer:" + claims.Subject
+		} else {
+			body, err := io.ReadAll(ctx.Request.Body)
+			if err != nil {
+				ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{})
+				return
+			}
+			ctx.Request.Body = io.NopCloser(bytes.NewReader(body))
+			var order struct {
+				AccountID string `json:"accountId"`
+			}
+			// malformed bodies are left to the handler to reject, they are still counted per ip and globally
+			if json.Unmarshal(body, &order) == nil && order.AccountID != "" {
+				key = order.AccountID
+			}
+		}
+		if key == "" {
+			ctx.Next()
+			return
+		}
+		allow(ctx, limiter, []ratelimit.Counter{{Scope: ratelimit.ScopeAccount, Key: key, Limit: limits.PerAccount}})
+	}
+}
+
+// allow counts the request in counters and answers 429 when one of them is at its limit. The RateLimit headers
+// describe the scope closest to its limit over every check the request went through.
+func allow(ctx *gin.Context, limiter ratelimit.LimiterInterface, counters []ratelimit.Counter) {
+	result, err := limiter.Allow(ctx.Request.Context(), counters)
+	if err != nil {
+		log.Printf("Failed to check rate limit, letting the request through: %v", err)
+		ctx.Next()
+		return
+	}
+	if result.Limit > 0 {
+		if previous, checked := ctx.Get(rateLimitKey); !result.Allowed || !checked || result.Remaining < previous.(ratelimit.Result).Remaining {
+			ctx.Set(rateLimitKey, result)
+			ctx.Header("RateLimit-Limit", strconv.Itoa(result.Limit))
+			ctx.Header("RateLimit-Remaining", strconv.Itoa(max(result.Remaining, 0)))
+			ctx.Header("RateLimit-Reset", ratelimit.Seconds(result.Reset))
+		}
+	}
+	if !result.Allowed {
+		ctx.Header("Retry-After", ratelimit.Seconds(result.Reset))
+		ctx.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"status": "error", "message": "too many orders per " + result.Scope + ", retry later"})
+		return
+	}
+	ctx.Next()
+}
@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"common/pagination"
+	"common/validation"
+	"errors"
+	"net/http"
+	"order-service/dto/request"
+	"order-service/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ReconciliationHandler struct {
+	reconciliationService *service.ReconciliationService
+}
+
+type ReconciliationHandlerInterface interface {
+	ListFindings(ctx *gin.Context)
+	GetFinding(ctx *gin.Context)
+	RunReconciliation(ctx *gin.Context)
+}
+
+func NewReconciliationHandler(reconciliationService *service.ReconciliationService) ReconciliationHandler {
+	return ReconciliationHandler{
+		reconciliationService: reconciliationService,
+	}
+}
+
+func (reconciliationHandler ReconciliationHandler) ListFindings(ctx *gin.Context) {
+	var listRequest request.FindingListRequest
+	if !validation.BindQuery(ctx, &listRequest) {
+		return
+	}
+
+	page, err := reconciliationHandler.reconciliationService.List(listRequest)
+	if errors.Is(err, pagination.ErrInvalidCursor) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, page)
+}
+
+func (reconciliationHandler ReconciliationHandler) GetFinding(ctx *gin.Context) {
+	finding, err := reconciliationHandler.reconciliationService.Get(ctx.Param("id"))
+	if errors.Is(err, service.ErrFindingNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, finding)
+}
+
+// RunReconciliation runs the reconciliation right away instead of waiting for the schedule.
+func (reconciliationHandler ReconciliationHandler) RunReconciliation(ctx *gin.Context) {
+	result, err := reconciliationHandler.reconciliationService.Run(ctx.Request.Context())
+	if err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, result)
+}
@@ -1,90 +1,155 @@
 package main
 
 import (
+	"common/apispec"
+	"common/auth"
+	"common/broker"
+	"common/fault"
+	commonMigration "common/migration"
+	"common/signing"
+	"common/statestore"
 	"context"
-	"github.com/gin-contrib/cors"
-	"github.com/gin-gonic/gin"
 	"github.com/go-redis/redis/v8"
-	"github.com/streadway/amqp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
 	"gorm.io/gorm"
 	"log"
+	"order-service/app"
 	"order-service/client"
 	"order-service/configs"
-	"order-service/handler"
-	"order-service/orchestration"
-	"order-service/repository"
-	"order-service/route"
-	"order-service/service"
+	"order-service/migration"
+	"order-service/openapi"
+	"os"
 	"strconv"
 )
 
-var (
-	server               *gin.Engine
-	OrderController      handler.OrderHandler
-	OrderRouteController route.OrderRouteHandler
-)
-
 func main() {
 	cfg, err := configs.LoadConfig(".")
 	if err != nil {
 		panic("Could not initialize app")
 	}
+	if err := fault.Configure(cfg.Faults); err != nil {
+		log.Fatalf("Failed to arm faults: %v", err)
+	}
 
 	postgresDB, err := configs.ConnectToDB(&cfg)
 	if err != nil {
 		panic("Failed to connect to DB")
 	}
 
-	paymentClient := client.NewPaymentClient(cfg.PaymentClientBaseUrl)
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrateCommand(postgresDB, os.Args[2:]); err != nil {
+			log.Fatalf("Migrate failed: %v", err)
+		}
+		return
+	}
+	if cfg.MigrateOnStartup {
+		if err := runMigrateCommand(postgresDB, []string{"up"}); err != nil {
+			log.Fatalf("Failed to migrate DB: %v", err)
+		}
+	}
+
 	redisDatabase := initializeRedisCache(cfg)
+	messageBroker := initializeBroker(cfg)
+	defer messageBroker.Close()
 
-	rmqConn, rmqChannel := initializeRabbitMQ(cfg)
-	defer rmqConn.Close()
-	rmqProducer := orchestration.NewRMQProducer(&cfg, rmqChannel)
-	orchestrationManager := orchestration.NewOrchestrationManager(redisDatabase, rmqProducer, &cfg)
+	messageSigner, messageVerifier, err := signing.NewMessageSigning(cfg.MessageSigningKeyId, cfg.MessageSigningSecret, cfg.MessageSigningKeys)
+	if err != nil {
+		log.Fatalf("Failed to load message signing keys: %v", err)
+	}
+	if messageVerifier == nil {
+		log.Println("No message signing keys configured, rollback messages are not verified")
+	}
 
-	orderRepository := repository.NewOrderRepository()
-	productRepository := repository.NewProductRepository()
+	paymentConn := initializePaymentConn(cfg)
+	if paymentConn != nil {
+		defer paymentConn.Close()
+	}
 
-	redisService := service.NewRedisService(redisDatabase)
-	orderService := service.NewOrderService(&cfg, postgresDB, orderRepository, redisService, paymentClient, productRepository, orchestrationManager)
+	orderApp := app.New(&cfg, app.Dependencies{
+		DB:     postgresDB,
+		Redis:  redisDatabase,
+		Broker: messageBroker,
+		Store:  initializeOrchestrationStore(cfg, redisDatabase, postgresDB),
+		// the payment-service checks the forwarded tokens with the same keys
+		Verifier:        initializeVerifier(cfg),
+		MessageSigner:   messageSigner,
+		MessageVerifier: messageVerifier,
+		Spec:            initializeSpec(cfg),
+		PaymentConn:     paymentConn,
+	})
 
-	OrderController = handler.NewOrderHandler(postgresDB, orderService, &cfg)
-	OrderRouteController = route.NewOrderRouteHandler(OrderController)
+	configs.WatchConfig(func(reloaded configs.Config) {
+		orderApp.ReloadRateLimits(&reloaded)
+		log.Printf("Rate limits reloaded: enabled %t, per account %d, per ip %d, global %d per %d seconds",
+			reloaded.RateLimitEnabled, reloaded.RateLimitPerAccount, reloaded.RateLimitPerIP, reloaded.RateLimitGlobal, reloaded.RateLimitWindowSeconds)
+	})
 
-	// Initialize rollback consumer on a separate channel
-	cancelRollbackConsumer := initializeRollbackConsumer(postgresDB, cfg, rmqConn, orderRepository)
-	defer cancelRollbackConsumer()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := orderApp.Start(ctx); err != nil {
+		log.Fatalf("Failed to start order-service: %v", err)
+	}
 
-	server = gin.Default()
-	corsConfig := cors.DefaultConfig()
-	corsConfig.AllowOrigins = []string{cfg.ClientOrigin}
-	corsConfig.AllowCredentials = true
-	server.Use(cors.New(corsConfig))
+	log.Fatal(orderApp.Router.Run(":" + cfg.ServerPort))
+}
 
-	router := server.Group("/api")
-	OrderRouteController.OrderRoute(router)
+func initializeOrchestrationStore(cfg configs.Config, redisDatabase *redis.Client, postgresDB *gorm.DB) statestore.StateStore {
+	switch cfg.OrchestrationStore {
+	case statestore.BackendRedis:
+		return statestore.NewRedisStore(redisDatabase, cfg.OrchestrationMapName)
+	case statestore.BackendPostgres:
+		return statestore.NewPostgresStore(postgresDB)
+	case statestore.BackendMemory:
+		log.Println("In-memory orchestration store is not visible to the orchestrator, use it for tests only")
+		return statestore.NewMemoryStore()
+	}
+	log.Fatalf("Unknown orchestration store: %s", cfg.OrchestrationStore)
+	return nil
+}
 
-	log.Fatal(server.Run(":" + cfg.ServerPort))
+// initializePaymentConn connects to the gRPC API of the payment-service, nil when it is called over http.
+// The connection is plaintext like the REST calls, the signatures keep the calls from being changed or replayed.
+func initializePaymentConn(cfg configs.Config) *grpc.ClientConn {
+	switch cfg.PaymentClientTransport {
+	case client.TransportHTTP:
+		return nil
+	case client.TransportGRPC:
+		conn, err := grpc.NewClient(cfg.PaymentGrpcAddress, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			log.Fatalf("Failed to connect to payment-service gRPC API: %v", err)
+		}
+		log.Printf("Calling payment-service over gRPC at %s", cfg.PaymentGrpcAddress)
+		return conn
+	}
+	log.Fatalf("Unknown payment client transport: %s", cfg.PaymentClientTransport)
+	return nil
 }
 
-func initializeRollbackConsumer(postgresDB *gorm.DB, cfg configs.Config, conn *amqp.Connection, orderRepository *repository.OrderRepository) context.CancelFunc {
-	rollbackChannel, err := conn.Channel()
+func initializeSpec(cfg configs.Config) *apispec.Spec {
+	spec, err := apispec.Load(openapi.Spec, cfg.OpenAPIResponseValidation)
 	if err != nil {
-		log.Fatalf("Failed to open channel for rollback consumer: %v", err)
+		log.Fatalf("Failed to load OpenAPI spec: %v", err)
 	}
-	rollbackConsumer := orchestration.NewRollbackConsumer(postgresDB, &cfg, rollbackChannel, orderRepository)
-	consumerCtx, consumerCancel := context.WithCancel(context.Background())
+	return spec
+}
 
-	if err := rollbackConsumer.Consume(consumerCtx); err != nil {
-		log.Fatalf("Failed to start rollback consumer: %v", err)
+func initializeVerifier(cfg configs.Config) *auth.Verifier {
+	if !cfg.AuthEnabled {
+		log.Println("Auth disabled, orders are accepted for any account")
+		return nil
 	}
-	log.Println("Rollback consumer started")
-
-	return func() {
-		consumerCancel()
-		rollbackChannel.Close()
+	verifier, err := auth.NewVerifier(auth.Config{
+		Algorithm: cfg.AuthAlgorithm,
+		KeyFile:   cfg.AuthKeyFile,
+		JWKSFile:  cfg.AuthJWKSFile,
+		Issuer:    cfg.AuthIssuer,
+		Audience:  cfg.AuthAudience,
+	})
+	if err != nil {
+		log.Fatalf("Failed to load jwt keys: %v", err)
 	}
+	return verifier
 }
 
 func initializeRedisCache(cfg configs.Config) *redis.Client {
@@ -101,38 +166,40 @@ func initializeRedisCache(cfg configs.Config) *redis.Client {
 	ctx := context.Background()
 	pong, err := redisDatabase.Ping(ctx).Result()
 	if err != nil {
-		log.Fatalf("Failed to ping Redis: %v", err)
+		// only the redis store can not do without it, the idempotency cache, the order events and the rate limiter
+		// degrade while redis is down and the client reconnects once it is back
+		if cfg.OrchestrationStore == statestore.BackendRedis {
+			log.Fatalf("Failed to ping Redis: %v", err)
+		}
+		log.Printf("Failed to ping Redis, running without the cache until it is reachable: %v", err)
+		return redisDatabase
 	}
 	log.Println("Redis ping response:", pong)
 
 	return redisDatabase
 }
 
-func initializeRabbitMQ(cfg configs.Config) (*amqp.Connection, *amqp.Channel) {
-	conn, err := amqp.Dial(cfg.RMQUrl)
+func initializeBroker(cfg configs.Config) broker.Broker {
+	messageBroker, err := broker.New(broker.Config{
+		Backend:     cfg.Broker,
+		RabbitMQUrl: cfg.RMQUrl,
+		NatsUrl:     cfg.NatsUrl,
+	})
 	if err != nil {
-		log.Fatalf("Failed to connect to RabbitMQ: %v", err)
+		log.Fatalf("Failed to connect to %s broker: %v", cfg.Broker, err)
 	}
 
-	ch, err := conn.Channel()
-	if err != nil {
-		conn.Close()
-		log.Fatalf("Failed to open a RabbitMQ channel: %v", err)
-	}
-
-	_, err = ch.QueueDeclare(
-		cfg.RMQExpiredEventQueue,
-		true,
-		false,
-		false,
-		false,
-		nil,
-	)
-	if err != nil {
-		ch.Close()
-		conn.Close()
-		log.Fatalf("Failed to declare RabbitMQ queue: %v", err)
+	if err := messageBroker.Declare(cfg.RMQExpiredEventQueue, cfg.RMQRollbackEventOrderQueue, cfg.RMQQuarantineQueue, cfg.RMQDeadLetterQueue); err != nil {
+		messageBroker.Close()
+		log.Fatalf("Failed to declare queues: %v", err)
 	}
+	return messageBroker
+}
 
-	return conn, ch
+func runMigrateCommand(postgresDB *gorm.DB, args []string) error {
+	migrator, err := migration.NewMigrator(postgresDB)
+	if err != nil {
+		return err
+	}
+	return commonMigration.RunCommand(migrator, args, os.Stdout)
 }
@@ -0,0 +1,4 @@
+drop table if exists product_order_items;
+drop table if exists order_idempotency_keys;
+drop table if exists product_orders;
+drop table if exists products;
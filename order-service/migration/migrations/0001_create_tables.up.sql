@@ -0,0 +1,83 @@
+-- baseline of the schema previously kept in docker/schema.sql, databases created from it are adopted below
+create table if not exists products
+(
+    product_id varchar(512) not null,
+    name varchar(128),
+    price numeric,
+    currency varchar(3) not null default 'EUR',
+    create_date date,
+    update_date date
+);
+
+create table if not exists product_orders
+(
+    product_order_id varchar(512) not null,
+    account_id varchar(512) not null,
+    status varchar(32) not null default 'PENDING',
+    total_amount numeric not null,
+    currency varchar(3) not null,
+    create_date timestamp,
+    update_date timestamp,
+    request_id varchar(512) not null
+);
+
+-- tables created from docker/schema.sql lack the columns added since, orders were only kept once they were charged
+alter table products add column if not exists currency varchar(3) not null default 'EUR';
+alter table product_orders
+    add column if not exists status varchar(32) not null default 'CONFIRMED',
+    add column if not exists total_amount numeric,
+    add column if not exists currency varchar(3) not null default 'EUR',
+    add column if not exists update_date timestamp,
+    alter column create_date type timestamp;
+alter table product_orders alter column status set default 'PENDING', alter column currency drop default;
+
+-- keyset pagination on (create_date, product_order_id) for the order list filters
+create index if not exists product_orders_account_id_idx on product_orders (account_id, create_date desc, product_order_id desc);
+create index if not exists product_orders_status_idx on product_orders (status, create_date desc, product_order_id desc);
+create index if not exists product_orders_create_date_idx on product_orders (create_date desc, product_order_id desc);
+create unique index if not exists product_orders_request_id_idx on product_orders (request_id);
+
+-- written in the same transaction as the order, redis only caches finished outcomes
+create table if not exists order_idempotency_keys
+(
+    request_id varchar(512) not null primary key,
+    fingerprint varchar(64) not null,
+    state varchar(16) not null,
+    response text,
+    error text,
+    create_date timestamp not null,
+    update_date timestamp not null
+);
+
+create table if not exists product_order_items
+(
+    product_order_item_id varchar(512) not null primary key,
+    product_order_id varchar(512) not null,
+    product_id varchar(512) not null,
+    quantity integer not null,
+    unit_price numeric not null,
+    line_total numeric not null
+);
+
+-- orders of docker/schema.sql hold a single product, it becomes their only item
+do $$
+begin
+    if exists (select 1 from information_schema.columns
+               where table_schema = current_schema() and table_name = 'product_orders' and column_name = 'product_id') then
+        insert into product_order_items (product_order_item_id, product_order_id, product_id, quantity, unit_price, line_total)
+        select o.product_order_id, o.product_order_id, o.product_id, 1, coalesce(p.price, 0), coalesce(p.price, 0)
+        from product_orders o
+        left join products p on p.product_id = o.product_id
+        where o.product_id is not null
+        on conflict (product_order_item_id) do nothing;
+        update product_orders o set total_amount = coalesce(p.price, 0)
+        from products p where p.product_id = o.product_id and o.total_amount is null;
+        alter table product_orders alter column product_id drop not null;
+    end if;
+end $$;
+update product_orders set total_amount = 0 where total_amount is null;
+update product_orders set update_date = create_date where update_date is null;
+alter table product_orders alter column total_amount set not null;
+
+create index if not exists product_order_items_product_order_id_idx on product_order_items (product_order_id);
+create index if not exists product_order_items_product_id_idx on product_order_items (product_id, product_order_id);
@@ -0,0 +1,6 @@
+alter table product_order_items drop constraint if exists product_order_items_product_order_id_fkey;
+
+alter table product_orders drop constraint if exists product_orders_pkey;
+
+alter table products alter column create_date type date, alter column update_date type date;
+alter table products drop constraint if exists products_pkey;
@@ -0,0 +1,7 @@
+alter table products add constraint products_pkey primary key (product_id);
+alter table products alter column create_date type timestamp, alter column update_date type timestamp;
+
+alter table product_orders add constraint product_orders_pkey primary key (product_order_id);
+
+alter table product_order_items add constraint product_order_items_product_order_id_fkey
+    foreign key (product_order_id) references product_orders (product_order_id);
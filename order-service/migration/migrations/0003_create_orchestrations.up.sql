@@ -0,0 +1,10 @@
+-- saga state when ORCHESTRATION_STORE=postgres, version is increased on every compare-and-swap
+create table if not exists orchestrations
+(
+    uuid varchar(512) not null primary key,
+    status varchar(32) not null,
+    expiration_time bigint not null,
+    version bigint not null default 0
+);
+
+create index if not exists orchestrations_expiration_time_idx on orchestrations (expiration_time);
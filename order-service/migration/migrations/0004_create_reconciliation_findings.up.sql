@@ -0,0 +1,20 @@
+-- mismatches between orders and payment transactions found by the reconciliation job
+create table if not exists reconciliation_findings
+(
+    finding_id varchar(512) not null primary key,
+    request_id varchar(512) not null,
+    kind varchar(32) not null,
+    status varchar(16) not null,
+    order_status varchar(32) not null,
+    order_amount numeric not null,
+    charged_amount numeric not null,
+    currency varchar(3) not null,
+    detail text not null,
+    compensation_triggered boolean not null,
+    first_seen_date timestamp not null,
+    last_seen_date timestamp not null,
+    resolved_date timestamp
+);
+
+create unique index if not exists reconciliation_findings_request_id_kind_idx on reconciliation_findings (request_id, kind);
+create index if not exists reconciliation_findings_status_idx on reconciliation_findings (status);
@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// IdempotencyKey is the durable record of how a request id was handled, redis only caches it.
+type IdempotencyKey struct {
+	RequestId   string    `gorm:"type:varchar(512);primary_key" sql:"requestId"`
+	Fingerprint string    `gorm:"not null" sql:"fingerprint"`
+	State       string    `gorm:"type:varchar(16);not null" sql:"state"`
+	Response    string    `gorm:"type:text" sql:"response"`
+	Error       string    `gorm:"type:text" sql:"error"`
+	CreateDate  time.Time `gorm:"not null" sql:"createDate"`
+	UpdateDate  time.Time `gorm:"not null" sql:"updateDate"`
+}
+
+func (IdempotencyKey) TableName() string {
+	return "order_idempotency_keys"
+}
@@ -2,10 +2,22 @@ package model
 
 import "time"
 
+const (
+	OrderStatusPending         = "PENDING"
+	OrderStatusPaymentAccepted = "PAYMENT_ACCEPTED"
+	OrderStatusConfirmed       = "CONFIRMED"
+	OrderStatusRollingBack     = "ROLLING_BACK"
+	OrderStatusCompensated     = "COMPENSATED"
+)
+
 type ProductOrder struct {
-	ProductOrderId string    `gorm:"type:bigint;primary_key" sql:"productOrderId"`
-	ProductId      string    `gorm:"not null" sql:"productId"`
-	AccountId      string    `gorm:"not null" sql:"accountId"`
-	CreateDate     time.Time `gorm:"not null" sql:"createDate"`
-	RequestId      string    `gorm:"not null" sql:"requestId"`
+	ProductOrderId string             `gorm:"type:varchar(512);primary_key" sql:"productOrderId"`
+	AccountId      string             `gorm:"not null" sql:"accountId"`
+	Status         string             `gorm:"type:varchar(32);not null" sql:"status"`
+	TotalAmount    float64            `gorm:"type:numeric;not null" sql:"totalAmount"`
+	Currency       string             `gorm:"type:varchar(3);not null" sql:"currency"`
+	CreateDate     time.Time          `gorm:"not null" sql:"createDate"`
+	UpdateDate     time.Time          `gorm:"not null" sql:"updateDate"`
+	RequestId      string             `gorm:"not null" sql:"requestId"`
+	Items          []ProductOrderItem `gorm:"foreignKey:ProductOrderId;references:ProductOrderId"`
 }
@@ -0,0 +1,11 @@
+package model
+
+// ProductOrderItem is a line of an order, UnitPrice is the product price at the time the order was placed.
+type ProductOrderItem struct {
+	ProductOrderItemId string  `gorm:"type:varchar(512);primary_key" sql:"productOrderItemId"`
+	ProductOrderId     string  `gorm:"not null" sql:"productOrderId"`
+	ProductId          string  `gorm:"not null" sql:"productId"`
+	Quantity           int     `gorm:"not null" sql:"quantity"`
+	UnitPrice          float64 `gorm:"type:numeric;not null" sql:"unitPrice"`
+	LineTotal          float64 `gorm:"type:numeric;not null" sql:"lineTotal"`
+}
@@ -0,0 +1,38 @@
+package model
+
+import "time"
+
+const (
+	// FindingKindOrphanedCharge is a charge that was not refunded although its order is compensated or missing
+	FindingKindOrphanedCharge = "ORPHANED_CHARGE"
+	// FindingKindMissingCharge is a confirmed order that was never charged
+	FindingKindMissingCharge = "MISSING_CHARGE"
+	// FindingKindRefundedOrder is a confirmed order whose charge was refunded
+	FindingKindRefundedOrder = "REFUNDED_CONFIRMED_ORDER"
+	// FindingKindAmountMismatch is a confirmed order charged with another amount than its total
+	FindingKindAmountMismatch = "AMOUNT_MISMATCH"
+)
+
+const (
+	FindingStatusOpen     = "OPEN"
+	FindingStatusResolved = "RESOLVED"
+)
+
+// ReconciliationFinding is a mismatch between an order and the payment transactions of its request,
+// there is at most one per request id and kind. It is resolved once a later run no longer sees the mismatch.
+type ReconciliationFinding struct {
+	FindingId   string `gorm:"type:varchar(512);primary_key" sql:"findingId"`
+	RequestId   string `gorm:"type:varchar(512);not null" sql:"requestId"`
+	Kind        string `gorm:"type:varchar(32);not null" sql:"kind"`
+	Status      string `gorm:"type:varchar(16);not null" sql:"status"`
+	OrderStatus string `gorm:"type:varchar(32);not null" sql:"orderStatus"`
+	// amounts in the order currency, the charged amount is net of refunds
+	OrderAmount           float64    `gorm:"type:numeric;not null" sql:"orderAmount"`
+	ChargedAmount         float64    `gorm:"type:numeric;not null" sql:"chargedAmount"`
+	Currency              string     `gorm:"type:varchar(3);not null" sql:"currency"`
+	Detail                string     `gorm:"type:text;not null" sql:"detail"`
+	CompensationTriggered bool       `gorm:"not null" sql:"compensationTriggered"`
+	FirstSeenDate         time.Time  `gorm:"not null" sql:"firstSeenDate"`
+	LastSeenDate          time.Time  `gorm:"not null" sql:"lastSeenDate"`
+	ResolvedDate          *time.Time `sql:"resolvedDate"`
+}
@@ -0,0 +1,8 @@
+package openapi
+
+import _ "embed"
+
+// Spec is the OpenAPI document of the order-service.
+//
+//go:embed openapi.yaml
+var Spec []byte
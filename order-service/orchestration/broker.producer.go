@@ -0,0 +1,37 @@
+package orchestration
+
+import (
+	"common/broker"
+	"common/signing"
+	"context"
+	"errors"
+	"order-service/configs"
+)
+
+type ProducerInterface interface {
+	Produce(orchestrationId string) error
+}
+
+type Producer struct {
+	config *configs.Config
+	broker broker.Broker
+	// signs the expired events for the orchestrator, nil publishes them unsigned
+	signer *signing.Signer
+}
+
+func NewProducer(cfg *configs.Config, broker broker.Broker, signer *signing.Signer) *Producer {
+	return &Producer{
+		config: cfg,
+		broker: broker,
+		signer: signer,
+	}
+}
+
+func (prod *Producer) Produce(orchestrationId string) error {
+	if prod.broker == nil {
+		return errors.New("broker not initialized")
+	}
+	msg := broker.Message{Body: []byte(orchestrationId)}
+	prod.signer.SignMessage(&msg)
+	return prod.broker.Publish(context.Background(), prod.config.RMQExpiredEventQueue, msg)
+}
@@ -0,0 +1,89 @@
+package orchestration
+
+import (
+	"common/broker"
+	"common/fault"
+	"common/signing"
+	"context"
+	"gorm.io/gorm"
+	"log"
+	"order-service/configs"
+	"order-service/event"
+	"order-service/repository"
+)
+
+type RollbackConsumer struct {
+	db              *gorm.DB
+	broker          broker.Broker
+	config          *configs.Config
+	orderRepository *repository.OrderRepository
+	orderEvents     *event.RedisPublisher
+	// nil accepts unsigned messages
+	verifier *signing.MessageVerifier
+}
+
+func NewRollbackConsumer(db *gorm.DB, cfg *configs.Config, broker broker.Broker, orderRepository *repository.OrderRepository, orderEvents *event.RedisPublisher, verifier *signing.MessageVerifier) *RollbackConsumer {
+	return &RollbackConsumer{
+		db:              db,
+		broker:          broker,
+		config:          cfg,
+		orderRepository: orderRepository,
+		orderEvents:     orderEvents,
+		verifier:        verifier,
+	}
+}
+
+func (rc *RollbackConsumer) Consume(ctx context.Context) error {
+	queue := rc.config.RMQRollbackEventOrderQueue
+	return rc.broker.Subscribe(ctx, queue, signing.Guard(rc.broker, rc.verifier, queue, rc.config.RMQQuarantineQueue, func(ctx context.Context, delivery broker.Delivery) {
+		log.Printf("Rollback Consumer: Received message: %s", string(delivery.Body))
+
+		requestId := string(delivery.Body)
+		err := rc.processRollback(requestId)
+		if err != nil {
+			log.Printf("Error occured processing a rollback: %v", err)
+		}
+		// a failed compensation is retried once and then parked in the dead-letter queue to be replayed
+		broker.Settle(ctx, rc.broker, queue, rc.config.RMQDeadLetterQueue, delivery, err)
+	}))
+}
+
+// processRollback compensates the whole order in a single update, items stay attached for history.
+func (rc *RollbackConsumer) processRollback(requestId string) error {
+	if err := fault.Inject(fault.OrderRollback); err != nil {
+		return err
+	}
+
+	tx := rc.getDbConnection()
+
+	order, cancelled, err := rc.orderRepository.Cancel(tx, requestId)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	err = tx.Commit().Error
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if !cancelled {
+		log.Printf("requestId %s has no order to rollback", requestId)
+		return nil
+	}
+	rc.orderEvents.Publish(*order)
+	log.Printf("requestId %s successfully rolledback", requestId)
+	return nil
+}
+
+func (rc *RollbackConsumer) getDbConnection() *gorm.DB {
+	tx := rc.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	return tx
+}
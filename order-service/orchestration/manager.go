@@ -1,9 +1,9 @@
 package orchestration
 
 import (
+	"common/statestore"
 	"context"
-	"encoding/json"
-	"github.com/go-redis/redis/v8"
+	"errors"
 	"order-service/configs"
 	"time"
 )
@@ -15,55 +15,70 @@ type ManagerInterface interface {
 }
 
 type Manager struct {
-	redisClient *redis.Client
-	rmqProducer *RMQProducer
-	config      *configs.Config
+	store    statestore.StateStore
+	producer *Producer
+	config   *configs.Config
 }
 
-func NewOrchestrationManager(redisClient *redis.Client, rmqProducer *RMQProducer, config *configs.Config) *Manager {
+func NewOrchestrationManager(store statestore.StateStore, producer *Producer, config *configs.Config) *Manager {
 	return &Manager{
-		redisClient: redisClient,
-		rmqProducer: rmqProducer,
-		config:      config,
+		store:    store,
+		producer: producer,
+		config:   config,
 	}
 }
 
 func (or *Manager) Start(orchestrationId string) error {
-	entity := or.build(orchestrationId, InProgress)
-	data, err := json.Marshal(entity)
-	if err != nil {
-		return err
-	}
+	return or.store.Create(context.Background(), or.build(orchestrationId, InProgress))
+}
 
-	err = or.redisClient.HSet(context.Background(), or.config.OrchestrationMapName, orchestrationId, data).Err()
-	if err != nil {
+func (or *Manager) End(orchestrationId string) error {
+	return or.store.Delete(context.Background(), orchestrationId)
+}
+
+func (or *Manager) Rollback(orchestrationId string) error {
+	// the orchestrator only rolls back records in ROLLBACK status, otherwise it would wait for the record to expire
+	if err := or.markRollback(context.Background(), orchestrationId); err != nil {
 		return err
 	}
-	return nil
-}
 
-func (or *Manager) End(orchestrationId string) error {
-	err := or.redisClient.HDel(context.Background(), or.config.OrchestrationMapName, orchestrationId).Err()
+	// publish to the broker so that orchestration will pick it up and started rollback
+	err := or.producer.Produce(orchestrationId)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (or *Manager) Rollback(orchestrationId string) error {
-	// publish to rmq so that orchestration will pick it up and started rollback
-	err := or.rmqProducer.Produce(orchestrationId)
-	if err != nil {
-		return err
+// markRollback swaps the record to ROLLBACK, retrying when the orchestrator expires it at the same time.
+func (or *Manager) markRollback(ctx context.Context, orchestrationId string) error {
+	next := or.build(orchestrationId, StatusRollback)
+	for {
+		current, err := or.store.Get(ctx, orchestrationId)
+		if errors.Is(err, statestore.ErrNotFound) {
+			err = or.store.Create(ctx, next)
+			if errors.Is(err, statestore.ErrExists) {
+				continue
+			}
+			return err
+		} else if err != nil {
+			return err
+		}
+		if current.Status == StatusRollback {
+			return nil
+		}
+
+		err = or.store.CompareAndSwap(ctx, *current, next)
+		if !errors.Is(err, statestore.ErrConflict) && !errors.Is(err, statestore.ErrNotFound) {
+			return err
+		}
 	}
-	return nil
 }
 
-func (or *Manager) build(orchestrationId string, status string) Model {
-	orchestrationModel := Model{
+func (or *Manager) build(orchestrationId string, status string) statestore.Record {
+	return statestore.Record{
 		UUID:           orchestrationId,
 		Status:         status,
 		ExpirationTime: time.Now().UnixMilli() + or.config.OrchestrationExpirationTimeSeconds*1000,
 	}
-	return orchestrationModel
 }
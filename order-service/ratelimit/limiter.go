@@ -0,0 +1,163 @@
+package ratelimit
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	uuid "github.com/satori/go.uuid"
+)
+
+// scopes a request is counted in, a limit of zero leaves its scope unlimited
+const (
+	ScopeAccount = "account"
+	ScopeIP      = "ip"
+	ScopeGlobal  = "global"
+)
+
+// Limits are the requests allowed per window in every scope, they can be swapped while the limiter is in use.
+type Limits struct {
+	Enabled    bool
+	Window     time.Duration
+	PerAccount int
+	PerIP      int
+	Global     int
+}
+
+// Counter is one scope a request is counted in.
+type Counter struct {
+	Scope string
+	Key   string
+	Limit int
+}
+
+// Result describes the scope closest to its limit, or the one that rejected the request.
+type Result struct {
+	Allowed   bool
+	Scope     string
+	Limit     int
+	Remaining int
+	// until the oldest request of the scope leaves the window, for a rejected request when it may be retried
+	Reset time.Duration
+}
+
+// sliding window log: every scope keeps the timestamps of its requests in a sorted set. The request is only recorded
+// when no scope is at its limit, so rejected requests do not lock a bot out for good and no scope is charged for a
+// request another one rejected. The clock of redis is used, so every replica agrees on the window.
+var slidingWindow = redis.NewScript(`
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+local window = tonumber(ARGV[1])
+local member = ARGV[2]
+local closest, closestRemaining, closestReset = 0, nil, 0
+local rejected, retryAfter = 0, 0
+for i, key in ipairs(KEYS) do
+	local limit = tonumber(ARGV[i + 2])
+	redis.call('ZREMRANGEBYSCORE', key, '-inf', now - window)
+	local count = redis.call('ZCARD', key)
+	local reset = window
+	local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+	if oldest[2] then
+		reset = tonumber(oldest[2]) + window - now
+	end
+	local remaining = limit - count
+	if remaining <= 0 then
+		if rejected == 0 or reset > retryAfter then
+			rejected, retryAfter = i, reset
+		end
+	end
+	if closestRemaining == nil or remaining < closestRemaining then
+		closest, closestRemaining, closestReset = i, remaining, reset
+	end
+end
+if rejected > 0 then
+	return {0, rejected, 0, retryAfter}
+end
+for i, key in ipairs(KEYS) do
+	redis.call('ZADD', key, now, member)
+	redis.call('PEXPIRE', key, window)
+end
+return {1, closest, closestRemaining - 1, closestReset}
+`)
+
+type LimiterInterface interface {
+	Limits() Limits
+	SetLimits(limits Limits)
+	Allow(ctx context.Context, counters []Counter) (Result, error)
+}
+
+// Limiter counts requests in redis, so the limits hold across all replicas of the order-service.
+type Limiter struct {
+	redisClient *redis.Client
+	prefix      string
+
+	mu     sync.RWMutex
+	limits Limits
+}
+
+func NewLimiter(redisClient *redis.Client, prefix string, limits Limits) *Limiter {
+	return &Limiter{
+		redisClient: redisClient,
+		prefix:      prefix,
+		limits:      limits,
+	}
+}
+
+func (l *Limiter) Limits() Limits {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.limits
+}
+
+// SetLimits applies new limits to the following requests, the requests already counted stay in their windows.
+func (l *Limiter) SetLimits(limits Limits) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limits = limits
+}
+
+// Allow counts a request in every scope of counters unless one of them is at its limit. Counters with a limit of
+// zero are skipped, without any left the request is allowed right away.
+func (l *Limiter) Allow(ctx context.Context, counters []Counter) (Result, error) {
+	window := l.Limits().Window
+	var counted []Counter
+	for _, counter := range counters {
+		if counter.Limit > 0 {
+			counted = append(counted, counter)
+		}
+	}
+	if len(counted) == 0 || window <= 0 {
+		return Result{Allowed: true}, nil
+	}
+
+	keys := make([]string, 0, len(counted))
+	args := []interface{}{window.Milliseconds(), uuid.NewV4().String()}
+	for _, counter := range counted {
+		keys = append(keys, l.prefix+counter.Scope+":"+counter.Key)
+		args = append(args, counter.Limit)
+	}
+	values, err := slidingWindow.Run(ctx, l.redisClient, keys, args...).Int64Slice()
+	if err != nil {
+		return Result{}, err
+	}
+
+	counter := counted[values[1]-1]
+	return Result{
+		Allowed:   values[0] == 1,
+		Scope:     counter.Scope,
+		Limit:     counter.Limit,
+		Remaining: int(values[2]),
+		Reset:     time.Duration(values[3]) * time.Millisecond,
+	}, nil
+}
+
+// Seconds rounds d up to whole seconds as used by the Retry-After and RateLimit-Reset headers.
+func Seconds(d time.Duration) string {
+	seconds := int64((d + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	return strconv.FormatInt(seconds, 10)
+}
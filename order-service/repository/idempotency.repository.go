@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"order-service/model"
+	"time"
+)
+
+type IdempotencyRepositoryInterface interface {
+	Acquire(tx *gorm.DB, key *model.IdempotencyKey) (bool, error)
+	Fetch(tx *gorm.DB, requestId string) (*model.IdempotencyKey, bool, error)
+	Complete(tx *gorm.DB, requestId string, state string, response string, errorMessage string) error
+}
+
+type IdempotencyRepository struct{}
+
+func NewIdempotencyRepository() *IdempotencyRepository {
+	return &IdempotencyRepository{}
+}
+
+// Acquire inserts the key unless it exists already, the unique request id decides between concurrent requests.
+func (repo *IdempotencyRepository) Acquire(tx *gorm.DB, key *model.IdempotencyKey) (bool, error) {
+	nowTime := time.Now()
+	key.CreateDate = nowTime
+	key.UpdateDate = nowTime
+
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(key)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (repo *IdempotencyRepository) Fetch(tx *gorm.DB, requestId string) (*model.IdempotencyKey, bool, error) {
+	var key model.IdempotencyKey
+	err := tx.Where("request_id = ?", requestId).First(&key).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	return &key, true, nil
+}
+
+func (repo *IdempotencyRepository) Complete(tx *gorm.DB, requestId string, state string, response string, errorMessage string) error {
+	return tx.Model(&model.IdempotencyKey{}).
+		Where("request_id = ?", requestId).
+		Updates(map[string]interface{}{
+			"state":       state,
+			"response":    response,
+			"error":       errorMessage,
+			"update_date": time.Now(),
+		}).Error
+}
@@ -1,16 +1,31 @@
 package repository
 
 import (
+	"common/pagination"
 	uuid "github.com/satori/go.uuid"
 	"gorm.io/gorm"
-	"order-service/dto/request"
 	"order-service/model"
 	"time"
 )
 
 type OrderRepositoryInterface interface {
-	Insert(tx *gorm.DB, order request.OrderRequest) (model.ProductOrder, error)
-	Delete(tx *gorm.DB, requestId string) error
+	Insert(tx *gorm.DB, order *model.ProductOrder) error
+	UpdateStatus(tx *gorm.DB, productOrderId string, fromStatus string, toStatus string) (bool, error)
+	Cancel(tx *gorm.DB, requestId string) (*model.ProductOrder, bool, error)
+	Fetch(tx *gorm.DB, productOrderId string) (*model.ProductOrder, bool, error)
+	FetchByRequestId(tx *gorm.DB, requestId string) (*model.ProductOrder, bool, error)
+	FetchAll(tx *gorm.DB, filter OrderFilter) ([]model.ProductOrder, error)
+}
+
+// OrderFilter narrows FetchAll, empty fields are not applied.
+type OrderFilter struct {
+	AccountId string
+	ProductId string
+	Status    string
+	From      *time.Time
+	To        *time.Time
+	Cursor    *pagination.Cursor
+	Limit     int
 }
 
 type OrderRepository struct{}
@@ -19,25 +34,94 @@ func NewOrderRepository() *OrderRepository {
 	return &OrderRepository{}
 }
 
-func (repo *OrderRepository) Insert(tx *gorm.DB, order request.OrderRequest) (model.ProductOrder, error) {
+// Insert stores a pending order together with its items, ids and dates are assigned here.
+func (repo *OrderRepository) Insert(tx *gorm.DB, order *model.ProductOrder) error {
 	nowTime := time.Now()
-	orderEntity := model.ProductOrder{
-		ProductOrderId: uuid.NewV4().String(),
-		ProductId:      order.ProductId,
-		CreateDate:     nowTime,
-		AccountId:      order.AccountID,
-		RequestId:      order.RequestId,
+	order.ProductOrderId = uuid.NewV4().String()
+	order.CreateDate = nowTime
+	order.UpdateDate = nowTime
+	order.Status = model.OrderStatusPending
+	for i := range order.Items {
+		order.Items[i].ProductOrderItemId = uuid.NewV4().String()
+		order.Items[i].ProductOrderId = order.ProductOrderId
+	}
+
+	return tx.Create(order).Error
+}
+
+// UpdateStatus changes the status only while the order still is in fromStatus,
+// it reports false when the order was changed by someone else in the meantime.
+func (repo *OrderRepository) UpdateStatus(tx *gorm.DB, productOrderId string, fromStatus string, toStatus string) (bool, error) {
+	result := tx.Model(&model.ProductOrder{}).
+		Where("product_order_id = ? AND status = ?", productOrderId, fromStatus).
+		Updates(map[string]interface{}{"status": toStatus, "update_date": time.Now()})
+	if result.Error != nil {
+		return false, result.Error
 	}
+	return result.RowsAffected == 1, nil
+}
+
+// Cancel marks the whole order as compensated, it reports false when there is nothing left to cancel.
+func (repo *OrderRepository) Cancel(tx *gorm.DB, requestId string) (*model.ProductOrder, bool, error) {
+	result := tx.Model(&model.ProductOrder{}).
+		Where("request_id = ? AND status <> ?", requestId, model.OrderStatusCompensated).
+		Updates(map[string]interface{}{"status": model.OrderStatusCompensated, "update_date": time.Now()})
+	if result.Error != nil {
+		return nil, false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, false, nil
+	}
+	return repo.FetchByRequestId(tx, requestId)
+}
 
-	savedOrderEntity := tx.Create(&orderEntity)
-	if savedOrderEntity.Error != nil {
-		return model.ProductOrder{}, savedOrderEntity.Error
+func (repo *OrderRepository) Fetch(tx *gorm.DB, productOrderId string) (*model.ProductOrder, bool, error) {
+	return repo.fetchOne(tx.Where("product_order_id = ?", productOrderId))
+}
+
+func (repo *OrderRepository) FetchByRequestId(tx *gorm.DB, requestId string) (*model.ProductOrder, bool, error) {
+	return repo.fetchOne(tx.Where("request_id = ?", requestId))
+}
+
+// FetchAll returns orders newest first, ties on create date are broken by id so pages never overlap.
+func (repo *OrderRepository) FetchAll(tx *gorm.DB, filter OrderFilter) ([]model.ProductOrder, error) {
+	query := tx.Model(&model.ProductOrder{})
+	if filter.AccountId != "" {
+		query = query.Where("account_id = ?", filter.AccountId)
+	}
+	if filter.ProductId != "" {
+		query = query.Where("EXISTS (SELECT 1 FROM product_order_items WHERE product_order_items.product_order_id = product_orders.product_order_id AND product_order_items.product_id = ?)", filter.ProductId)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.From != nil {
+		query = query.Where("create_date >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("create_date < ?", *filter.To)
+	}
+	if filter.Cursor != nil {
+		query = query.Where("(create_date < ? OR (create_date = ? AND product_order_id < ?))",
+			filter.Cursor.CreateDate, filter.Cursor.CreateDate, filter.Cursor.Id)
 	}
 
-	return orderEntity, nil
+	var orders []model.ProductOrder
+	err := query.Preload("Items").Order("create_date desc").Order("product_order_id desc").Limit(filter.Limit).Find(&orders).Error
+	if err != nil {
+		return nil, err
+	}
+	return orders, nil
 }
 
-func (repo *OrderRepository) Delete(tx *gorm.DB, requestId string) error {
-	result := tx.Where("request_id = ?", requestId).Delete(&model.ProductOrder{})
-	return result.Error
+func (repo *OrderRepository) fetchOne(query *gorm.DB) (*model.ProductOrder, bool, error) {
+	var order model.ProductOrder
+	err := query.Preload("Items").First(&order).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	return &order, true, nil
 }
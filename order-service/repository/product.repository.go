@@ -7,6 +7,7 @@ import (
 
 type ProductRepositoryInterface interface {
 	Fetch(tx *gorm.DB, productId string) (*model.Product, bool, error)
+	FetchAll(tx *gorm.DB, productIds []string) ([]model.Product, error)
 }
 
 type ProductRepository struct{}
@@ -26,3 +27,12 @@ func (repo *ProductRepository) Fetch(tx *gorm.DB, productId string) (*model.Prod
 	}
 	return &product, true, nil
 }
+
+func (repo *ProductRepository) FetchAll(tx *gorm.DB, productIds []string) ([]model.Product, error) {
+	var products []model.Product
+	err := tx.Table("products").Where("product_id IN ?", productIds).Find(&products).Error
+	if err != nil {
+		return nil, err
+	}
+	return products, nil
+}
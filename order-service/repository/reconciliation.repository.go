@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"common/pagination"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"order-service/model"
+	"time"
+)
+
+type ReconciliationRepositoryInterface interface {
+	Upsert(tx *gorm.DB, finding *model.ReconciliationFinding) error
+	Resolve(tx *gorm.DB, findingId string) error
+	Fetch(tx *gorm.DB, findingId string) (*model.ReconciliationFinding, bool, error)
+	FetchOpen(tx *gorm.DB) ([]model.ReconciliationFinding, error)
+	FetchAll(tx *gorm.DB, filter FindingFilter) ([]model.ReconciliationFinding, error)
+}
+
+// FindingFilter narrows FetchAll, empty fields are not applied.
+type FindingFilter struct {
+	Status    string
+	Kind      string
+	RequestId string
+	Cursor    *pagination.Cursor
+	Limit     int
+}
+
+type ReconciliationRepository struct{}
+
+func NewReconciliationRepository() *ReconciliationRepository {
+	return &ReconciliationRepository{}
+}
+
+// Upsert records a finding, a finding of the same request and kind seen before is refreshed and reopened.
+// The stored row is read back into finding, so it keeps the id and first seen date of the earlier finding.
+func (repo *ReconciliationRepository) Upsert(tx *gorm.DB, finding *model.ReconciliationFinding) error {
+	return tx.Clauses(clause.Returning{}, clause.OnConflict{
+		Columns: []clause.Column{{Name: "request_id"}, {Name: "kind"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"status", "order_status", "order_amount", "charged_amount", "currency", "detail",
+			"compensation_triggered", "last_seen_date", "resolved_date",
+		}),
+	}).Create(finding).Error
+}
+
+func (repo *ReconciliationRepository) Resolve(tx *gorm.DB, findingId string) error {
+	nowTime := time.Now()
+	return tx.Model(&model.ReconciliationFinding{}).
+		Where("finding_id = ? AND status = ?", findingId, model.FindingStatusOpen).
+		Updates(map[string]interface{}{"status": model.FindingStatusResolved, "resolved_date": nowTime, "last_seen_date": nowTime}).Error
+}
+
+func (repo *ReconciliationRepository) Fetch(tx *gorm.DB, findingId string) (*model.ReconciliationFinding, bool, error) {
+	var finding model.ReconciliationFinding
+	err := tx.Where("finding_id = ?", findingId).First(&finding).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	return &finding, true, nil
+}
+
+func (repo *ReconciliationRepository) FetchOpen(tx *gorm.DB) ([]model.ReconciliationFinding, error) {
+	var findings []model.ReconciliationFinding
+	err := tx.Where("status = ?", model.FindingStatusOpen).Find(&findings).Error
+	if err != nil {
+		return nil, err
+	}
+	return findings, nil
+}
+
+// FetchAll returns findings newest first, ties on first seen date are broken by id so pages never overlap.
+func (repo *ReconciliationRepository) FetchAll(tx *gorm.DB, filter FindingFilter) ([]model.ReconciliationFinding, error) {
+	query := tx.Model(&model.ReconciliationFinding{})
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Kind != "" {
+		query = query.Where("kind = ?", filter.Kind)
+	}
+	if filter.RequestId != "" {
+		query = query.Where("request_id = ?", filter.RequestId)
+	}
+	if filter.Cursor != nil {
+		query = query.Where("(first_seen_date < ? OR (first_seen_date = ? AND finding_id < ?))",
+			filter.Cursor.CreateDate, filter.Cursor.CreateDate, filter.Cursor.Id)
+	}
+
+	var findings []model.ReconciliationFinding
+	err := query.Order("first_seen_date desc").Order("finding_id desc").Limit(filter.Limit).Find(&findings).Error
+	if err != nil {
+		return nil, err
+	}
+	return findings, nil
+}
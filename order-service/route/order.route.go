@@ -6,16 +6,29 @@ import (
 )
 
 type OrderRouteHandler struct {
-	orderHandler handler.OrderHandler
+	orderHandler     handler.OrderHandler
+	authenticate     gin.HandlerFunc
+	rateLimit        gin.HandlerFunc
+	rateLimitAccount gin.HandlerFunc
+	validate         gin.HandlerFunc
 }
 
-func NewOrderRouteHandler(orderHandler handler.OrderHandler) OrderRouteHandler {
+func NewOrderRouteHandler(orderHandler handler.OrderHandler, authenticate gin.HandlerFunc, rateLimit gin.HandlerFunc, rateLimitAccount gin.HandlerFunc, validate gin.HandlerFunc) OrderRouteHandler {
 	return OrderRouteHandler{
-		orderHandler: orderHandler,
+		orderHandler:     orderHandler,
+		authenticate:     authenticate,
+		rateLimit:        rateLimit,
+		rateLimitAccount: rateLimitAccount,
+		validate:         validate,
 	}
 }
 
 func (h *OrderRouteHandler) OrderRoute(group *gin.RouterGroup) {
 	router := group.Group("order")
-	router.POST("/create", h.orderHandler.MakeOrder)
+	router.POST("/create", h.rateLimit, h.authenticate, h.rateLimitAccount, h.validate, h.orderHandler.MakeOrder)
+	router.GET("", h.authenticate, h.validate, h.orderHandler.ListOrders)
+	router.GET("/:id", h.authenticate, h.validate, h.orderHandler.GetOrder)
+	router.GET("/:id/status", h.authenticate, h.validate, h.orderHandler.GetOrderStatus)
+	router.GET("/:id/events", h.authenticate, h.validate, h.orderHandler.StreamOrderEvents)
+	router.GET("/by-request/:requestId", h.authenticate, h.validate, h.orderHandler.GetOrderByRequestId)
 }
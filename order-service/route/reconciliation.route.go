@@ -0,0 +1,30 @@
+package route
+
+import (
+	"github.com/gin-gonic/gin"
+	"order-service/handler"
+)
+
+type ReconciliationRouteHandler struct {
+	reconciliationHandler handler.ReconciliationHandler
+	authenticate          gin.HandlerFunc
+	requireAdmin          gin.HandlerFunc
+	validate              gin.HandlerFunc
+}
+
+func NewReconciliationRouteHandler(reconciliationHandler handler.ReconciliationHandler, authenticate gin.HandlerFunc, requireAdmin gin.HandlerFunc, validate gin.HandlerFunc) ReconciliationRouteHandler {
+	return ReconciliationRouteHandler{
+		reconciliationHandler: reconciliationHandler,
+		authenticate:          authenticate,
+		requireAdmin:          requireAdmin,
+		validate:              validate,
+	}
+}
+
+// ReconciliationRoute serves the findings and the refunding runs to admins only.
+func (h *ReconciliationRouteHandler) ReconciliationRoute(group *gin.RouterGroup) {
+	router := group.Group("reconciliation", h.authenticate, h.requireAdmin)
+	router.GET("/findings", h.validate, h.reconciliationHandler.ListFindings)
+	router.GET("/findings/:id", h.validate, h.reconciliationHandler.GetFinding)
+	router.POST("/run", h.validate, h.reconciliationHandler.RunReconciliation)
+}
@@ -0,0 +1,44 @@
+package service
+
+import "errors"
+
+var (
+	ErrOrderNotFound          = errors.New("order not found")
+	ErrAccountNotFound        = errors.New("account does not exist")
+	ErrAccountNotOwned        = errors.New("account belongs to another customer")
+	ErrAccountIdRequired      = errors.New("accountId is required to list orders")
+	ErrFindingNotFound        = errors.New("reconciliation finding not found")
+	ErrProductNotFound        = errors.New("product does not exist")
+	ErrMixedCurrencies        = errors.New("products of an order must be priced in the same currency")
+	ErrOutOfStock             = errors.New("products are out of stock")
+	ErrPaymentDeclined        = errors.New("payment was declined")
+	ErrTooManyOrders          = errors.New("too many orders in progress")
+	ErrRequestInProgress      = errors.New("request is still in progress")
+	ErrIdempotencyKeyMismatch = errors.New("request id was already used with a different payload")
+)
+
+// replayableErrors are recognized again when a failed request is replayed from the idempotency store.
+var replayableErrors = []error{
+	ErrProductNotFound,
+	ErrMixedCurrencies,
+	ErrOutOfStock,
+	ErrPaymentDeclined,
+}
+
+func restoreError(message string) error {
+	for _, err := range replayableErrors {
+		if err.Error() == message {
+			return err
+		}
+	}
+	return errors.New(message)
+}
+
+// duplicateRequestError carries the idempotency record of the request that took the request id first.
+type duplicateRequestError struct {
+	record *IdempotencyRecord
+}
+
+func (e *duplicateRequestError) Error() string {
+	return "request id already taken in state " + e.record.State
+}
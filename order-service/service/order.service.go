@@ -1,30 +1,67 @@
 package service
 
 import (
+	"common/auth"
+	"common/fault"
+	"common/pagination"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"fmt"
 	uuid "github.com/satori/go.uuid"
 	"gorm.io/gorm"
+	"log"
+	"math"
 	"net/http"
 	"order-service/client"
+	"order-service/client/paymentapi"
 	"order-service/configs"
 	"order-service/dto/request"
 	"order-service/dto/response"
+	"order-service/event"
+	"order-service/model"
 	"order-service/orchestration"
 	"order-service/repository"
 )
 
+const (
+	ProcessingModeSync  = "sync"
+	ProcessingModeAsync = "async"
+)
+
 type OrderServiceInterface interface {
-	Create(request request.OrderRequest) (response.OrderResponse, error)
+	Create(claims *auth.Claims, request request.OrderRequest) (response.OrderResponse, error)
+	Get(claims *auth.Claims, productOrderId string) (response.OrderResponse, error)
+	GetByRequestId(claims *auth.Claims, requestId string) (response.OrderResponse, error)
+	GetStatus(claims *auth.Claims, productOrderId string) (response.OrderStatusResponse, error)
+	List(claims *auth.Claims, listRequest request.OrderListRequest) (response.OrderPageResponse, error)
 }
 
+var _ OrderServiceInterface = (*OrderService)(nil)
+
 type OrderService struct {
-	conf                 *configs.Config
-	postgresDB           *gorm.DB
-	orderRepository      *repository.OrderRepository
-	redisService         *RedisService
-	paymentClient        *client.PaymentClient
-	productRepository    *repository.ProductRepository
-	orchestrationManager *orchestration.Manager
+	conf                  *configs.Config
+	postgresDB            *gorm.DB
+	orderRepository       *repository.OrderRepository
+	redisService          *RedisService
+	paymentClient         client.PaymentClientInterface
+	inventoryClient       *client.InventoryClient
+	productRepository     *repository.ProductRepository
+	orchestrationManager  *orchestration.Manager
+	orderEvents           *event.RedisPublisher
+	idempotencyRepository *repository.IdempotencyRepository
+	jobs                  chan orderJob
+	// taken by async orders before they are persisted and given back once a worker picked them up, a full queue
+	// rejects an order while its request id is still free for a retry
+	slots chan struct{}
+}
+
+// orderJob is an order accepted in async mode, the claims of its caller are forwarded when it gets charged.
+type orderJob struct {
+	order  model.ProductOrder
+	claims *auth.Claims
 }
 
 func NewOrderService(
@@ -32,93 +69,536 @@ func NewOrderService(
 	postgresDB *gorm.DB,
 	orderRepository *repository.OrderRepository,
 	redisService *RedisService,
-	paymentClient *client.PaymentClient,
+	paymentClient client.PaymentClientInterface,
+	inventoryClient *client.InventoryClient,
 	productRepository *repository.ProductRepository,
-	orchestrationManager *orchestration.Manager) *OrderService {
+	orchestrationManager *orchestration.Manager,
+	orderEvents *event.RedisPublisher,
+	idempotencyRepository *repository.IdempotencyRepository) *OrderService {
 	return &OrderService{
-		conf:                 config,
-		postgresDB:           postgresDB,
-		orderRepository:      orderRepository,
-		redisService:         redisService,
-		paymentClient:        paymentClient,
-		productRepository:    productRepository,
-		orchestrationManager: orchestrationManager,
+		conf:                  config,
+		postgresDB:            postgresDB,
+		orderRepository:       orderRepository,
+		redisService:          redisService,
+		paymentClient:         paymentClient,
+		inventoryClient:       inventoryClient,
+		productRepository:     productRepository,
+		orchestrationManager:  orchestrationManager,
+		orderEvents:           orderEvents,
+		idempotencyRepository: idempotencyRepository,
+		jobs:                  make(chan orderJob, config.OrderWorkerQueueSize),
+		slots:                 make(chan struct{}, config.OrderWorkerQueueSize),
 	}
 }
 
-func (os *OrderService) Create(request request.OrderRequest) (response.OrderResponse, error) {
-	valid, err := os.redisService.IdempotencyValidation(request.RequestId)
-	if err != nil {
+// Create answers duplicates of a request id from the idempotency store, new requests are submitted.
+// Postgres holds the idempotency keys, redis only caches finished outcomes and may be unavailable.
+// Only outcomes of requests that got persisted are remembered, earlier failures free the request id for a retry.
+// With auth enabled the caller has to own the account, claims are nil otherwise.
+func (os *OrderService) Create(claims *auth.Claims, request request.OrderRequest) (response.OrderResponse, error) {
+	if err := os.checkOwner(claims, request.AccountID); err != nil {
 		return response.OrderResponse{}, err
 	}
-	if !valid {
-		return response.OrderResponse{}, errors.New("idempotency validation error")
+
+	fingerprint, err := fingerprintOf(request)
+	if err != nil {
+		return response.OrderResponse{}, err
 	}
 
-	err = os.orchestrationManager.Start(request.RequestId)
+	cached, exists, err := os.redisService.Get(request.RequestId)
 	if err != nil {
+		log.Printf("Idempotency cache unavailable for %s: %v", request.RequestId, err)
+	}
+	if exists {
+		return replay(cached, fingerprint)
+	}
+
+	orderResponse, persisted, err := os.submit(claims, request, fingerprint)
+	var duplicate *duplicateRequestError
+	if errors.As(err, &duplicate) {
+		return replay(duplicate.record, fingerprint)
+	}
+	if err != nil && !persisted {
 		return response.OrderResponse{}, err
 	}
 
+	outcome := IdempotencyRecord{Fingerprint: fingerprint, State: IdempotencyStateSucceeded, Response: &orderResponse}
+	if err != nil {
+		outcome = IdempotencyRecord{Fingerprint: fingerprint, State: IdempotencyStateFailed, Error: err.Error()}
+	}
+	os.complete(request.RequestId, outcome)
+	return orderResponse, err
+}
+
+// submit persists the order as PENDING together with its idempotency key and runs the saga for it.
+// In async mode the saga is handed over to a background worker and the pending order is returned right away.
+// It reports whether the order got persisted, as from then on the request id belongs to the saga.
+// When the request id is taken already the existing record is returned as error.
+func (os *OrderService) submit(claims *auth.Claims, request request.OrderRequest, fingerprint string) (response.OrderResponse, bool, error) {
+	async := os.conf.OrderProcessingMode == ProcessingModeAsync
+	enqueued := false
+	if async {
+		select {
+		case os.slots <- struct{}{}:
+			defer func() {
+				if !enqueued {
+					<-os.slots
+				}
+			}()
+		default:
+			return response.OrderResponse{}, false, ErrTooManyOrders
+		}
+	}
+
 	tx := os.getDbConnection()
-	product, exists, err := os.productRepository.Fetch(tx, request.ProductId)
+	acquired, err := os.idempotencyRepository.Acquire(tx, &model.IdempotencyKey{
+		RequestId:   request.RequestId,
+		Fingerprint: fingerprint,
+		State:       IdempotencyStateInFlight,
+	})
 	if err != nil {
 		tx.Rollback()
-		return response.OrderResponse{}, err
+		return response.OrderResponse{}, false, err
+	}
+	if !acquired {
+		tx.Rollback()
+		return response.OrderResponse{}, false, os.existingRecord(request.RequestId)
 	}
 
-	if !exists {
+	orderEntity, err := os.buildOrder(tx, request)
+	if err != nil {
 		tx.Rollback()
-		return response.OrderResponse{}, errors.New("product does not exist")
+		return response.OrderResponse{}, false, err
 	}
 
-	orderEntity, err := os.orderRepository.Insert(tx, request)
+	err = os.orchestrationManager.Start(request.RequestId)
 	if err != nil {
 		tx.Rollback()
-		return response.OrderResponse{}, err
+		return response.OrderResponse{}, false, err
 	}
 
-	paymentRequest := client.PaymentRequest{
-		RequestID: request.RequestId,
-		UUID:      uuid.NewV4().String(),
-		ProductId: request.ProductId,
-		AccountID: request.AccountID,
-		Amount:    product.Price,
+	if err := fault.Inject(fault.OrderInsertBefore); err != nil {
+		tx.Rollback()
+		return response.OrderResponse{}, false, err
 	}
-	statusCode, err := os.paymentClient.Process(paymentRequest)
+	err = os.orderRepository.Insert(tx, &orderEntity)
 	if err != nil {
 		tx.Rollback()
-		return response.OrderResponse{}, err
+		return response.OrderResponse{}, false, err
 	}
-
-	if statusCode != http.StatusOK {
+	if err := fault.Inject(fault.OrderInsertAfter); err != nil {
 		tx.Rollback()
-		return response.OrderResponse{}, err
+		return response.OrderResponse{}, false, err
 	}
 
+	if err := fault.Inject(fault.OrderCommitBefore); err != nil {
+		tx.Rollback()
+		return response.OrderResponse{}, false, err
+	}
 	err = tx.Commit().Error
 	if err != nil {
 		tx.Rollback()
-		err := os.orchestrationManager.Rollback(request.RequestId)
+		return response.OrderResponse{}, false, err
+	}
+	os.orderEvents.Publish(orderEntity)
+
+	if async {
+		// never blocks, the queue has room for every slot
+		os.jobs <- orderJob{order: orderEntity, claims: claims}
+		enqueued = true
+		return toOrderResponse(orderEntity), true, nil
+	}
+
+	orderEntity, err = os.process(orderEntity, claims)
+	if err != nil {
+		return response.OrderResponse{}, true, err
+	}
+	return toOrderResponse(orderEntity), true, nil
+}
+
+// complete stores the outcome durably first, the cache is refreshed on a best effort basis.
+func (os *OrderService) complete(requestId string, outcome IdempotencyRecord) {
+	var responseData []byte
+	if outcome.Response != nil {
+		data, err := json.Marshal(outcome.Response)
 		if err != nil {
-			return response.OrderResponse{}, err
+			log.Printf("Failed to marshal idempotency outcome of %s: %v", requestId, err)
+			return
+		}
+		responseData = data
+	}
+
+	err := os.idempotencyRepository.Complete(os.postgresDB, requestId, outcome.State, string(responseData), outcome.Error)
+	if err != nil {
+		log.Printf("Failed to store idempotency outcome of %s: %v", requestId, err)
+		return
+	}
+	if err := os.redisService.Set(requestId, outcome); err != nil {
+		log.Printf("Failed to cache idempotency outcome of %s: %v", requestId, err)
+	}
+}
+
+// existingRecord loads the idempotency key that won over this request, wrapped as an error for submit.
+func (os *OrderService) existingRecord(requestId string) error {
+	key, exists, err := os.idempotencyRepository.Fetch(os.postgresDB, requestId)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		// the other request failed before persisting and freed the key
+		return ErrRequestInProgress
+	}
+
+	record := &IdempotencyRecord{Fingerprint: key.Fingerprint, State: key.State, Error: key.Error}
+	if key.Response != "" {
+		var orderResponse response.OrderResponse
+		if err := json.Unmarshal([]byte(key.Response), &orderResponse); err != nil {
+			return err
+		}
+		record.Response = &orderResponse
+	}
+	if record.State != IdempotencyStateInFlight {
+		if err := os.redisService.Set(requestId, *record); err != nil {
+			log.Printf("Failed to cache idempotency outcome of %s: %v", requestId, err)
+		}
+	}
+	return &duplicateRequestError{record: record}
+}
+
+func replay(record *IdempotencyRecord, fingerprint string) (response.OrderResponse, error) {
+	if record.Fingerprint != fingerprint {
+		return response.OrderResponse{}, ErrIdempotencyKeyMismatch
+	}
+	switch record.State {
+	case IdempotencyStateInFlight:
+		return response.OrderResponse{}, ErrRequestInProgress
+	case IdempotencyStateFailed:
+		return response.OrderResponse{}, restoreError(record.Error)
+	}
+	if record.Response == nil {
+		return response.OrderResponse{}, errors.New("idempotency record without response")
+	}
+	return *record.Response, nil
+}
+
+// fingerprintOf identifies the payload sent under a request id.
+func fingerprintOf(request request.OrderRequest) (string, error) {
+	data, err := json.Marshal(request)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// checkOwner asks the payment-service for the account with the token of the caller and compares its owner to the subject.
+func (os *OrderService) checkOwner(claims *auth.Claims, accountId string) error {
+	if claims == nil {
+		return nil
+	}
+	account, statusCode, err := os.paymentClient.GetAccount(claims, accountId)
+	if err != nil {
+		return err
+	}
+	switch statusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return ErrAccountNotFound
+	case http.StatusForbidden:
+		return ErrAccountNotOwned
+	default:
+		return fmt.Errorf("account lookup failed with status %d", statusCode)
+	}
+	if account.CustomerId != claims.Subject {
+		return ErrAccountNotOwned
+	}
+	return nil
+}
+
+// checkOrderOwner hides orders on accounts of other customers from the caller of claims, they are reported as not found
+// so their ids can not be probed.
+func (os *OrderService) checkOrderOwner(claims *auth.Claims, order *model.ProductOrder, exists bool) error {
+	if !exists {
+		return ErrOrderNotFound
+	}
+	err := os.checkOwner(claims, order.AccountId)
+	if errors.Is(err, ErrAccountNotOwned) || errors.Is(err, ErrAccountNotFound) {
+		return ErrOrderNotFound
+	}
+	return err
+}
+
+// StartWorkers runs the sagas of orders accepted in async mode until the context is cancelled.
+func (os *OrderService) StartWorkers(ctx context.Context) {
+	for i := 0; i < os.conf.OrderWorkerCount; i++ {
+		go func() {
+			for {
+				select {
+				case job := <-os.jobs:
+					<-os.slots
+					// the orchestration may have expired while the order was queued
+					current, exists, err := os.orderRepository.Fetch(os.postgresDB, job.order.ProductOrderId)
+					if err != nil || !exists || current.Status != model.OrderStatusPending {
+						log.Printf("Order %s is no longer pending, skipping", job.order.ProductOrderId)
+						continue
+					}
+					if _, err := os.process(job.order, job.claims); err != nil {
+						log.Printf("Order %s failed: %v", job.order.ProductOrderId, err)
+					}
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+}
+
+// process reserves stock, charges the account and confirms a pending order.
+// Any failure moves the order to ROLLING_BACK and hands it over to the orchestrator for compensation.
+// The payment is made on behalf of the caller of claims, the payment-service checks the account owner again.
+func (os *OrderService) process(orderEntity model.ProductOrder, claims *auth.Claims) (model.ProductOrder, error) {
+	reservationRequest := client.ReservationRequest{RequestId: orderEntity.RequestId}
+	for _, item := range orderEntity.Items {
+		reservationRequest.Items = append(reservationRequest.Items, client.ReservationItemRequest{
+			ProductId: item.ProductId,
+			Quantity:  item.Quantity,
+		})
+	}
+	statusCode, err := os.inventoryClient.Reserve(reservationRequest)
+	if err != nil {
+		os.rollback(orderEntity)
+		return orderEntity, err
+	}
+
+	if statusCode == http.StatusConflict {
+		os.rollback(orderEntity)
+		return orderEntity, ErrOutOfStock
+	}
+
+	if statusCode != http.StatusOK {
+		os.rollback(orderEntity)
+		return orderEntity, fmt.Errorf("stock reservation failed with status %d", statusCode)
+	}
+
+	paymentUuid := uuid.NewV4().String()
+	paymentRequest := paymentapi.PaymentRequest{
+		RequestId: orderEntity.RequestId,
+		Uuid:      paymentUuid,
+		OrderId:   orderEntity.ProductOrderId,
+		AccountId: orderEntity.AccountId,
+		Amount:    orderEntity.TotalAmount,
+		Currency:  orderEntity.Currency,
+	}
+	statusCode, err = os.paymentClient.Process(claims, paymentRequest)
+	if err != nil {
+		os.rollback(orderEntity)
+		return orderEntity, err
+	}
+
+	if statusCode != http.StatusOK {
+		os.rollback(orderEntity)
+		return orderEntity, ErrPaymentDeclined
+	}
+
+	err = os.transition(&orderEntity, model.OrderStatusPending, model.OrderStatusPaymentAccepted)
+	if err != nil {
+		os.rollback(orderEntity)
+		return orderEntity, err
+	}
+
+	err = os.transition(&orderEntity, model.OrderStatusPaymentAccepted, model.OrderStatusConfirmed)
+	if err != nil {
+		os.rollback(orderEntity)
+		return orderEntity, err
+	}
+
+	err = os.orchestrationManager.End(orderEntity.RequestId)
+	if err != nil {
+		return orderEntity, err
+	}
+
+	return orderEntity, nil
+}
+
+// transition moves the order to the next status unless it was changed meanwhile, e.g. compensated after the orchestration expired.
+func (os *OrderService) transition(orderEntity *model.ProductOrder, from string, to string) error {
+	updated, err := os.orderRepository.UpdateStatus(os.postgresDB, orderEntity.ProductOrderId, from, to)
+	if err != nil {
+		return err
+	}
+	if !updated {
+		return fmt.Errorf("order %s is no longer %s", orderEntity.ProductOrderId, from)
+	}
+	orderEntity.Status = to
+	os.orderEvents.Publish(*orderEntity)
+	return nil
+}
+
+func (os *OrderService) rollback(orderEntity model.ProductOrder) {
+	updated, err := os.orderRepository.UpdateStatus(os.postgresDB, orderEntity.ProductOrderId, orderEntity.Status, model.OrderStatusRollingBack)
+	if err != nil {
+		log.Printf("Failed to mark order %s as rolling back: %v", orderEntity.ProductOrderId, err)
+	}
+	if updated {
+		orderEntity.Status = model.OrderStatusRollingBack
+		os.orderEvents.Publish(orderEntity)
+	}
+
+	// the orchestration record expires anyway, rolling back right away only speeds up compensation
+	err = os.orchestrationManager.Rollback(orderEntity.RequestId)
+	if err != nil {
+		log.Printf("Failed to start rollback of order %s: %v", orderEntity.ProductOrderId, err)
+	}
+}
+
+// buildOrder snapshots the current product prices into order items and computes the order total.
+// All products of an order have to be priced in the same currency as the order is charged at once.
+func (os *OrderService) buildOrder(tx *gorm.DB, request request.OrderRequest) (model.ProductOrder, error) {
+	productIds := make([]string, 0, len(request.Items))
+	for _, item := range request.Items {
+		productIds = append(productIds, item.ProductId)
+	}
+	products, err := os.productRepository.FetchAll(tx, productIds)
+	if err != nil {
+		return model.ProductOrder{}, err
+	}
+	productsById := make(map[string]model.Product, len(products))
+	for _, product := range products {
+		productsById[product.ProductId] = product
+	}
+
+	order := model.ProductOrder{
+		AccountId: request.AccountID,
+		RequestId: request.RequestId,
+	}
+	for _, item := range request.Items {
+		product, exists := productsById[item.ProductId]
+		if !exists {
+			return model.ProductOrder{}, ErrProductNotFound
 		}
+		if order.Currency == "" {
+			order.Currency = product.Currency
+		}
+		if order.Currency != product.Currency {
+			return model.ProductOrder{}, ErrMixedCurrencies
+		}
+
+		lineTotal := roundAmount(product.Price * float64(item.Quantity))
+		order.Items = append(order.Items, model.ProductOrderItem{
+			ProductId: item.ProductId,
+			Quantity:  item.Quantity,
+			UnitPrice: product.Price,
+			LineTotal: lineTotal,
+		})
+		order.TotalAmount = roundAmount(order.TotalAmount + lineTotal)
+	}
+	return order, nil
+}
+
+func (os *OrderService) Get(claims *auth.Claims, productOrderId string) (response.OrderResponse, error) {
+	order, exists, err := os.orderRepository.Fetch(os.postgresDB, productOrderId)
+	if err != nil {
 		return response.OrderResponse{}, err
 	}
+	if err := os.checkOrderOwner(claims, order, exists); err != nil {
+		return response.OrderResponse{}, err
+	}
+	return toOrderResponse(*order), nil
+}
 
-	err = os.orchestrationManager.End(request.RequestId)
+func (os *OrderService) GetByRequestId(claims *auth.Claims, requestId string) (response.OrderResponse, error) {
+	order, exists, err := os.orderRepository.FetchByRequestId(os.postgresDB, requestId)
 	if err != nil {
 		return response.OrderResponse{}, err
 	}
+	if err := os.checkOrderOwner(claims, order, exists); err != nil {
+		return response.OrderResponse{}, err
+	}
+	return toOrderResponse(*order), nil
+}
 
-	return response.OrderResponse{
-		ProductOrderId: orderEntity.ProductOrderId,
-		CreateDate:     orderEntity.CreateDate,
-		ProductId:      orderEntity.ProductId,
-		AccountId:      orderEntity.AccountId,
+func (os *OrderService) GetStatus(claims *auth.Claims, productOrderId string) (response.OrderStatusResponse, error) {
+	order, exists, err := os.orderRepository.Fetch(os.postgresDB, productOrderId)
+	if err != nil {
+		return response.OrderStatusResponse{}, err
+	}
+	if err := os.checkOrderOwner(claims, order, exists); err != nil {
+		return response.OrderStatusResponse{}, err
+	}
+	return response.OrderStatusResponse{
+		ProductOrderId: order.ProductOrderId,
+		RequestId:      order.RequestId,
+		Status:         order.Status,
+		UpdateDate:     order.UpdateDate,
 	}, nil
 }
 
+// List pages through the orders matching listRequest. Callers with claims only see the orders of an account of their own.
+func (os *OrderService) List(claims *auth.Claims, listRequest request.OrderListRequest) (response.OrderPageResponse, error) {
+	if claims != nil && listRequest.AccountId == "" {
+		return response.OrderPageResponse{}, ErrAccountIdRequired
+	}
+	if err := os.checkOwner(claims, listRequest.AccountId); err != nil {
+		return response.OrderPageResponse{}, err
+	}
+	cursor, err := pagination.Decode(listRequest.Cursor)
+	if err != nil {
+		return response.OrderPageResponse{}, err
+	}
+
+	limit := pagination.Limit(listRequest.Limit)
+	// one extra row tells whether there is a next page
+	orders, err := os.orderRepository.FetchAll(os.postgresDB, repository.OrderFilter{
+		AccountId: listRequest.AccountId,
+		ProductId: listRequest.ProductId,
+		Status:    listRequest.Status,
+		From:      listRequest.From,
+		To:        listRequest.To,
+		Cursor:    cursor,
+		Limit:     limit + 1,
+	})
+	if err != nil {
+		return response.OrderPageResponse{}, err
+	}
+
+	page := response.OrderPageResponse{Items: []response.OrderResponse{}}
+	if len(orders) > limit {
+		orders = orders[:limit]
+		last := orders[limit-1]
+		page.NextCursor = pagination.Cursor{CreateDate: last.CreateDate, Id: last.ProductOrderId}.Encode()
+	}
+	for _, order := range orders {
+		page.Items = append(page.Items, toOrderResponse(order))
+	}
+	return page, nil
+}
+
+func toOrderResponse(order model.ProductOrder) response.OrderResponse {
+	items := make([]response.OrderItemResponse, 0, len(order.Items))
+	for _, item := range order.Items {
+		items = append(items, response.OrderItemResponse{
+			ProductId: item.ProductId,
+			Quantity:  item.Quantity,
+			UnitPrice: item.UnitPrice,
+			LineTotal: item.LineTotal,
+		})
+	}
+	return response.OrderResponse{
+		ProductOrderId: order.ProductOrderId,
+		RequestId:      order.RequestId,
+		Status:         order.Status,
+		CreateDate:     order.CreateDate,
+		AccountId:      order.AccountId,
+		TotalAmount:    order.TotalAmount,
+		Currency:       order.Currency,
+		Items:          items,
+	}
+}
+
+func roundAmount(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
 func (os *OrderService) getDbConnection() *gorm.DB {
 	tx := os.postgresDB.Begin()
 	defer func() {
@@ -0,0 +1,359 @@
+package service
+
+import (
+	"common/pagination"
+	"context"
+	"fmt"
+	uuid "github.com/satori/go.uuid"
+	"gorm.io/gorm"
+	"log"
+	"math"
+	"order-service/client"
+	"order-service/client/paymentapi"
+	"order-service/configs"
+	"order-service/dto/request"
+	"order-service/dto/response"
+	"order-service/model"
+	"order-service/orchestration"
+	"order-service/repository"
+	"sync"
+	"time"
+)
+
+// amounts are numeric in postgres but float64 here, differences below a cent are rounding
+const amountTolerance = 0.005
+
+// orders are read from the database in pages of this size
+const reconciliationBatchSize = 500
+
+type ReconciliationServiceInterface interface {
+	Run(ctx context.Context) (response.ReconciliationRunResponse, error)
+	List(listRequest request.FindingListRequest) (response.FindingPageResponse, error)
+	Get(findingId string) (response.FindingResponse, error)
+}
+
+type ReconciliationService struct {
+	conf                     *configs.Config
+	postgresDB               *gorm.DB
+	orderRepository          *repository.OrderRepository
+	reconciliationRepository *repository.ReconciliationRepository
+	paymentClient            client.PaymentClientInterface
+	orchestrationManager     *orchestration.Manager
+	// one run at a time, scheduled and on demand runs would only duplicate the work
+	running sync.Mutex
+}
+
+func NewReconciliationService(
+	config *configs.Config,
+	postgresDB *gorm.DB,
+	orderRepository *repository.OrderRepository,
+	reconciliationRepository *repository.ReconciliationRepository,
+	paymentClient client.PaymentClientInterface,
+	orchestrationManager *orchestration.Manager) *ReconciliationService {
+	return &ReconciliationService{
+		conf:                     config,
+		postgresDB:               postgresDB,
+		orderRepository:          orderRepository,
+		reconciliationRepository: reconciliationRepository,
+		paymentClient:            paymentClient,
+		orchestrationManager:     orchestrationManager,
+	}
+}
+
+// StartScheduler runs the reconciliation every period until ctx is done.
+func (rs *ReconciliationService) StartScheduler(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(time.Duration(rs.conf.ReconciliationPeriodSeconds) * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				result, err := rs.Run(ctx)
+				if err != nil {
+					log.Printf("Reconciliation failed: %v", err)
+					continue
+				}
+				log.Printf("Reconciliation checked %d requests, %d findings, %d compensations, %d resolved",
+					result.Checked, len(result.Findings), result.Compensations, result.Resolved)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// ledger is the payment side of a request, amounts are in the order currency.
+type ledger struct {
+	charges       int
+	refunds       int
+	chargedAmount float64
+}
+
+func (l *ledger) add(transaction paymentapi.Transaction) {
+	switch transaction.Type {
+	case paymentapi.TransactionTypeCharge:
+		l.charges++
+		l.chargedAmount += transaction.OriginalAmount
+	case paymentapi.TransactionTypeRefund:
+		l.refunds++
+		l.chargedAmount -= transaction.OriginalAmount
+	}
+}
+
+// Run compares the orders and payment transactions created within the window by request id.
+// Requests that look off in the window are checked again with all of their records, so a refund or an order just
+// outside the window does not count as a mismatch. Records younger than the grace period still belong to a running saga.
+// Orphaned charges are compensated through the orchestrator, which refunds them like any other rollback,
+// running it again for a request that is being compensated already is harmless.
+func (rs *ReconciliationService) Run(ctx context.Context) (response.ReconciliationRunResponse, error) {
+	rs.running.Lock()
+	defer rs.running.Unlock()
+
+	to := time.Now().Add(-time.Duration(rs.conf.ReconciliationGraceSeconds) * time.Second)
+	from := to.Add(-time.Duration(rs.conf.ReconciliationWindowSeconds) * time.Second)
+	result := response.ReconciliationRunResponse{From: from, To: to, Findings: []response.FindingResponse{}}
+
+	ledgers := map[string]*ledger{}
+	cursor := ""
+	for {
+		page, err := rs.paymentClient.ListTransactions(from, to, cursor)
+		if err != nil {
+			return result, err
+		}
+		for _, transaction := range page.Items {
+			entry, ok := ledgers[transaction.RequestId]
+			if !ok {
+				entry = &ledger{}
+				ledgers[transaction.RequestId] = entry
+			}
+			entry.add(transaction)
+			result.Transactions++
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+
+	candidates := map[string]bool{}
+	ordered := map[string]bool{}
+	var orderCursor *pagination.Cursor
+	for {
+		orders, err := rs.orderRepository.FetchAll(rs.postgresDB, repository.OrderFilter{
+			From:   &from,
+			To:     &to,
+			Cursor: orderCursor,
+			Limit:  reconciliationBatchSize,
+		})
+		if err != nil {
+			return result, err
+		}
+		for _, order := range orders {
+			result.Orders++
+			ordered[order.RequestId] = true
+			entry := ledgers[order.RequestId]
+			if entry == nil {
+				entry = &ledger{}
+			}
+			if findings, settled := classify(&order, entry); settled && len(findings) > 0 {
+				candidates[order.RequestId] = true
+			}
+		}
+		if len(orders) < reconciliationBatchSize {
+			break
+		}
+		last := orders[len(orders)-1]
+		orderCursor = &pagination.Cursor{CreateDate: last.CreateDate, Id: last.ProductOrderId}
+	}
+	for requestId := range ledgers {
+		if !ordered[requestId] {
+			candidates[requestId] = true
+		}
+	}
+
+	openFindings, err := rs.reconciliationRepository.FetchOpen(rs.postgresDB)
+	if err != nil {
+		return result, err
+	}
+	open := map[string][]model.ReconciliationFinding{}
+	for _, finding := range openFindings {
+		open[finding.RequestId] = append(open[finding.RequestId], finding)
+		candidates[finding.RequestId] = true
+	}
+
+	for requestId := range candidates {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		result.Checked++
+		if err := rs.reconcile(requestId, open[requestId], &result); err != nil {
+			log.Printf("Reconciliation of %s failed: %v", requestId, err)
+		}
+	}
+	return result, nil
+}
+
+// reconcile checks a single request against all of its records and updates its findings.
+func (rs *ReconciliationService) reconcile(requestId string, open []model.ReconciliationFinding, result *response.ReconciliationRunResponse) error {
+	order, exists, err := rs.orderRepository.FetchByRequestId(rs.postgresDB, requestId)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		order = nil
+	}
+	transactions, err := rs.paymentClient.GetTransactionsByRequestId(requestId)
+	if err != nil {
+		return err
+	}
+	entry := &ledger{}
+	currency := ""
+	for _, transaction := range transactions {
+		entry.add(transaction)
+		currency = transaction.OriginalCurrency
+	}
+
+	findings, settled := classify(order, entry)
+	if !settled {
+		return nil
+	}
+
+	nowTime := time.Now()
+	found := map[string]bool{}
+	for _, finding := range findings {
+		found[finding.Kind] = true
+		finding.FindingId = uuid.NewV4().String()
+		finding.RequestId = requestId
+		finding.Status = model.FindingStatusOpen
+		finding.FirstSeenDate = nowTime
+		finding.LastSeenDate = nowTime
+		if finding.Currency == "" {
+			finding.Currency = currency
+		}
+
+		if finding.Kind == model.FindingKindOrphanedCharge && rs.conf.ReconciliationAutoCompensate {
+			if err := rs.orchestrationManager.Rollback(requestId); err != nil {
+				log.Printf("Failed to trigger compensation of %s: %v", requestId, err)
+			} else {
+				finding.CompensationTriggered = true
+				result.Compensations++
+			}
+		}
+		if err := rs.reconciliationRepository.Upsert(rs.postgresDB, &finding); err != nil {
+			return err
+		}
+		result.Findings = append(result.Findings, toFindingResponse(finding))
+	}
+
+	for _, finding := range open {
+		if found[finding.Kind] {
+			continue
+		}
+		if err := rs.reconciliationRepository.Resolve(rs.postgresDB, finding.FindingId); err != nil {
+			return err
+		}
+		result.Resolved++
+	}
+	return nil
+}
+
+// classify returns the mismatches of a request, settled is false while its order is still mid-saga.
+func classify(order *model.ProductOrder, entry *ledger) ([]model.ReconciliationFinding, bool) {
+	finding := model.ReconciliationFinding{ChargedAmount: entry.chargedAmount}
+	if order != nil {
+		finding.OrderStatus = order.Status
+		finding.OrderAmount = order.TotalAmount
+		finding.Currency = order.Currency
+	}
+
+	if order == nil || order.Status == model.OrderStatusCompensated {
+		if entry.charges <= entry.refunds {
+			return nil, true
+		}
+		finding.Kind = model.FindingKindOrphanedCharge
+		if order == nil {
+			finding.Detail = fmt.Sprintf("%d charges and %d refunds without an order", entry.charges, entry.refunds)
+		} else {
+			finding.Detail = fmt.Sprintf("%d charges and %d refunds for a compensated order", entry.charges, entry.refunds)
+		}
+		return []model.ReconciliationFinding{finding}, true
+	}
+
+	if order.Status != model.OrderStatusConfirmed {
+		return nil, false
+	}
+	switch {
+	case entry.charges == 0:
+		finding.Kind = model.FindingKindMissingCharge
+		finding.Detail = "confirmed order was never charged"
+	case entry.refunds > 0:
+		finding.Kind = model.FindingKindRefundedOrder
+		finding.Detail = fmt.Sprintf("confirmed order has %d charges and %d refunds", entry.charges, entry.refunds)
+	case math.Abs(entry.chargedAmount-order.TotalAmount) > amountTolerance:
+		finding.Kind = model.FindingKindAmountMismatch
+		finding.Detail = fmt.Sprintf("confirmed order of %.2f was charged %.2f", order.TotalAmount, entry.chargedAmount)
+	default:
+		return nil, true
+	}
+	return []model.ReconciliationFinding{finding}, true
+}
+
+func (rs *ReconciliationService) List(listRequest request.FindingListRequest) (response.FindingPageResponse, error) {
+	cursor, err := pagination.Decode(listRequest.Cursor)
+	if err != nil {
+		return response.FindingPageResponse{}, err
+	}
+
+	limit := pagination.Limit(listRequest.Limit)
+	// one extra row tells whether there is a next page
+	findings, err := rs.reconciliationRepository.FetchAll(rs.postgresDB, repository.FindingFilter{
+		Status:    listRequest.Status,
+		Kind:      listRequest.Kind,
+		RequestId: listRequest.RequestId,
+		Cursor:    cursor,
+		Limit:     limit + 1,
+	})
+	if err != nil {
+		return response.FindingPageResponse{}, err
+	}
+
+	page := response.FindingPageResponse{Items: []response.FindingResponse{}}
+	if len(findings) > limit {
+		findings = findings[:limit]
+		last := findings[limit-1]
+		page.NextCursor = pagination.Cursor{CreateDate: last.FirstSeenDate, Id: last.FindingId}.Encode()
+	}
+	for _, finding := range findings {
+		page.Items = append(page.Items, toFindingResponse(finding))
+	}
+	return page, nil
+}
+
+func (rs *ReconciliationService) Get(findingId string) (response.FindingResponse, error) {
+	finding, exists, err := rs.reconciliationRepository.Fetch(rs.postgresDB, findingId)
+	if err != nil {
+		return response.FindingResponse{}, err
+	}
+	if !exists {
+		return response.FindingResponse{}, ErrFindingNotFound
+	}
+	return toFindingResponse(*finding), nil
+}
+
+func toFindingResponse(finding model.ReconciliationFinding) response.FindingResponse {
+	return response.FindingResponse{
+		FindingId:             finding.FindingId,
+		RequestId:             finding.RequestId,
+		Kind:                  finding.Kind,
+		Status:                finding.Status,
+		OrderStatus:           finding.OrderStatus,
+		OrderAmount:           finding.OrderAmount,
+		ChargedAmount:         finding.ChargedAmount,
+		Currency:              finding.Currency,
+		Detail:                finding.Detail,
+		CompensationTriggered: finding.CompensationTriggered,
+		FirstSeenDate:         finding.FirstSeenDate,
+		LastSeenDate:          finding.LastSeenDate,
+		ResolvedDate:          finding.ResolvedDate,
+	}
+}
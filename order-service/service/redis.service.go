@@ -2,33 +2,62 @@ package service
 
 import (
 	"context"
+	"encoding/json"
+	"order-service/dto/response"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 )
 
+const (
+	IdempotencyStateInFlight  = "IN_FLIGHT"
+	IdempotencyStateSucceeded = "SUCCEEDED"
+	IdempotencyStateFailed    = "FAILED"
+)
+
+// IdempotencyRecord remembers how a request id was handled, so duplicates can be answered the same way.
+type IdempotencyRecord struct {
+	Fingerprint string                  `json:"fingerprint"`
+	State       string                  `json:"state"`
+	Response    *response.OrderResponse `json:"response,omitempty"`
+	Error       string                  `json:"error,omitempty"`
+}
+
 type RedisServiceInterface interface {
-	IdempotencyValidation(key string) (bool, error)
+	Get(key string) (*IdempotencyRecord, bool, error)
+	Set(key string, record IdempotencyRecord) error
 }
 
+// RedisService caches finished idempotency records in front of postgres.
 type RedisService struct {
 	redisClient *redis.Client
+	ttl         time.Duration
 }
 
-func NewRedisService(redisClient *redis.Client) *RedisService {
-	return &RedisService{redisClient: redisClient}
+func NewRedisService(redisClient *redis.Client, ttl time.Duration) *RedisService {
+	return &RedisService{redisClient: redisClient, ttl: ttl}
 }
 
-func (red *RedisService) IdempotencyValidation(key string) (bool, error) {
-	if key == "" {
-		return false, nil
+func (red *RedisService) Get(key string) (*IdempotencyRecord, bool, error) {
+	data, err := red.redisClient.Get(context.Background(), key).Result()
+	if err == redis.Nil {
+		return nil, false, nil
 	}
-
-	ctx := context.Background()
-	result, err := red.redisClient.SetNX(ctx, key, "processed", 5*time.Minute).Result()
 	if err != nil {
-		return false, err
+		return nil, false, err
 	}
 
-	return result, nil
+	var record IdempotencyRecord
+	if err := json.Unmarshal([]byte(data), &record); err != nil {
+		return nil, false, err
+	}
+	return &record, true, nil
+}
+
+func (red *RedisService) Set(key string, record IdempotencyRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return red.redisClient.Set(context.Background(), key, data, red.ttl).Err()
 }
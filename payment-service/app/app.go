@@ -0,0 +1,108 @@
+package app
+
+import (
+	"common/apispec"
+	"common/auth"
+	"common/broker"
+	"common/fault"
+	"common/paymentpb"
+	"common/signing"
+	"context"
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"google.golang.org/grpc"
+	"gorm.io/gorm"
+	"log"
+	"payment-service/configs"
+	"payment-service/handler"
+	"payment-service/orchestration"
+	"payment-service/repository"
+	"payment-service/route"
+	"payment-service/service"
+	"time"
+)
+
+// Dependencies are the connections the payment-service runs on, main dials the real ones
+// and the end-to-end tests pass in-process stand-ins.
+type Dependencies struct {
+	DB     *gorm.DB
+	Redis  *redis.Client
+	Broker broker.Broker
+	// verifies the tokens of customers, nil disables auth
+	Verifier *auth.Verifier
+	// verifies the signatures of the order-service, nil disables the check
+	Signatures *signing.Verifier
+	// verifies the consumed rollback messages, nil accepts unsigned ones
+	MessageVerifier *signing.MessageVerifier
+	// OpenAPI document checking the traffic and served at /openapi.json, nil serves neither
+	Spec *apispec.Spec
+}
+
+// App is the wired payment-service, Start runs its rollback consumer, Router serves its REST API and GrpcServer its gRPC API.
+type App struct {
+	Router           *gin.Engine
+	GrpcServer       *grpc.Server
+	rollbackConsumer *orchestration.RollbackConsumer
+}
+
+func New(cfg *configs.Config, deps Dependencies) *App {
+	accountRepository := repository.NewAccountRepository()
+	transactionRepository := repository.NewTransactionRepository()
+	fxRateRepository := repository.NewFxRateRepository()
+	idempotencyRepository := repository.NewIdempotencyRepository()
+	settlementRepository := repository.NewSettlementRepository()
+
+	redisService := service.NewRedisService(deps.Redis, time.Duration(cfg.IdempotencyKeyTTLSeconds)*time.Second)
+	fxService := service.NewFxService(fxRateRepository)
+	paymentService := service.NewPaymentService(deps.DB, accountRepository, transactionRepository, redisService, fxService, idempotencyRepository, settlementRepository)
+
+	// initialize handlers
+	paymentController := handler.NewPaymentHandler(deps.DB, paymentService, cfg)
+	validate := func(ctx *gin.Context) { ctx.Next() }
+	if deps.Spec != nil {
+		validate = deps.Spec.Validate()
+	}
+	paymentRouteController := route.NewPaymentRouteHandler(paymentController, auth.Authenticate(deps.Verifier), handler.VerifySignature(deps.Signatures), handler.VerifySignatureOrRole(deps.Signatures, deps.Verifier, auth.RoleSupport, auth.RoleAdmin), validate)
+
+	server := gin.Default()
+	corsConfig := cors.DefaultConfig()
+	corsConfig.AllowOrigins = []string{cfg.ClientOrigin}
+	corsConfig.AllowCredentials = true
+
+	server.Use(cors.New(corsConfig))
+	if fault.Enabled {
+		server.Any("/api/admin/faults", gin.WrapH(fault.Handler()))
+	}
+
+	if deps.Spec != nil {
+		deps.Spec.Serve(server)
+	}
+
+	router := server.Group("/api")
+	paymentRouteController.PaymentRoute(router)
+
+	// the same checks as on the REST routes, signatures first so forged calls never reach the token verification
+	grpcServer := grpc.NewServer(grpc.ChainUnaryInterceptor(
+		handler.LogCalls,
+		handler.RejectExpiredCalls,
+		handler.VerifyCallSignature(deps.Signatures),
+		handler.AuthenticateCall(deps.Verifier),
+	))
+	paymentpb.RegisterPaymentServiceServer(grpcServer, handler.NewPaymentGrpcHandler(paymentService))
+
+	return &App{
+		Router:           server,
+		GrpcServer:       grpcServer,
+		rollbackConsumer: orchestration.NewRollbackConsumer(cfg, deps.Broker, paymentService, deps.MessageVerifier),
+	}
+}
+
+// Start runs the rollback consumer until ctx is done.
+func (a *App) Start(ctx context.Context) error {
+	if err := a.rollbackConsumer.Consume(ctx); err != nil {
+		return err
+	}
+	log.Println("Rollback consumer started")
+	return nil
+}
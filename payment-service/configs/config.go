@@ -10,9 +10,18 @@ type Config struct {
 	DBName         string `mapstructure:"POSTGRES_NAME"`
 	DBPort         string `mapstructure:"POSTGRES_PORT"`
 
+	// applies pending migrations before the server starts, otherwise run the migrate subcommand
+	MigrateOnStartup bool `mapstructure:"MIGRATE_ON_STARTUP"`
+
 	// app config
 	ServerPort   string `mapstructure:"SERVER_PORT"`
 	ClientOrigin string `mapstructure:"CLIENT_ORIGIN"`
+	// port of the gRPC API served next to the REST API, empty serves REST only
+	GrpcPort string `mapstructure:"GRPC_PORT"`
+
+	// broker config, rabbitmq, nats or memory, the RMQ_ queue names are used on every broker
+	Broker  string `mapstructure:"BROKER"`
+	NatsUrl string `mapstructure:"NATS_URL"`
 
 	// rmq config
 	RMQUrl         string `mapstructure:"RMQ_URL"`
@@ -26,6 +35,35 @@ type Config struct {
 	RedisHost string `mapstructure:"REDIS_HOST"`
 	RedisPort string `mapstructure:"REDIS_PORT"`
 	RedisDb   string `mapstructure:"REDIS_DB"`
+	// how long processed payment uuids stay cached, postgres keeps them regardless
+	IdempotencyKeyTTLSeconds int64 `mapstructure:"IDEMPOTENCY_KEY_TTL_SECONDS"`
+
+	// jwt auth of customers, HS256 verifies with the secret in the key file, RS256 with the PEM public key in it
+	// or the keys of the JWKS file, issuer and audience are checked when set
+	AuthEnabled   bool   `mapstructure:"AUTH_ENABLED"`
+	AuthAlgorithm string `mapstructure:"AUTH_ALGORITHM"`
+	AuthKeyFile   string `mapstructure:"AUTH_KEY_FILE"`
+	AuthJWKSFile  string `mapstructure:"AUTH_JWKS_FILE"`
+	AuthIssuer    string `mapstructure:"AUTH_ISSUER"`
+	AuthAudience  string `mapstructure:"AUTH_AUDIENCE"`
+
+	// keys the order-service may sign with as keyId:secret pairs separated by commas, more than one while a key is rotated.
+	// Signatures older or newer than the skew are rejected, nonces are remembered in redis for twice as long.
+	ServiceSigningKeys           string `mapstructure:"SERVICE_SIGNING_KEYS"`
+	ServiceSigningMaxSkewSeconds int64  `mapstructure:"SERVICE_SIGNING_MAX_SKEW_SECONDS"`
+
+	// rollback messages have to be signed with one of these keyId:secret pairs separated by commas,
+	// unsigned and forged ones are moved to the quarantine queue
+	MessageSigningKeys string `mapstructure:"MESSAGE_SIGNING_KEYS"`
+	RMQQuarantineQueue string `mapstructure:"RMQ_QUARANTINE_QUEUE"`
+	// rollbacks failing twice are parked here to be replayed with orchestrationctl
+	RMQDeadLetterQueue string `mapstructure:"RMQ_DEAD_LETTER_QUEUE"`
+
+	// responses checked against the OpenAPI spec: off, log or strict, which answers 500 instead of a broken response
+	OpenAPIResponseValidation string `mapstructure:"OPENAPI_RESPONSE_VALIDATION"`
+
+	// faults armed at startup, point=kind[:arg][*count] separated by commas, only honoured by -tags faults builds
+	Faults string `mapstructure:"FAULTS"`
 }
 
 func LoadConfig(path string) (config Config, err error) {
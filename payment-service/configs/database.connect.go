@@ -14,7 +14,7 @@ func ConnectToDB(config *Config) (*gorm.DB, error) {
 	connection := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s",
 		config.DBHost, config.DBUsername, config.DBUserPassword, config.DBName, config.DBPort)
 
-	DB, err = gorm.Open(postgres.Open(connection), &gorm.Config{})
+	DB, err = gorm.Open(postgres.Open(connection), &gorm.Config{TranslateError: true})
 	if err != nil {
 		log.Println("Failed to connect to postgres")
 		return nil, err
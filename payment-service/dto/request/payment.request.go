@@ -1,9 +1,10 @@
 package request
 
 type PaymentRequest struct {
-	RequestID string  `json:"requestId"`
-	UUID      string  `json:"uuid"`
-	ProductId string  `json:"productId" binding:"required"`
-	Amount    float64 `json:"amount"`
-	AccountID string  `json:"accountId"`
+	RequestID string  `json:"requestId" validate:"required,uuid"`
+	UUID      string  `json:"uuid" validate:"required,uuid"`
+	OrderId   string  `json:"orderId" validate:"required,max=512"`
+	Amount    float64 `json:"amount" validate:"gt=0"`
+	Currency  string  `json:"currency" validate:"required,iso4217"`
+	AccountID string  `json:"accountId" validate:"required,uuid"`
 }
@@ -0,0 +1,11 @@
+package request
+
+import "time"
+
+type TransactionListRequest struct {
+	Type   string     `form:"type" validate:"omitempty,oneof=CHARGE REFUND"`
+	From   *time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To     *time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	Cursor string     `form:"cursor" validate:"max=512"`
+	Limit  int        `form:"limit" validate:"gte=0"`
+}
@@ -0,0 +1,11 @@
+package response
+
+import "time"
+
+type AccountResponse struct {
+	AccountId  string
+	CustomerId string
+	Amount     float64
+	Currency   string
+	UpdateDate time.Time
+}
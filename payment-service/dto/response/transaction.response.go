@@ -0,0 +1,22 @@
+package response
+
+import "time"
+
+type TransactionResponse struct {
+	TransactionId    string
+	RequestId        string
+	AccountId        string
+	OrderId          string
+	Type             string
+	Amount           float64
+	Currency         string
+	OriginalAmount   float64
+	OriginalCurrency string
+	FxRate           float64
+	CreateDate       time.Time
+}
+
+type TransactionPageResponse struct {
+	Items      []TransactionResponse
+	NextCursor string
+}
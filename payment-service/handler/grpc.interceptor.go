@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"common/auth"
+	"common/paymentpb"
+	"common/signing"
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type grpcClaimsKey struct{}
+
+// customer facing methods, their calls forward the token of the customer
+var customerMethods = map[string]bool{
+	paymentpb.PaymentService_ProcessPayment_FullMethodName: true,
+	paymentpb.PaymentService_GetAccount_FullMethodName:     true,
+}
+
+// LogCalls logs every call with its status code and duration, like gin does for the REST API.
+func LogCalls(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	requestId := "-"
+	if md, ok := metadata.FromIncomingContext(ctx); ok && len(md.Get(paymentpb.MetadataRequestId)) > 0 {
+		requestId = md.Get(paymentpb.MetadataRequestId)[0]
+	}
+	log.Printf("[GRPC] %s | %13v | %s | request %s", status.Code(err), time.Since(start), info.FullMethod, requestId)
+	return resp, err
+}
+
+// RejectExpiredCalls fails calls whose deadline passed or whose caller went away before they are handled,
+// the work would be done for nobody.
+func RejectExpiredCalls(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, status.FromContextError(err).Err()
+	}
+	return handler(ctx, req)
+}
+
+// VerifyCallSignature only lets calls signed with a service key through, every method of the gRPC API is internal.
+// Without a verifier signatures are not checked.
+func VerifyCallSignature(verifier *signing.Verifier) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if verifier == nil {
+			return handler(ctx, req)
+		}
+		if err := verifier.VerifyCall(ctx, info.FullMethod, req); err != nil {
+			for _, rejection := range signatureErrors {
+				if errors.Is(err, rejection) {
+					return nil, status.Error(codes.Unauthenticated, err.Error())
+				}
+			}
+			// replays can not be ruled out while the nonce cache is down, so the call is refused
+			log.Printf("Failed to verify signature: %v", err)
+			return nil, status.Error(codes.Unavailable, "signature could not be verified")
+		}
+		return handler(ctx, req)
+	}
+}
+
+// AuthenticateCall rejects calls of customer facing methods without a valid bearer token in the authorization metadata
+// and hands the claims on to the handler. Without a verifier auth is disabled and every call passes anonymously.
+func AuthenticateCall(verifier *auth.Verifier) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if verifier == nil || !customerMethods[info.FullMethod] {
+			return handler(ctx, req)
+		}
+		header := ""
+		if values := metadata.ValueFromIncomingContext(ctx, paymentpb.MetadataAuthorization); len(values) > 0 {
+			header = values[0]
+		}
+		claims, err := verifier.VerifyHeader(header)
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+		return handler(context.WithValue(ctx, grpcClaimsKey{}, claims), req)
+	}
+}
+
+// grpcClaimsOf returns the claims of the caller, nil while auth is disabled.
+func grpcClaimsOf(ctx context.Context) *auth.Claims {
+	claims, _ := ctx.Value(grpcClaimsKey{}).(*auth.Claims)
+	return claims
+}
@@ -0,0 +1,236 @@
+package handler
+
+import (
+	"common/pagination"
+	"common/paymentpb"
+	"common/validation"
+	"context"
+	"errors"
+	"log"
+	"payment-service/dto/request"
+	"payment-service/dto/response"
+	"payment-service/model"
+	"payment-service/service"
+	"strings"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// errorDomain is the domain of the ErrorInfo details of failed calls
+const errorDomain = "payment-service"
+
+// statuses of the domain errors, the reason tells them apart without parsing messages
+var grpcStatuses = []struct {
+	err    error
+	code   codes.Code
+	reason string
+}{
+	{service.ErrAccountNotFound, codes.NotFound, "ACCOUNT_NOT_FOUND"},
+	{service.ErrChargeNotFound, codes.NotFound, "CHARGE_NOT_FOUND"},
+	{service.ErrAccountNotOwned, codes.PermissionDenied, "ACCOUNT_NOT_OWNED"},
+	{service.ErrDuplicatePayment, codes.AlreadyExists, "DUPLICATE_PAYMENT"},
+	{service.ErrPaymentRolledBack, codes.AlreadyExists, "PAYMENT_ROLLED_BACK"},
+	{service.ErrInsufficientBalance, codes.FailedPrecondition, "INSUFFICIENT_BALANCE"},
+	{service.ErrNoFxRate, codes.FailedPrecondition, "NO_FX_RATE"},
+	{service.ErrBalanceConflict, codes.Aborted, "BALANCE_CONFLICT"},
+	{pagination.ErrInvalidCursor, codes.InvalidArgument, "INVALID_CURSOR"},
+}
+
+var transactionTypes = map[paymentpb.TransactionType]string{
+	paymentpb.TransactionType_TRANSACTION_TYPE_UNSPECIFIED: "",
+	paymentpb.TransactionType_TRANSACTION_TYPE_CHARGE:      model.TransactionTypeCharge,
+	paymentpb.TransactionType_TRANSACTION_TYPE_REFUND:      model.TransactionTypeRefund,
+}
+
+type requestIdRequest struct {
+	RequestId string `json:"requestId" validate:"required,uuid"`
+}
+
+type accountRequest struct {
+	AccountId string `json:"accountId" validate:"required,uuid"`
+}
+
+// PaymentGrpcHandler serves the gRPC API of the payment-service with the same service as the REST handlers.
+type PaymentGrpcHandler struct {
+	paymentpb.UnimplementedPaymentServiceServer
+	paymentService *service.PaymentService
+}
+
+func NewPaymentGrpcHandler(paymentService *service.PaymentService) *PaymentGrpcHandler {
+	return &PaymentGrpcHandler{paymentService: paymentService}
+}
+
+func (h *PaymentGrpcHandler) ProcessPayment(ctx context.Context, req *paymentpb.ProcessPaymentRequest) (*paymentpb.ProcessPaymentResponse, error) {
+	paymentRequest := request.PaymentRequest{
+		RequestID: req.GetRequestId(),
+		UUID:      req.GetUuid(),
+		OrderId:   req.GetOrderId(),
+		Amount:    req.GetAmount(),
+		Currency:  req.GetCurrency(),
+		AccountID: req.GetAccountId(),
+	}
+	if err := validation.Struct(paymentRequest); err != nil {
+		return nil, invalidArgument(err)
+	}
+	if err := h.paymentService.ProcessPayment(grpcClaimsOf(ctx), paymentRequest); err != nil {
+		return nil, statusOf(err)
+	}
+
+	transactions, err := h.paymentService.GetTransactionsByRequestId(req.GetRequestId())
+	if err != nil {
+		return nil, statusOf(err)
+	}
+	resp := &paymentpb.ProcessPaymentResponse{}
+	for _, transaction := range transactions {
+		if transaction.Type == model.TransactionTypeCharge {
+			resp.Charge = toPbTransaction(transaction)
+		}
+	}
+	return resp, nil
+}
+
+func (h *PaymentGrpcHandler) GetPaymentByRequest(ctx context.Context, req *paymentpb.GetPaymentByRequestRequest) (*paymentpb.GetPaymentByRequestResponse, error) {
+	if err := validation.Struct(requestIdRequest{RequestId: req.GetRequestId()}); err != nil {
+		return nil, invalidArgument(err)
+	}
+	transactions, err := h.paymentService.GetTransactionsByRequestId(req.GetRequestId())
+	if err != nil {
+		return nil, statusOf(err)
+	}
+	if len(transactions) == 0 {
+		return nil, statusOf(service.ErrChargeNotFound)
+	}
+
+	resp := &paymentpb.GetPaymentByRequestResponse{}
+	for _, transaction := range transactions {
+		resp.Transactions = append(resp.Transactions, toPbTransaction(transaction))
+	}
+	return resp, nil
+}
+
+func (h *PaymentGrpcHandler) Refund(ctx context.Context, req *paymentpb.RefundRequest) (*paymentpb.RefundResponse, error) {
+	if err := validation.Struct(requestIdRequest{RequestId: req.GetRequestId()}); err != nil {
+		return nil, invalidArgument(err)
+	}
+	refund, err := h.paymentService.Refund(req.GetRequestId())
+	if err != nil {
+		return nil, statusOf(err)
+	}
+	return &paymentpb.RefundResponse{Refund: toPbTransaction(refund)}, nil
+}
+
+func (h *PaymentGrpcHandler) GetAccount(ctx context.Context, req *paymentpb.GetAccountRequest) (*paymentpb.Account, error) {
+	if err := validation.Struct(accountRequest{AccountId: req.GetAccountId()}); err != nil {
+		return nil, invalidArgument(err)
+	}
+	account, err := h.paymentService.GetAccount(grpcClaimsOf(ctx), req.GetAccountId())
+	if err != nil {
+		return nil, statusOf(err)
+	}
+	return &paymentpb.Account{
+		AccountId:  account.AccountId,
+		CustomerId: account.CustomerId,
+		Amount:     account.Amount,
+		Currency:   account.Currency,
+		UpdateDate: timestamppb.New(account.UpdateDate),
+	}, nil
+}
+
+func (h *PaymentGrpcHandler) ListTransactions(ctx context.Context, req *paymentpb.ListTransactionsRequest) (*paymentpb.ListTransactionsResponse, error) {
+	listRequest := request.TransactionListRequest{
+		Type:   transactionTypes[req.GetType()],
+		Cursor: req.GetCursor(),
+		Limit:  int(req.GetLimit()),
+	}
+	if req.GetFrom() != nil {
+		from := req.GetFrom().AsTime()
+		listRequest.From = &from
+	}
+	if req.GetTo() != nil {
+		to := req.GetTo().AsTime()
+		listRequest.To = &to
+	}
+	if err := validation.Struct(listRequest); err != nil {
+		return nil, invalidArgument(err)
+	}
+
+	page, err := h.paymentService.ListAllTransactions(listRequest)
+	if err != nil {
+		return nil, statusOf(err)
+	}
+	resp := &paymentpb.ListTransactionsResponse{NextCursor: page.NextCursor}
+	for _, transaction := range page.Items {
+		resp.Items = append(resp.Items, toPbTransaction(transaction))
+	}
+	return resp, nil
+}
+
+func toPbTransaction(transaction response.TransactionResponse) *paymentpb.Transaction {
+	transactionType := paymentpb.TransactionType_TRANSACTION_TYPE_UNSPECIFIED
+	for pbType, name := range transactionTypes {
+		if name != "" && name == transaction.Type {
+			transactionType = pbType
+		}
+	}
+	return &paymentpb.Transaction{
+		TransactionId:    transaction.TransactionId,
+		RequestId:        transaction.RequestId,
+		AccountId:        transaction.AccountId,
+		OrderId:          transaction.OrderId,
+		Type:             transactionType,
+		Amount:           transaction.Amount,
+		Currency:         transaction.Currency,
+		OriginalAmount:   transaction.OriginalAmount,
+		OriginalCurrency: transaction.OriginalCurrency,
+		FxRate:           transaction.FxRate,
+		CreateDate:       timestamppb.New(transaction.CreateDate),
+	}
+}
+
+// invalidArgument reports the field errors of a request as BadRequest details, named as in the REST API.
+func invalidArgument(err error) error {
+	var fieldErrors validation.Errors
+	if !errors.As(err, &fieldErrors) {
+		return statusOf(err)
+	}
+	badRequest := &errdetails.BadRequest{}
+	for _, fieldError := range fieldErrors {
+		badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       fieldError.Field,
+			Description: fieldError.Message,
+			Reason:      strings.ToUpper(fieldError.Rule),
+		})
+	}
+	return withDetails(status.New(codes.InvalidArgument, "invalid request"), badRequest)
+}
+
+// statusOf translates err into a status with an ErrorInfo, unknown errors are logged and reported as internal.
+func statusOf(err error) error {
+	for _, known := range grpcStatuses {
+		if !errors.Is(err, known.err) {
+			continue
+		}
+		details := []protoadapt.MessageV1{&errdetails.ErrorInfo{Reason: known.reason, Domain: errorDomain}}
+		// the payment lost the race for the balance every time, it goes through once the account is left alone
+		if known.code == codes.Aborted {
+			details = append(details, &errdetails.RetryInfo{RetryDelay: durationpb.New(time.Second)})
+		}
+		return withDetails(status.New(known.code, err.Error()), details...)
+	}
+	log.Printf("Failed to handle gRPC call: %v", err)
+	return status.Error(codes.Internal, "internal error")
+}
+
+func withDetails(st *status.Status, details ...protoadapt.MessageV1) error {
+	detailed, err := st.WithDetails(details...)
+	if err != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
@@ -1,6 +1,10 @@
 package handler
 
 import (
+	"common/auth"
+	"common/pagination"
+	"common/validation"
+	"errors"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 	"net/http"
@@ -16,7 +20,11 @@ type PaymentHandler struct {
 }
 
 type PaymentHandlerInterface interface {
-	MakeOrder(ctx *gin.Context)
+	ProcessPayment(ctx *gin.Context)
+	GetAccount(ctx *gin.Context)
+	ListTransactions(ctx *gin.Context)
+	ListAllTransactions(ctx *gin.Context)
+	GetTransactionsByRequestId(ctx *gin.Context)
 }
 
 func NewPaymentHandler(postgresDB *gorm.DB, paymentService *service.PaymentService, config *configs.Config) PaymentHandler {
@@ -29,31 +37,118 @@ func NewPaymentHandler(postgresDB *gorm.DB, paymentService *service.PaymentServi
 
 func (paymentHandler PaymentHandler) ProcessPayment(ctx *gin.Context) {
 	var paymentRequest request.PaymentRequest
-	if err := ctx.ShouldBindJSON(&paymentRequest); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{})
+	if !validation.BindJSON(ctx, &paymentRequest) {
 		return
 	}
 
-	if paymentRequest.RequestID == "" {
-		ctx.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": "wrong orderRequest params, missing request id"})
+	err := paymentHandler.paymentService.ProcessPayment(auth.ClaimsOf(ctx), paymentRequest)
+	if errors.Is(err, service.ErrAccountNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	if errors.Is(err, service.ErrAccountNotOwned) {
+		ctx.JSON(http.StatusForbidden, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	if errors.Is(err, service.ErrDuplicatePayment) || errors.Is(err, service.ErrPaymentRolledBack) {
+		ctx.JSON(http.StatusConflict, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	if errors.Is(err, service.ErrInsufficientBalance) {
+		ctx.JSON(http.StatusPaymentRequired, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	if errors.Is(err, service.ErrNoFxRate) {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	if errors.Is(err, service.ErrBalanceConflict) {
+		ctx.Header("Retry-After", "1")
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{})
 		return
 	}
 
-	if paymentRequest.ProductId == "" {
-		ctx.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": "wrong orderRequest params, missing product"})
+	ctx.JSON(http.StatusOK, gin.H{})
+}
+
+func (paymentHandler PaymentHandler) GetAccount(ctx *gin.Context) {
+	account, err := paymentHandler.paymentService.GetAccount(auth.ClaimsOf(ctx), ctx.Param("id"))
+	if errors.Is(err, service.ErrAccountNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"status": "error", "message": err.Error()})
 		return
 	}
+	if errors.Is(err, service.ErrAccountNotOwned) {
+		ctx.JSON(http.StatusForbidden, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, account)
+}
 
-	if paymentRequest.AccountID == "" {
-		ctx.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": "wrong orderRequest params, missing account"})
+func (paymentHandler PaymentHandler) ListTransactions(ctx *gin.Context) {
+	var listRequest request.TransactionListRequest
+	if !validation.BindQuery(ctx, &listRequest) {
 		return
 	}
 
-	err := paymentHandler.paymentService.ProcessPayment(paymentRequest)
+	page, err := paymentHandler.paymentService.ListTransactions(auth.ClaimsOf(ctx), ctx.Param("id"), listRequest)
+	if errors.Is(err, pagination.ErrInvalidCursor) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	if errors.Is(err, service.ErrAccountNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	if errors.Is(err, service.ErrAccountNotOwned) {
+		ctx.JSON(http.StatusForbidden, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{})
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{})
+	ctx.JSON(http.StatusOK, page)
+}
+
+func (paymentHandler PaymentHandler) ListAllTransactions(ctx *gin.Context) {
+	var listRequest request.TransactionListRequest
+	if !validation.BindQuery(ctx, &listRequest) {
+		return
+	}
+
+	page, err := paymentHandler.paymentService.ListAllTransactions(listRequest)
+	if errors.Is(err, pagination.ErrInvalidCursor) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, page)
+}
+
+func (paymentHandler PaymentHandler) GetTransactionsByRequestId(ctx *gin.Context) {
+	transactions, err := paymentHandler.paymentService.GetTransactionsByRequestId(ctx.Param("requestId"))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+	if len(transactions) == 0 {
+		ctx.JSON(http.StatusNotFound, gin.H{"status": "error", "message": "no transactions for request"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, transactions)
 }
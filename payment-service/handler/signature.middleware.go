@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"bytes"
+	"common/auth"
+	"common/signing"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// rejections of the caller, any other verification error is on our side
+var signatureErrors = []error{
+	signing.ErrMissingSignature,
+	signing.ErrUnknownKey,
+	signing.ErrStaleTimestamp,
+	signing.ErrDigestMismatch,
+	signing.ErrInvalidSignature,
+	signing.ErrReplayed,
+}
+
+// VerifySignature only lets requests signed with a service key through, the body is put back for the handlers.
+// Without a verifier signatures are not checked.
+func VerifySignature(verifier *signing.Verifier) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if verifier == nil {
+			ctx.Next()
+			return
+		}
+		body, err := io.ReadAll(ctx.Request.Body)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{})
+			return
+		}
+		ctx.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		if err := verifier.Verify(ctx.Request, body); err != nil {
+			for _, rejection := range signatureErrors {
+				if errors.Is(err, rejection) {
+					ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"status": "error", "message": err.Error()})
+					return
+				}
+			}
+			// replays can not be ruled out while the nonce cache is down, so the request is refused
+			log.Printf("Failed to verify signature: %v", err)
+			ctx.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{})
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// VerifySignatureOrRole lets requests through that are signed with a service key or carry a token with one of the roles.
+// Requests without a bearer token, or while auth is disabled, have to be signed.
+func VerifySignatureOrRole(signatures *signing.Verifier, tokens *auth.Verifier, roles ...string) gin.HandlerFunc {
+	verifySignature := VerifySignature(signatures)
+	return func(ctx *gin.Context) {
+		if tokens == nil || ctx.GetHeader(signing.HeaderSignature) != "" || ctx.GetHeader("Authorization") == "" {
+			verifySignature(ctx)
+			return
+		}
+		claims, err := tokens.VerifyHeader(ctx.GetHeader("Authorization"))
+		if err != nil {
+			ctx.Header("WWW-Authenticate", "Bearer")
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"status": "error", "message": err.Error()})
+			return
+		}
+		if !claims.HasRole(roles...) {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"status": "error", "message": "missing role " + strings.Join(roles, " or ")})
+			return
+		}
+		auth.SetClaims(ctx, claims)
+		ctx.Next()
+	}
+}
@@ -1,26 +1,24 @@
 package main
 
 import (
+	"common/apispec"
+	"common/auth"
+	"common/broker"
+	"common/fault"
+	commonMigration "common/migration"
+	"common/signing"
 	"context"
-	"github.com/gin-contrib/cors"
-	"github.com/gin-gonic/gin"
 	"github.com/go-redis/redis/v8"
-	"github.com/streadway/amqp"
 	"gorm.io/gorm"
 	"log"
+	"net"
+	"os"
+	"payment-service/app"
 	"payment-service/configs"
-	"payment-service/handler"
-	"payment-service/orchestration"
-	"payment-service/repository"
-	"payment-service/route"
-	"payment-service/service"
+	"payment-service/migration"
+	"payment-service/openapi"
 	"strconv"
-)
-
-var (
-	server                 *gin.Engine
-	PaymentController      handler.PaymentHandler
-	PaymentRouteController route.PaymentRouteHandler
+	"time"
 )
 
 func main() {
@@ -28,88 +26,122 @@ func main() {
 	if err != nil {
 		panic("Could not initialize app")
 	}
+	if err := fault.Configure(config.Faults); err != nil {
+		log.Fatalf("Failed to arm faults: %v", err)
+	}
 
 	postgresDB, err := configs.ConnectToDB(&config)
 	if err != nil {
 		panic("Failed to connect to DB")
 	}
 
-	redisDatabase := initializeRedisCache(config)
-	accountRepository := repository.NewAccountRepository()
-	transactionRepository := repository.NewTransactionRepository()
-
-	redisService := service.NewRedisService(redisDatabase)
-	paymentService := service.NewPaymentService(postgresDB, accountRepository, transactionRepository, redisService)
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrateCommand(postgresDB, os.Args[2:]); err != nil {
+			log.Fatalf("Migrate failed: %v", err)
+		}
+		return
+	}
+	if config.MigrateOnStartup {
+		if err := runMigrateCommand(postgresDB, []string{"up"}); err != nil {
+			log.Fatalf("Failed to migrate DB: %v", err)
+		}
+	}
 
-	// initialize handlers
-	PaymentController = handler.NewPaymentHandler(postgresDB, paymentService, &config)
-	PaymentRouteController = route.NewPaymentRouteHandler(PaymentController)
+	redisDatabase := initializeRedisCache(config)
+	messageBroker := initializeBroker(config)
+	defer messageBroker.Close()
 
-	rmqConn := initializeRabbitMQ(config)
-	defer rmqConn.Close()
-	// Initialize rollback consumer on a separate channel
-	cancelRollbackConsumer := initializeRollbackConsumer(postgresDB, config, rmqConn, transactionRepository, accountRepository)
-	defer cancelRollbackConsumer()
+	_, messageVerifier, err := signing.NewMessageSigning("", "", config.MessageSigningKeys)
+	if err != nil {
+		log.Fatalf("Failed to load message signing keys: %v", err)
+	}
+	if messageVerifier == nil {
+		log.Println("No message signing keys configured, rollback messages are not verified")
+	}
 
-	server = gin.Default()
-	corsConfig := cors.DefaultConfig()
-	corsConfig.AllowOrigins = []string{config.ClientOrigin}
-	corsConfig.AllowCredentials = true
+	paymentApp := app.New(&config, app.Dependencies{
+		DB:              postgresDB,
+		Redis:           redisDatabase,
+		Broker:          messageBroker,
+		Verifier:        initializeVerifier(config),
+		Signatures:      initializeSignatureVerifier(config, redisDatabase),
+		MessageVerifier: messageVerifier,
+		Spec:            initializeSpec(config),
+	})
 
-	server.Use(cors.New(corsConfig))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := paymentApp.Start(ctx); err != nil {
+		log.Fatalf("Failed to start payment-service: %v", err)
+	}
 
-	router := server.Group("/api")
-	PaymentRouteController.PaymentRoute(router)
+	if config.GrpcPort != "" {
+		listener, err := net.Listen("tcp", ":"+config.GrpcPort)
+		if err != nil {
+			log.Fatalf("Failed to listen for gRPC: %v", err)
+		}
+		go func() {
+			log.Fatal(paymentApp.GrpcServer.Serve(listener))
+		}()
+		log.Printf("gRPC API listening on %s", config.GrpcPort)
+	}
 
-	log.Fatal(server.Run(":" + config.ServerPort))
+	log.Fatal(paymentApp.Router.Run(":" + config.ServerPort))
 }
 
-func initializeRabbitMQ(cfg configs.Config) *amqp.Connection {
-	conn, err := amqp.Dial(cfg.RMQUrl)
+func initializeBroker(cfg configs.Config) broker.Broker {
+	messageBroker, err := broker.New(broker.Config{
+		Backend:     cfg.Broker,
+		RabbitMQUrl: cfg.RMQUrl,
+		NatsUrl:     cfg.NatsUrl,
+	})
 	if err != nil {
-		log.Fatalf("Failed to connect to RabbitMQ: %v", err)
+		log.Fatalf("Failed to connect to %s broker: %v", cfg.Broker, err)
 	}
 
-	ch, err := conn.Channel()
-	if err != nil {
-		conn.Close()
-		log.Fatalf("Failed to open a RabbitMQ channel: %v", err)
-	}
-
-	_, err = ch.QueueDeclare(
-		cfg.RMQExpiredEventQueue,
-		true,
-		false,
-		false,
-		false,
-		nil,
-	)
-	if err != nil {
-		ch.Close()
-		conn.Close()
-		log.Fatalf("Failed to declare RabbitMQ queue: %v", err)
+	if err := messageBroker.Declare(cfg.RMQExpiredEventQueue, cfg.RMQRollbackEventPaymentQueue, cfg.RMQQuarantineQueue, cfg.RMQDeadLetterQueue); err != nil {
+		messageBroker.Close()
+		log.Fatalf("Failed to declare queues: %v", err)
 	}
-
-	return conn
+	return messageBroker
 }
 
-func initializeRollbackConsumer(postgresDB *gorm.DB, cfg configs.Config, conn *amqp.Connection, transactionRepository *repository.TransactionRepository, accountRepository *repository.AccountRepository) context.CancelFunc {
-	rollbackChannel, err := conn.Channel()
+func initializeVerifier(cfg configs.Config) *auth.Verifier {
+	if !cfg.AuthEnabled {
+		log.Println("Auth disabled, accounts are not checked against their owners")
+		return nil
+	}
+	verifier, err := auth.NewVerifier(auth.Config{
+		Algorithm: cfg.AuthAlgorithm,
+		KeyFile:   cfg.AuthKeyFile,
+		JWKSFile:  cfg.AuthJWKSFile,
+		Issuer:    cfg.AuthIssuer,
+		Audience:  cfg.AuthAudience,
+	})
 	if err != nil {
-		log.Fatalf("Failed to open channel for rollback consumer: %v", err)
+		log.Fatalf("Failed to load jwt keys: %v", err)
 	}
-	rollbackConsumer := orchestration.NewRollbackConsumer(postgresDB, &cfg, rollbackChannel, accountRepository, transactionRepository)
-	consumerCtx, consumerCancel := context.WithCancel(context.Background())
+	return verifier
+}
 
-	if err := rollbackConsumer.Consume(consumerCtx); err != nil {
-		log.Fatalf("Failed to start rollback consumer: %v", err)
+func initializeSpec(cfg configs.Config) *apispec.Spec {
+	spec, err := apispec.Load(openapi.Spec, cfg.OpenAPIResponseValidation)
+	if err != nil {
+		log.Fatalf("Failed to load OpenAPI spec: %v", err)
 	}
-	log.Println("Rollback consumer started")
+	return spec
+}
 
-	return func() {
-		consumerCancel()
-		rollbackChannel.Close()
+func initializeSignatureVerifier(cfg configs.Config, redisDatabase *redis.Client) *signing.Verifier {
+	keys, err := signing.ParseKeys(cfg.ServiceSigningKeys)
+	if err != nil {
+		log.Fatalf("Failed to load signing keys: %v", err)
 	}
+	if len(keys) == 0 {
+		log.Println("No signing keys configured, payments are accepted from any caller")
+		return nil
+	}
+	return signing.NewVerifier(keys, time.Duration(cfg.ServiceSigningMaxSkewSeconds)*time.Second, signing.NewRedisNonceStore(redisDatabase, "payment-nonce:"))
 }
 
 func initializeRedisCache(config configs.Config) *redis.Client {
@@ -125,11 +157,21 @@ func initializeRedisCache(config configs.Config) *redis.Client {
 
 	// Ping the Redis server to check the connection
 	ctx := context.Background()
+	// redis only caches idempotency keys, so the service starts without it and falls back to postgres
 	pong, err := redisDatabase.Ping(ctx).Result()
 	if err != nil {
-		log.Fatalf("Failed to ping Redis: %v", err)
+		log.Printf("Failed to ping Redis, idempotency cache disabled until it is reachable: %v", err)
+		return redisDatabase
 	}
 	log.Println("Redis ping response:", pong)
 
 	return redisDatabase
 }
+
+func runMigrateCommand(postgresDB *gorm.DB, args []string) error {
+	migrator, err := migration.NewMigrator(postgresDB)
+	if err != nil {
+		return err
+	}
+	return commonMigration.RunCommand(migrator, args, os.Stdout)
+}
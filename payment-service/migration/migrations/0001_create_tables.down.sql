@@ -0,0 +1,4 @@
+drop table if exists fx_rates;
+drop table if exists payment_idempotency_keys;
+drop table if exists transactions;
+drop table if exists accounts;
@@ -0,0 +1,68 @@
+-- baseline of the schema previously kept in docker/schema.sql, databases created from it are adopted below
+create table if not exists accounts
+(
+    account_id varchar(512) not null,
+    amount numeric,
+    currency varchar(3) not null default 'EUR',
+    update_date date
+);
+
+create table if not exists transactions
+(
+    transaction_id varchar(512) not null,
+    order_id varchar(512) not null,
+    type varchar(16) not null default 'CHARGE',
+    amount numeric,
+    currency varchar(3) not null default 'EUR',
+    original_amount numeric,
+    original_currency varchar(3) not null default 'EUR',
+    fx_rate numeric not null default 1,
+    create_date timestamp,
+    request_id varchar(512) not null,
+    account_id varchar(512) not null
+);
+
+-- tables created from docker/schema.sql lack the columns added since, their transactions are all charges in EUR
+alter table accounts add column if not exists currency varchar(3) not null default 'EUR';
+alter table transactions
+    add column if not exists order_id varchar(512) not null default '',
+    add column if not exists type varchar(16) not null default 'CHARGE',
+    add column if not exists currency varchar(3) not null default 'EUR',
+    add column if not exists original_amount numeric,
+    add column if not exists original_currency varchar(3) not null default 'EUR',
+    add column if not exists fx_rate numeric not null default 1,
+    alter column create_date type timestamp;
+alter table transactions alter column order_id drop default;
+update transactions set original_amount = amount where original_amount is null;
+-- charges only name the order now, the product is kept on the order items
+do $$
+begin
+    if exists (select 1 from information_schema.columns
+               where table_schema = current_schema() and table_name = 'transactions' and column_name = 'product_id') then
+        alter table transactions alter column product_id drop not null;
+    end if;
+end $$;
+
+create index if not exists transactions_account_id_idx on transactions (account_id, create_date desc, transaction_id desc);
+-- a request is charged and refunded at most once
+create unique index if not exists transactions_request_id_type_idx on transactions (request_id, type);
+
+-- written in the same transaction as the charge, redis only caches processed uuids
+create table if not exists payment_idempotency_keys
+(
+    uuid varchar(512) not null primary key,
+    request_id varchar(512) not null,
+    create_date timestamp not null
+);
+
+create table if not exists fx_rates
+(
+    fx_rate_id varchar(512) not null primary key,
+    base_currency varchar(3) not null,
+    quote_currency varchar(3) not null,
+    rate numeric not null,
+    effective_date timestamp not null,
+    create_date timestamp not null
+);
+
+create index if not exists fx_rates_pair_effective_date_idx on fx_rates (base_currency, quote_currency, effective_date desc);
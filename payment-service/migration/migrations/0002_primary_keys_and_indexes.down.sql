@@ -0,0 +1,8 @@
+drop index if exists payment_idempotency_keys_request_id_idx;
+
+drop index if exists transactions_order_id_idx;
+alter table transactions drop constraint if exists transactions_account_id_fkey;
+alter table transactions drop constraint if exists transactions_pkey;
+
+alter table accounts alter column update_date type date;
+alter table accounts drop constraint if exists accounts_pkey;
@@ -0,0 +1,9 @@
+alter table accounts add constraint accounts_pkey primary key (account_id);
+alter table accounts alter column update_date type timestamp;
+
+alter table transactions add constraint transactions_pkey primary key (transaction_id);
+alter table transactions add constraint transactions_account_id_fkey
+    foreign key (account_id) references accounts (account_id);
+create index if not exists transactions_order_id_idx on transactions (order_id);
+
+create index if not exists payment_idempotency_keys_request_id_idx on payment_idempotency_keys (request_id);
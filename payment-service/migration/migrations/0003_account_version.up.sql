@@ -0,0 +1,2 @@
+-- increased on every balance change, a charge only applies to the version it has read
+alter table accounts add column if not exists version bigint not null default 0;
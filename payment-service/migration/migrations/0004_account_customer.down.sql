@@ -0,0 +1,2 @@
+drop index if exists accounts_customer_id_idx;
+alter table accounts drop column if exists customer_id;
@@ -0,0 +1,3 @@
+-- the customer owning the account, the subject of the tokens allowed to charge it
+alter table accounts add column if not exists customer_id varchar(512);
+create index if not exists accounts_customer_id_idx on accounts (customer_id);
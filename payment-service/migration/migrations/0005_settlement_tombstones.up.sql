@@ -0,0 +1,17 @@
+-- one row per request, a rollback that finds nothing charged leaves a ROLLED_BACK tombstone behind
+create table if not exists settlements
+(
+    request_id varchar(512) not null primary key,
+    status varchar(16) not null,
+    create_date timestamp not null,
+    update_date timestamp not null
+);
+
+insert into settlements (request_id, status, create_date, update_date)
+select request_id,
+       case when bool_or(type = 'REFUND') then 'ROLLED_BACK' else 'CHARGED' end,
+       min(create_date),
+       max(create_date)
+from transactions
+group by request_id
+on conflict (request_id) do nothing;
@@ -5,7 +5,12 @@ import (
 )
 
 type Account struct {
-	AccountId  string    `gorm:"type:bigint;primary_key" sql:"productOrderId"`
+	AccountId  string    `gorm:"type:varchar(512);primary_key" sql:"productOrderId"`
 	Amount     float64   `gorm:"type:numeric;not null"`
+	Currency   string    `gorm:"type:varchar(3);not null" sql:"currency"`
 	UpdateDate time.Time `gorm:"not null" sql:"createDate"`
+	// increased on every balance change
+	Version int64 `gorm:"not null;default:0" sql:"version"`
+	// the customer owning the account, matched against the token subject when auth is enabled
+	CustomerId string `gorm:"type:varchar(512);index" sql:"customerId"`
 }
@@ -0,0 +1,16 @@
+package model
+
+import (
+	"time"
+)
+
+// FxRate converts one unit of BaseCurrency into Rate units of QuoteCurrency,
+// valid from EffectiveDate until a newer rate for the same pair takes over.
+type FxRate struct {
+	FxRateId      string    `gorm:"type:varchar(512);primary_key" sql:"fxRateId"`
+	BaseCurrency  string    `gorm:"type:varchar(3);not null" sql:"baseCurrency"`
+	QuoteCurrency string    `gorm:"type:varchar(3);not null" sql:"quoteCurrency"`
+	Rate          float64   `gorm:"type:numeric;not null" sql:"rate"`
+	EffectiveDate time.Time `gorm:"not null" sql:"effectiveDate"`
+	CreateDate    time.Time `gorm:"not null" sql:"createDate"`
+}
@@ -0,0 +1,14 @@
+package model
+
+import "time"
+
+// IdempotencyKey is the durable record of a processed payment uuid, redis only caches it.
+type IdempotencyKey struct {
+	UUID       string    `gorm:"column:uuid;type:varchar(512);primary_key" sql:"uuid"`
+	RequestId  string    `gorm:"not null" sql:"requestId"`
+	CreateDate time.Time `gorm:"not null" sql:"createDate"`
+}
+
+func (IdempotencyKey) TableName() string {
+	return "payment_idempotency_keys"
+}
@@ -0,0 +1,19 @@
+package model
+
+import (
+	"time"
+)
+
+const (
+	SettlementStatusCharged    = "CHARGED"
+	SettlementStatusRolledBack = "ROLLED_BACK"
+)
+
+// Settlement is claimed once per request by whichever of charge and rollback comes first. A rollback claiming it
+// leaves a ROLLED_BACK tombstone, so a charge arriving after its rollback is refused instead of kept for good.
+type Settlement struct {
+	RequestId  string    `gorm:"type:varchar(512);primary_key" sql:"requestId"`
+	Status     string    `gorm:"type:varchar(16);not null" sql:"status"`
+	CreateDate time.Time `gorm:"not null" sql:"createDate"`
+	UpdateDate time.Time `gorm:"not null" sql:"updateDate"`
+}
@@ -4,11 +4,22 @@ import (
 	"time"
 )
 
+const (
+	TransactionTypeCharge = "CHARGE"
+	TransactionTypeRefund = "REFUND"
+)
+
 type Transaction struct {
-	TransactionId string    `gorm:"type:bigint;primary_key" sql:"productOrderId"`
-	ProductId     string    `gorm:"not null" sql:"productId"`
-	Amount        float64   `gorm:"type:numeric;not null"`
-	CreateDate    time.Time `gorm:"not null" sql:"createDate"`
-	RequestId     string    `gorm:"not null" sql:"requestId"`
-	AccountId     string    `gorm:"not null" sql:"accountId"`
+	TransactionId string  `gorm:"type:varchar(512);primary_key" sql:"productOrderId"`
+	OrderId       string  `gorm:"not null" sql:"orderId"`
+	Type          string  `gorm:"type:varchar(16);not null" sql:"type"`
+	Amount        float64 `gorm:"type:numeric;not null"`
+	Currency      string  `gorm:"type:varchar(3);not null" sql:"currency"`
+	// amount and currency as requested by the caller, before conversion to the account currency
+	OriginalAmount   float64   `gorm:"type:numeric;not null" sql:"originalAmount"`
+	OriginalCurrency string    `gorm:"type:varchar(3);not null" sql:"originalCurrency"`
+	FxRate           float64   `gorm:"type:numeric;not null" sql:"fxRate"`
+	CreateDate       time.Time `gorm:"not null" sql:"createDate"`
+	RequestId        string    `gorm:"not null" sql:"requestId"`
+	AccountId        string    `gorm:"not null" sql:"accountId"`
 }
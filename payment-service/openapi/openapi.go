@@ -0,0 +1,8 @@
+package openapi
+
+import _ "embed"
+
+// Spec is the OpenAPI document of the payment-service, the order-service generates its payment client from it.
+//
+//go:embed openapi.yaml
+var Spec []byte
@@ -0,0 +1,70 @@
+package orchestration
+
+import (
+	"common/broker"
+	"common/fault"
+	"common/signing"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"payment-service/configs"
+	"payment-service/service"
+)
+
+type RollbackConsumer struct {
+	broker         broker.Broker
+	config         *configs.Config
+	paymentService service.PaymentServiceInterface
+	// nil accepts unsigned messages
+	verifier *signing.MessageVerifier
+}
+
+func NewRollbackConsumer(
+	cfg *configs.Config,
+	broker broker.Broker,
+	paymentService service.PaymentServiceInterface,
+	verifier *signing.MessageVerifier) *RollbackConsumer {
+	return &RollbackConsumer{
+		broker:         broker,
+		config:         cfg,
+		paymentService: paymentService,
+		verifier:       verifier,
+	}
+}
+
+func (rc *RollbackConsumer) Consume(ctx context.Context) error {
+	queue := rc.config.RMQRollbackEventPaymentQueue
+	return rc.broker.Subscribe(ctx, queue, signing.Guard(rc.broker, rc.verifier, queue, rc.config.RMQQuarantineQueue, func(ctx context.Context, delivery broker.Delivery) {
+		log.Printf("Rollback Consumer: Received message: %s", string(delivery.Body))
+
+		requestId := string(delivery.Body)
+		err := rc.processRollback(requestId)
+		if err != nil {
+			log.Printf("Error occured processing a rollback: %v", err)
+		}
+		// a failed compensation is retried once and then parked in the dead-letter queue to be replayed
+		broker.Settle(ctx, rc.broker, queue, rc.config.RMQDeadLetterQueue, delivery, err)
+	}))
+}
+
+// processRollback refunds the charge made for the request. The charge stays in the history
+// and is offset by a refund transaction, so a request that was already refunded is skipped.
+func (rc *RollbackConsumer) processRollback(requestId string) error {
+	if err := fault.Inject(fault.PaymentRollback); err != nil {
+		return err
+	}
+
+	_, err := rc.paymentService.Refund(requestId)
+	// declined payments and orders failing before their payment have nothing to refund, their tombstone is kept
+	if errors.Is(err, service.ErrChargeNotFound) {
+		log.Printf("requestId %s was never charged, nothing to roll back", requestId)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("refunding requestId %s: %w", requestId, err)
+	}
+
+	log.Printf("requestId %s successfully rolledback", requestId)
+	return nil
+}
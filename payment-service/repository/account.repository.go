@@ -2,12 +2,28 @@ package repository
 
 import (
 	"errors"
+	"github.com/jackc/pgx/v5/pgconn"
 	"gorm.io/gorm"
 	"payment-service/model"
+	"time"
 )
 
+var (
+	ErrInsufficientBalance = errors.New("insufficient balance")
+	// ErrBalanceConflict means the account changed or was locked meanwhile, the whole payment can be retried
+	ErrBalanceConflict = errors.New("account balance changed concurrently")
+)
+
+// postgres error codes of transactions losing against concurrent ones
+var contentionCodes = map[string]bool{
+	"40001": true, // serialization_failure
+	"40P01": true, // deadlock_detected
+	"55P03": true, // lock_not_available
+}
+
 type AccountRepositoryInterface interface {
-	DeductAmount(tx *gorm.DB, accountId string, amount float64) error
+	Fetch(tx *gorm.DB, accountId string) (*model.Account, bool, error)
+	DeductAmount(tx *gorm.DB, account *model.Account, amount float64) error
 	AddAmount(tx *gorm.DB, accountId string, amount float64) error
 }
 
@@ -17,42 +33,74 @@ func NewAccountRepository() *AccountRepository {
 	return &AccountRepository{}
 }
 
-func (r *AccountRepository) DeductAmount(tx *gorm.DB, accountId string, amount float64) error {
+func (r *AccountRepository) Fetch(tx *gorm.DB, accountId string) (*model.Account, bool, error) {
 	var account model.Account
-	if err := tx.Where("account_id = ?", accountId).First(&account).Error; err != nil {
-		return err
+	err := tx.Where("account_id = ?", accountId).First(&account).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, false, nil
+		}
+		return nil, false, err
 	}
+	return &account, true, nil
+}
 
-	if account.Amount < amount {
-		return errors.New("insufficient balance")
+// DeductAmount takes amount off the balance in a single conditional update, which only applies while the account
+// still is at the version read into account and covers the amount. The account is updated in place on success.
+func (r *AccountRepository) DeductAmount(tx *gorm.DB, account *model.Account, amount float64) error {
+	nowTime := time.Now().UTC()
+	result := tx.Model(&model.Account{}).
+		Where("account_id = ? AND version = ? AND amount >= ?", account.AccountId, account.Version, amount).
+		Updates(map[string]interface{}{
+			"amount":      gorm.Expr("amount - ?", amount),
+			"version":     gorm.Expr("version + 1"),
+			"update_date": nowTime,
+		})
+	if result.Error != nil {
+		return translateContention(result.Error)
 	}
-
-	account.Amount -= amount
-	account.UpdateDate = account.UpdateDate.UTC()
-
-	if err := tx.Save(&account).Error; err != nil {
-		return err
+	if result.RowsAffected == 1 {
+		account.Amount -= amount
+		account.Version++
+		account.UpdateDate = nowTime
+		return nil
 	}
 
-	return nil
+	current, exists, err := r.Fetch(tx, account.AccountId)
+	if err != nil {
+		return translateContention(err)
+	}
+	if !exists {
+		return gorm.ErrRecordNotFound
+	}
+	if current.Version != account.Version {
+		return ErrBalanceConflict
+	}
+	return ErrInsufficientBalance
 }
 
+// AddAmount credits the balance atomically, credits can not overdraw an account so they never conflict.
 func (r *AccountRepository) AddAmount(tx *gorm.DB, accountId string, amount float64) error {
-	var account model.Account
-	if err := tx.Where("account_id = ?", accountId).First(&account).Error; err != nil {
-		return err
+	result := tx.Model(&model.Account{}).
+		Where("account_id = ?", accountId).
+		Updates(map[string]interface{}{
+			"amount":      gorm.Expr("amount + ?", amount),
+			"version":     gorm.Expr("version + 1"),
+			"update_date": time.Now().UTC(),
+		})
+	if result.Error != nil {
+		return translateContention(result.Error)
 	}
-
-	if account.Amount < amount {
-		return errors.New("insufficient balance")
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
 	}
+	return nil
+}
 
-	account.Amount += amount
-	account.UpdateDate = account.UpdateDate.UTC()
-
-	if err := tx.Save(&account).Error; err != nil {
-		return err
+func translateContention(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && contentionCodes[pgErr.Code] {
+		return ErrBalanceConflict
 	}
-
-	return nil
+	return err
 }
@@ -0,0 +1,32 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+	"payment-service/model"
+	"time"
+)
+
+type FxRateRepositoryInterface interface {
+	FetchEffective(tx *gorm.DB, baseCurrency string, quoteCurrency string, at time.Time) (*model.FxRate, bool, error)
+}
+
+type FxRateRepository struct{}
+
+func NewFxRateRepository() *FxRateRepository {
+	return &FxRateRepository{}
+}
+
+// FetchEffective returns the latest rate for the currency pair that is already in effect at the given time.
+func (r *FxRateRepository) FetchEffective(tx *gorm.DB, baseCurrency string, quoteCurrency string, at time.Time) (*model.FxRate, bool, error) {
+	var fxRate model.FxRate
+	err := tx.Where("base_currency = ? AND quote_currency = ? AND effective_date <= ?", baseCurrency, quoteCurrency, at).
+		Order("effective_date desc").
+		First(&fxRate).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	return &fxRate, true, nil
+}
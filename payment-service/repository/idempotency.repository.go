@@ -0,0 +1,27 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"payment-service/model"
+)
+
+type IdempotencyRepositoryInterface interface {
+	Acquire(tx *gorm.DB, key *model.IdempotencyKey) (bool, error)
+}
+
+type IdempotencyRepository struct{}
+
+func NewIdempotencyRepository() *IdempotencyRepository {
+	return &IdempotencyRepository{}
+}
+
+// Acquire inserts the key unless it exists already. It has to run in the same transaction as the charge,
+// so a key is only taken together with the charge it protects.
+func (r *IdempotencyRepository) Acquire(tx *gorm.DB, key *model.IdempotencyKey) (bool, error) {
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(key)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
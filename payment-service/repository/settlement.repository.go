@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"payment-service/model"
+	"time"
+)
+
+type SettlementRepositoryInterface interface {
+	Claim(tx *gorm.DB, requestId string, status string) (bool, error)
+	Fetch(tx *gorm.DB, requestId string) (*model.Settlement, error)
+	MarkRolledBack(tx *gorm.DB, requestId string) error
+}
+
+type SettlementRepository struct{}
+
+func NewSettlementRepository() *SettlementRepository {
+	return &SettlementRepository{}
+}
+
+// Claim records the request with status, it reports false when the request was claimed already. A concurrent
+// claim waits until the transaction holding the request finishes.
+func (r *SettlementRepository) Claim(tx *gorm.DB, requestId string, status string) (bool, error) {
+	now := time.Now().UTC()
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&model.Settlement{
+		RequestId:  requestId,
+		Status:     status,
+		CreateDate: now,
+		UpdateDate: now,
+	})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *SettlementRepository) Fetch(tx *gorm.DB, requestId string) (*model.Settlement, error) {
+	var settlement model.Settlement
+	if err := tx.Where("request_id = ?", requestId).First(&settlement).Error; err != nil {
+		return nil, err
+	}
+	return &settlement, nil
+}
+
+func (r *SettlementRepository) MarkRolledBack(tx *gorm.DB, requestId string) error {
+	return tx.Model(&model.Settlement{}).
+		Where("request_id = ? AND status = ?", requestId, model.SettlementStatusCharged).
+		Updates(map[string]interface{}{
+			"status":      model.SettlementStatusRolledBack,
+			"update_date": time.Now().UTC(),
+		}).Error
+}
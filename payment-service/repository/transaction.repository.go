@@ -1,13 +1,26 @@
 package repository
 
 import (
+	"common/pagination"
 	"gorm.io/gorm"
 	"payment-service/model"
+	"time"
 )
 
 type TransactionRepositoryInterface interface {
 	Insert(tx *gorm.DB, transaction *model.Transaction) error
-	Delete(tx *gorm.DB, requestId string) (*model.Transaction, error)
+	FetchByRequestId(tx *gorm.DB, requestId string) ([]model.Transaction, error)
+	FetchAll(tx *gorm.DB, filter TransactionFilter) ([]model.Transaction, error)
+}
+
+// TransactionFilter narrows FetchAll, empty fields are not applied.
+type TransactionFilter struct {
+	AccountId string
+	Type      string
+	From      *time.Time
+	To        *time.Time
+	Cursor    *pagination.Cursor
+	Limit     int
 }
 
 type TransactionRepository struct{}
@@ -20,13 +33,39 @@ func (r *TransactionRepository) Insert(tx *gorm.DB, transaction *model.Transacti
 	return tx.Create(transaction).Error
 }
 
-func (r *TransactionRepository) Delete(tx *gorm.DB, requestId string) (*model.Transaction, error) {
-	var transaction model.Transaction
-	if err := tx.Where("request_id = ?", requestId).First(&transaction).Error; err != nil {
+func (r *TransactionRepository) FetchByRequestId(tx *gorm.DB, requestId string) ([]model.Transaction, error) {
+	var transactions []model.Transaction
+	err := tx.Where("request_id = ?", requestId).Order("create_date").Find(&transactions).Error
+	if err != nil {
 		return nil, err
 	}
-	if err := tx.Delete(&transaction).Error; err != nil {
+	return transactions, nil
+}
+
+// FetchAll returns transactions newest first, ties on create date are broken by id so pages never overlap.
+func (r *TransactionRepository) FetchAll(tx *gorm.DB, filter TransactionFilter) ([]model.Transaction, error) {
+	query := tx.Model(&model.Transaction{})
+	if filter.AccountId != "" {
+		query = query.Where("account_id = ?", filter.AccountId)
+	}
+	if filter.Type != "" {
+		query = query.Where("type = ?", filter.Type)
+	}
+	if filter.From != nil {
+		query = query.Where("create_date >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("create_date < ?", *filter.To)
+	}
+	if filter.Cursor != nil {
+		query = query.Where("(create_date < ? OR (create_date = ? AND transaction_id < ?))",
+			filter.Cursor.CreateDate, filter.Cursor.CreateDate, filter.Cursor.Id)
+	}
+
+	var transactions []model.Transaction
+	err := query.Order("create_date desc").Order("transaction_id desc").Limit(filter.Limit).Find(&transactions).Error
+	if err != nil {
 		return nil, err
 	}
-	return &transaction, nil
+	return transactions, nil
 }
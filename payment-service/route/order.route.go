@@ -6,16 +6,32 @@ import (
 )
 
 type PaymentRouteHandler struct {
-	paymentHandler handler.PaymentHandler
+	paymentHandler  handler.PaymentHandler
+	authenticate    gin.HandlerFunc
+	verifySignature gin.HandlerFunc
+	signedOrStaff   gin.HandlerFunc
+	validate        gin.HandlerFunc
 }
 
-func NewPaymentRouteHandler(paymentHandler handler.PaymentHandler) PaymentRouteHandler {
+func NewPaymentRouteHandler(paymentHandler handler.PaymentHandler, authenticate gin.HandlerFunc, verifySignature gin.HandlerFunc, signedOrStaff gin.HandlerFunc, validate gin.HandlerFunc) PaymentRouteHandler {
 	return PaymentRouteHandler{
-		paymentHandler: paymentHandler,
+		paymentHandler:  paymentHandler,
+		authenticate:    authenticate,
+		verifySignature: verifySignature,
+		signedOrStaff:   signedOrStaff,
+		validate:        validate,
 	}
 }
 
 func (h *PaymentRouteHandler) PaymentRoute(group *gin.RouterGroup) {
 	router := group.Group("payment")
-	router.POST("/process", h.paymentHandler.ProcessPayment)
+	// only the order-service charges accounts, it signs the request and forwards the token of its caller
+	router.POST("/process", h.verifySignature, h.authenticate, h.validate, h.paymentHandler.ProcessPayment)
+	// customer facing routes
+	router.GET("/account/:id", h.authenticate, h.validate, h.paymentHandler.GetAccount)
+	router.GET("/account/:id/transactions", h.authenticate, h.validate, h.paymentHandler.ListTransactions)
+	// internal routes of the reconciliation
+	router.GET("/transactions", h.verifySignature, h.validate, h.paymentHandler.ListAllTransactions)
+	// support staff looks up the money moved for an order with their own token
+	router.GET("/transaction/by-request/:requestId", h.signedOrStaff, h.validate, h.paymentHandler.GetTransactionsByRequestId)
 }
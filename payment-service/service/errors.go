@@ -0,0 +1,19 @@
+package service
+
+import (
+	"errors"
+	"payment-service/repository"
+)
+
+var (
+	ErrAccountNotFound  = errors.New("account does not exist")
+	ErrAccountNotOwned  = errors.New("account belongs to another customer")
+	ErrDuplicatePayment = errors.New("request was already charged")
+	ErrChargeNotFound   = errors.New("request was never charged")
+	// the request was rolled back before its charge arrived
+	ErrPaymentRolledBack   = errors.New("request was rolled back already")
+	ErrNoFxRate            = errors.New("no fx rate")
+	ErrInsufficientBalance = repository.ErrInsufficientBalance
+	// ErrBalanceConflict is returned once the retries of a payment are used up, the caller may retry it later
+	ErrBalanceConflict = repository.ErrBalanceConflict
+)
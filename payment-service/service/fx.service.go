@@ -0,0 +1,52 @@
+package service
+
+import (
+	"fmt"
+	"gorm.io/gorm"
+	"math"
+	"payment-service/repository"
+	"time"
+)
+
+type FxServiceInterface interface {
+	Convert(tx *gorm.DB, amount float64, fromCurrency string, toCurrency string) (float64, float64, error)
+}
+
+type FxService struct {
+	fxRateRepository repository.FxRateRepositoryInterface
+}
+
+func NewFxService(fxRateRepository repository.FxRateRepositoryInterface) *FxService {
+	return &FxService{fxRateRepository: fxRateRepository}
+}
+
+// Convert returns the amount expressed in toCurrency together with the applied rate.
+// A direct rate for the pair is preferred, otherwise the inverse of the opposite pair is used.
+func (fs *FxService) Convert(tx *gorm.DB, amount float64, fromCurrency string, toCurrency string) (float64, float64, error) {
+	if fromCurrency == toCurrency {
+		return amount, 1, nil
+	}
+
+	now := time.Now().UTC()
+	fxRate, exists, err := fs.fxRateRepository.FetchEffective(tx, fromCurrency, toCurrency, now)
+	if err != nil {
+		return 0, 0, err
+	}
+	if exists {
+		return roundAmount(amount * fxRate.Rate), fxRate.Rate, nil
+	}
+
+	fxRate, exists, err = fs.fxRateRepository.FetchEffective(tx, toCurrency, fromCurrency, now)
+	if err != nil {
+		return 0, 0, err
+	}
+	if !exists || fxRate.Rate == 0 {
+		return 0, 0, fmt.Errorf("%w from %s to %s", ErrNoFxRate, fromCurrency, toCurrency)
+	}
+	rate := 1 / fxRate.Rate
+	return roundAmount(amount * rate), rate, nil
+}
+
+func roundAmount(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -1,17 +1,33 @@
 package service
 
 import (
+	"common/auth"
+	"common/fault"
+	"common/pagination"
 	"errors"
+	"fmt"
 	uuid "github.com/satori/go.uuid"
 	"gorm.io/gorm"
+	"log"
 	"payment-service/dto/request"
+	"payment-service/dto/response"
 	"payment-service/model"
 	"payment-service/repository"
 	"time"
 )
 
+const (
+	maxChargeAttempts  = 3
+	chargeRetryBackoff = 20 * time.Millisecond
+)
+
 type PaymentServiceInterface interface {
-	ProcessPayment(request request.PaymentRequest) error
+	ProcessPayment(claims *auth.Claims, request request.PaymentRequest) error
+	GetAccount(claims *auth.Claims, accountId string) (response.AccountResponse, error)
+	ListTransactions(claims *auth.Claims, accountId string, listRequest request.TransactionListRequest) (response.TransactionPageResponse, error)
+	ListAllTransactions(listRequest request.TransactionListRequest) (response.TransactionPageResponse, error)
+	GetTransactionsByRequestId(requestId string) ([]response.TransactionResponse, error)
+	Refund(requestId string) (response.TransactionResponse, error)
 }
 
 type PaymentService struct {
@@ -19,6 +35,9 @@ type PaymentService struct {
 	accountRepository     repository.AccountRepositoryInterface
 	transactionRepository repository.TransactionRepositoryInterface
 	redisService          RedisServiceInterface
+	fxService             FxServiceInterface
+	idempotencyRepository repository.IdempotencyRepositoryInterface
+	settlementRepository  repository.SettlementRepositoryInterface
 }
 
 func NewPaymentService(
@@ -26,50 +45,333 @@ func NewPaymentService(
 	accountRepo repository.AccountRepositoryInterface,
 	transactionRepo repository.TransactionRepositoryInterface,
 	redisService RedisServiceInterface,
+	fxService FxServiceInterface,
+	idempotencyRepo repository.IdempotencyRepositoryInterface,
+	settlementRepo repository.SettlementRepositoryInterface,
 ) *PaymentService {
 	return &PaymentService{
 		db:                    db,
 		accountRepository:     accountRepo,
 		transactionRepository: transactionRepo,
 		redisService:          redisService,
+		fxService:             fxService,
+		idempotencyRepository: idempotencyRepo,
+		settlementRepository:  settlementRepo,
 	}
 }
 
-func (ps *PaymentService) ProcessPayment(req request.PaymentRequest) error {
-	valid, err := ps.redisService.IdempotencyValidation(req.UUID)
+// ProcessPayment charges the account of req, claims are nil when auth is disabled and the account is not checked then.
+func (ps *PaymentService) ProcessPayment(claims *auth.Claims, req request.PaymentRequest) error {
+	// redis is only a fast path, postgres decides when the cache is cold or unavailable
+	processed, err := ps.redisService.IsProcessed(req.UUID)
 	if err != nil {
-		return err
+		log.Printf("Idempotency cache unavailable for %s: %v", req.UUID, err)
+	}
+	if processed {
+		log.Printf("Payment %s already processed", req.UUID)
+		return nil
 	}
-	if !valid {
-		return errors.New("duplicate or invalid request")
+
+	// a payment losing the race for the account balance is retried from scratch, it reads the new balance then
+	for attempt := 1; ; attempt++ {
+		err = ps.charge(claims, req)
+		if !errors.Is(err, ErrBalanceConflict) || attempt == maxChargeAttempts {
+			return err
+		}
+		log.Printf("Payment %s conflicted on account %s, retrying", req.UUID, req.AccountID)
+		time.Sleep(time.Duration(attempt) * chargeRetryBackoff)
 	}
+}
 
+// charge records the payment uuid, deducts the converted amount and stores the charge in a single transaction.
+func (ps *PaymentService) charge(claims *auth.Claims, req request.PaymentRequest) error {
 	tx := ps.getDbConnection()
-	if err := ps.accountRepository.DeductAmount(tx, req.AccountID, req.Amount); err != nil {
+	acquired, err := ps.idempotencyRepository.Acquire(tx, &model.IdempotencyKey{
+		UUID:       req.UUID,
+		RequestId:  req.RequestID,
+		CreateDate: time.Now().UTC(),
+	})
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if !acquired {
+		tx.Rollback()
+		log.Printf("Payment %s already processed", req.UUID)
+		ps.markProcessed(req.UUID)
+		return nil
+	}
+
+	claimed, err := ps.settlementRepository.Claim(tx, req.RequestID, model.SettlementStatusCharged)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if !claimed {
+		existing, err := ps.settlementRepository.Fetch(tx, req.RequestID)
+		tx.Rollback()
+		if err != nil {
+			return err
+		}
+		if existing.Status == model.SettlementStatusRolledBack {
+			log.Printf("requestId %s was rolled back before it was charged", req.RequestID)
+			return ErrPaymentRolledBack
+		}
+		// another payment uuid already charged this request
+		return ErrDuplicatePayment
+	}
+
+	account, exists, err := ps.accountRepository.Fetch(tx, req.AccountID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if !exists {
+		tx.Rollback()
+		return ErrAccountNotFound
+	}
+	if !owns(claims, account) {
+		tx.Rollback()
+		return ErrAccountNotOwned
+	}
+
+	amount, rate, err := ps.fxService.Convert(tx, req.Amount, req.Currency, account.Currency)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := ps.accountRepository.DeductAmount(tx, account, amount); err != nil {
 		tx.Rollback()
 		return err
 	}
 
 	newTxn := &model.Transaction{
-		TransactionId: uuid.NewV4().String(),
-		ProductId:     req.ProductId,
-		Amount:        req.Amount,
-		CreateDate:    time.Now().UTC(),
-		RequestId:     req.RequestID,
-		AccountId:     req.AccountID,
+		TransactionId:    uuid.NewV4().String(),
+		OrderId:          req.OrderId,
+		Type:             model.TransactionTypeCharge,
+		Amount:           amount,
+		Currency:         account.Currency,
+		OriginalAmount:   req.Amount,
+		OriginalCurrency: req.Currency,
+		FxRate:           rate,
+		CreateDate:       time.Now().UTC(),
+		RequestId:        req.RequestID,
+		AccountId:        req.AccountID,
 	}
 	if err := ps.transactionRepository.Insert(tx, newTxn); err != nil {
 		tx.Rollback()
+		// another payment uuid already charged this request
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return ErrDuplicatePayment
+		}
 		return err
 	}
 
+	if err := fault.Inject(fault.PaymentCommitBefore); err != nil {
+		tx.Rollback()
+		return err
+	}
 	if err := tx.Commit().Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return ErrDuplicatePayment
+		}
 		return err
 	}
 
+	ps.markProcessed(req.UUID)
 	return nil
 }
 
+func (ps *PaymentService) markProcessed(uuid string) {
+	if err := ps.redisService.MarkProcessed(uuid); err != nil {
+		log.Printf("Failed to cache processed payment %s: %v", uuid, err)
+	}
+}
+
+// owns tells whether the caller may use account, every caller may while auth is disabled.
+func owns(claims *auth.Claims, account *model.Account) bool {
+	return claims == nil || account.CustomerId == claims.Subject
+}
+
+func (ps *PaymentService) GetAccount(claims *auth.Claims, accountId string) (response.AccountResponse, error) {
+	account, exists, err := ps.accountRepository.Fetch(ps.db, accountId)
+	if err != nil {
+		return response.AccountResponse{}, err
+	}
+	if !exists {
+		return response.AccountResponse{}, ErrAccountNotFound
+	}
+	if !owns(claims, account) {
+		return response.AccountResponse{}, ErrAccountNotOwned
+	}
+	return response.AccountResponse{
+		AccountId:  account.AccountId,
+		CustomerId: account.CustomerId,
+		Amount:     account.Amount,
+		Currency:   account.Currency,
+		UpdateDate: account.UpdateDate,
+	}, nil
+}
+
+func (ps *PaymentService) ListTransactions(claims *auth.Claims, accountId string, listRequest request.TransactionListRequest) (response.TransactionPageResponse, error) {
+	account, exists, err := ps.accountRepository.Fetch(ps.db, accountId)
+	if err != nil {
+		return response.TransactionPageResponse{}, err
+	}
+	if !exists {
+		return response.TransactionPageResponse{}, ErrAccountNotFound
+	}
+	if !owns(claims, account) {
+		return response.TransactionPageResponse{}, ErrAccountNotOwned
+	}
+	return ps.listTransactions(accountId, listRequest)
+}
+
+// ListAllTransactions pages through the transactions of every account, the order-service reconciles its orders against them.
+func (ps *PaymentService) ListAllTransactions(listRequest request.TransactionListRequest) (response.TransactionPageResponse, error) {
+	return ps.listTransactions("", listRequest)
+}
+
+func (ps *PaymentService) listTransactions(accountId string, listRequest request.TransactionListRequest) (response.TransactionPageResponse, error) {
+	cursor, err := pagination.Decode(listRequest.Cursor)
+	if err != nil {
+		return response.TransactionPageResponse{}, err
+	}
+
+	limit := pagination.Limit(listRequest.Limit)
+	// one extra row tells whether there is a next page
+	transactions, err := ps.transactionRepository.FetchAll(ps.db, repository.TransactionFilter{
+		AccountId: accountId,
+		Type:      listRequest.Type,
+		From:      listRequest.From,
+		To:        listRequest.To,
+		Cursor:    cursor,
+		Limit:     limit + 1,
+	})
+	if err != nil {
+		return response.TransactionPageResponse{}, err
+	}
+
+	page := response.TransactionPageResponse{Items: []response.TransactionResponse{}}
+	if len(transactions) > limit {
+		transactions = transactions[:limit]
+		last := transactions[limit-1]
+		page.NextCursor = pagination.Cursor{CreateDate: last.CreateDate, Id: last.TransactionId}.Encode()
+	}
+	for _, transaction := range transactions {
+		page.Items = append(page.Items, toTransactionResponse(transaction))
+	}
+	return page, nil
+}
+
+func (ps *PaymentService) GetTransactionsByRequestId(requestId string) ([]response.TransactionResponse, error) {
+	transactions, err := ps.transactionRepository.FetchByRequestId(ps.db, requestId)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := []response.TransactionResponse{}
+	for _, transaction := range transactions {
+		responses = append(responses, toTransactionResponse(transaction))
+	}
+	return responses, nil
+}
+
+// Refund offsets the charge of the request with a refund transaction and credits the account, the charge stays in the history.
+// A request is refunded at most once, refunding it again returns the first refund.
+func (ps *PaymentService) Refund(requestId string) (response.TransactionResponse, error) {
+	tx := ps.getDbConnection()
+	// waits for a charge of the request in flight, it is refunded below once it committed
+	if _, err := ps.settlementRepository.Claim(tx, requestId, model.SettlementStatusRolledBack); err != nil {
+		tx.Rollback()
+		return response.TransactionResponse{}, err
+	}
+	if err := ps.settlementRepository.MarkRolledBack(tx, requestId); err != nil {
+		tx.Rollback()
+		return response.TransactionResponse{}, err
+	}
+	transactions, err := ps.transactionRepository.FetchByRequestId(tx, requestId)
+	if err != nil {
+		tx.Rollback()
+		return response.TransactionResponse{}, err
+	}
+
+	var charge *model.Transaction
+	for i := range transactions {
+		switch transactions[i].Type {
+		case model.TransactionTypeRefund:
+			tx.Rollback()
+			log.Printf("requestId %s already refunded", requestId)
+			return toTransactionResponse(transactions[i]), nil
+		case model.TransactionTypeCharge:
+			charge = &transactions[i]
+		}
+	}
+	if charge == nil {
+		// the tombstone is kept, so a charge arriving later is refused
+		if err := tx.Commit().Error; err != nil {
+			return response.TransactionResponse{}, err
+		}
+		return response.TransactionResponse{}, ErrChargeNotFound
+	}
+
+	refund := *charge
+	refund.TransactionId = uuid.NewV4().String()
+	refund.Type = model.TransactionTypeRefund
+	refund.CreateDate = time.Now().UTC()
+	if err := ps.transactionRepository.Insert(tx, &refund); err != nil {
+		tx.Rollback()
+		// a concurrent refund of the request won
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return ps.refundOf(requestId)
+		}
+		return response.TransactionResponse{}, err
+	}
+
+	if err := ps.accountRepository.AddAmount(tx, charge.AccountId, charge.Amount); err != nil {
+		tx.Rollback()
+		return response.TransactionResponse{}, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return ps.refundOf(requestId)
+		}
+		return response.TransactionResponse{}, err
+	}
+	return toTransactionResponse(refund), nil
+}
+
+func (ps *PaymentService) refundOf(requestId string) (response.TransactionResponse, error) {
+	transactions, err := ps.transactionRepository.FetchByRequestId(ps.db, requestId)
+	if err != nil {
+		return response.TransactionResponse{}, err
+	}
+	for _, transaction := range transactions {
+		if transaction.Type == model.TransactionTypeRefund {
+			return toTransactionResponse(transaction), nil
+		}
+	}
+	return response.TransactionResponse{}, fmt.Errorf("refund of requestId %s not found", requestId)
+}
+
+func toTransactionResponse(transaction model.Transaction) response.TransactionResponse {
+	return response.TransactionResponse{
+		TransactionId:    transaction.TransactionId,
+		RequestId:        transaction.RequestId,
+		AccountId:        transaction.AccountId,
+		OrderId:          transaction.OrderId,
+		Type:             transaction.Type,
+		Amount:           transaction.Amount,
+		Currency:         transaction.Currency,
+		OriginalAmount:   transaction.OriginalAmount,
+		OriginalCurrency: transaction.OriginalCurrency,
+		FxRate:           transaction.FxRate,
+		CreateDate:       transaction.CreateDate,
+	}
+}
+
 func (ps *PaymentService) getDbConnection() *gorm.DB {
 	tx := ps.db.Begin()
 	defer func() {
@@ -8,27 +8,28 @@ import (
 )
 
 type RedisServiceInterface interface {
-	IdempotencyValidation(key string) (bool, error)
+	IsProcessed(key string) (bool, error)
+	MarkProcessed(key string) error
 }
 
+// RedisService caches processed payment uuids in front of postgres.
 type RedisService struct {
 	redisClient *redis.Client
+	ttl         time.Duration
 }
 
-func NewRedisService(redisClient *redis.Client) *RedisService {
-	return &RedisService{redisClient: redisClient}
+func NewRedisService(redisClient *redis.Client, ttl time.Duration) *RedisService {
+	return &RedisService{redisClient: redisClient, ttl: ttl}
 }
 
-func (red *RedisService) IdempotencyValidation(key string) (bool, error) {
-	if key == "" {
-		return false, nil
-	}
-
-	ctx := context.Background()
-	result, err := red.redisClient.SetNX(ctx, key, "processed", 5*time.Minute).Result()
+func (red *RedisService) IsProcessed(key string) (bool, error) {
+	count, err := red.redisClient.Exists(context.Background(), key).Result()
 	if err != nil {
 		return false, err
 	}
+	return count == 1, nil
+}
 
-	return result, nil
+func (red *RedisService) MarkProcessed(key string) error {
+	return red.redisClient.Set(context.Background(), key, "processed", red.ttl).Err()
 }